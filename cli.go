@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/koscakluka/ema-core/core"
+	"github.com/koscakluka/ema-core/core/config"
+	"github.com/koscakluka/ema-core/core/llms"
+)
+
+// runChat reads prompts from stdin line by line and streams the responses to
+// stdout, a prompt is only read once the previous response ended
+func runChat(ctx context.Context, configPath string, args []string) error {
+	flags := flag.NewFlagSet("chat", flag.ExitOnError)
+	speak := flags.Bool("speak", false, "speak the responses")
+	flags.Parse(args)
+
+	orchestrator, release, err := newOrchestrator(ctx, configPath, func(c *config.Config) error {
+		c.SpeechToText.Provider = config.ProviderNone
+		if !*speak {
+			c.TextToSpeech.Provider = config.ProviderNone
+			c.Audio.Provider = config.ProviderNone
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	responseEnded := make(chan struct{}, 1)
+	if err := orchestrator.Orchestrate(ctx,
+		orchestration.WithResponseCallback(func(response string) {
+			fmt.Print(response)
+		}),
+		orchestration.WithResponseEndCallback(func() {
+			fmt.Println()
+			responseEnded <- struct{}{}
+		}),
+	); err != nil {
+		return err
+	}
+
+	interactive := isTerminal(os.Stdin)
+	lines := readLines(os.Stdin)
+	for {
+		if interactive {
+			fmt.Print("> ")
+		}
+
+		var line string
+		select {
+		case <-ctx.Done():
+			return nil
+		case result, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			if result.err != nil {
+				return fmt.Errorf("failed to read prompt: %w", result.err)
+			}
+			line = strings.TrimSpace(result.line)
+		}
+		if line == "" {
+			continue
+		}
+
+		orchestrator.SendPrompt(line)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-responseEnded:
+		}
+	}
+}
+
+// askResult is the output of the ask command
+type askResult struct {
+	Prompt    string        `json:"prompt"`
+	Response  string        `json:"response"`
+	ToolCalls []askToolCall `json:"toolCalls,omitempty"`
+	Cancelled bool          `json:"cancelled,omitempty"`
+}
+
+type askToolCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Response  string `json:"response"`
+}
+
+// runAsk sends a single prompt, taken from the arguments or stdin, and prints
+// the response and the executed tool calls as JSON
+func runAsk(ctx context.Context, configPath string, args []string) error {
+	flags := flag.NewFlagSet("ask", flag.ExitOnError)
+	timeout := flags.Duration("timeout", 2*time.Minute, "how long to wait for the response")
+	flags.Parse(args)
+
+	prompt := strings.Join(flags.Args(), " ")
+	if prompt == "" {
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt: %w", err)
+		}
+		prompt = string(input)
+	}
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return fmt.Errorf("prompt is required")
+	}
+
+	orchestrator, release, err := newOrchestrator(ctx, configPath, func(c *config.Config) error {
+		c.SpeechToText.Provider = config.ProviderNone
+		c.TextToSpeech.Provider = config.ProviderNone
+		c.Audio.Provider = config.ProviderNone
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	responseEnded := make(chan struct{}, 1)
+	if err := orchestrator.Orchestrate(ctx,
+		orchestration.WithResponseEndCallback(func() {
+			select {
+			case responseEnded <- struct{}{}:
+			default:
+			}
+		}),
+	); err != nil {
+		return err
+	}
+
+	orchestrator.SendPrompt(prompt)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(*timeout):
+		return fmt.Errorf("no response after %v", *timeout)
+	case <-responseEnded:
+	}
+
+	result := askResult{Prompt: prompt}
+	for turn := range orchestrator.Turns().RValues {
+		if turn.Role != llms.TurnRoleAssistant {
+			continue
+		}
+		result.Response = turn.Content
+		result.Cancelled = turn.Cancelled
+		for _, toolCall := range turn.ToolCalls {
+			result.ToolCalls = append(result.ToolCalls, askToolCall{
+				Name:      toolCall.Name,
+				Arguments: toolCall.Arguments,
+				Response:  toolCall.Response,
+			})
+		}
+		break
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// listenLine is a line of the listen command's JSON output
+type listenLine struct {
+	Role string `json:"role"`
+	Text string `json:"text"`
+}
+
+// runListen holds a voice conversation and prints what both sides said, one
+// line per turn
+func runListen(ctx context.Context, configPath string, args []string) error {
+	flags := flag.NewFlagSet("listen", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the lines as JSON objects")
+	flags.Parse(args)
+
+	orchestrator, release, err := newOrchestrator(ctx, configPath, func(c *config.Config) error {
+		if c.SpeechToText.Provider == config.ProviderNone || c.Audio.Provider == config.ProviderNone {
+			return fmt.Errorf("listen needs speech to text and audio, the config disables them")
+		}
+		c.Orchestrator.AlwaysRecording = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	printLine := func(role llms.TurnRole, text string) {
+		if *asJSON {
+			line, _ := json.Marshal(listenLine{Role: string(role), Text: text})
+			fmt.Println(string(line))
+		} else {
+			fmt.Printf("%s: %s\n", role, text)
+		}
+	}
+
+	var response strings.Builder
+	if err := orchestrator.Orchestrate(ctx,
+		orchestration.WithTranscriptionCallback(func(transcript string) {
+			printLine(llms.TurnRoleUser, transcript)
+		}),
+		orchestration.WithResponseCallback(func(chunk string) {
+			response.WriteString(chunk)
+		}),
+		orchestration.WithResponseEndCallback(func() {
+			if text := strings.TrimSpace(response.String()); text != "" {
+				printLine(llms.TurnRoleAssistant, text)
+			}
+			response.Reset()
+		}),
+	); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+type readResult struct {
+	line string
+	err  error
+}
+
+// readLines reads the lines in the background, so waiting for input can be
+// interrupted
+func readLines(r io.Reader) <-chan readResult {
+	lines := make(chan readResult)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines <- readResult{line: scanner.Text()}
+		}
+		if err := scanner.Err(); err != nil {
+			lines <- readResult{err: err}
+		}
+	}()
+	return lines
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
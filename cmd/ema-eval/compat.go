@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/invopop/jsonschema"
+	"github.com/koscakluka/ema-core/core/llms"
+)
+
+// compatibleClient is a minimal client for OpenAI compatible chat completion
+// APIs (e.g. llama.cpp server, vLLM, Ollama), it keeps track of used tokens so
+// they can be reported
+type compatibleClient struct {
+	baseURL string
+	apiKey  string
+	model   string
+
+	usage   llms.Usage
+	usageMu sync.Mutex
+}
+
+func newCompatibleClient(baseURL, apiKey, model string) *compatibleClient {
+	return &compatibleClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
+
+// structuredCompatibleClient exposes the compatible client as a structured
+// prompt classifier
+type structuredCompatibleClient struct{ *compatibleClient }
+
+// generalCompatibleClient exposes the compatible client as a general prompt
+// classifier
+type generalCompatibleClient struct{ *compatibleClient }
+
+func (c *compatibleClient) Usage() llms.Usage {
+	c.usageMu.Lock()
+	defer c.usageMu.Unlock()
+	return c.usage
+}
+
+func (c structuredCompatibleClient) PromptWithStructure(ctx context.Context, prompt string, outputSchema any, opts ...llms.StructuredPromptOption) error {
+	options := llms.StructuredPromptOptions{}
+	for _, opt := range opts {
+		opt.ApplyToStructured(&options)
+	}
+
+	var (
+		schema     *jsonschema.Schema
+		schemaName string
+	)
+	if schemaProvider, ok := outputSchema.(interface{ JSONSchema() *jsonschema.Schema }); ok {
+		schema = schemaProvider.JSONSchema()
+	} else {
+		schema = (&jsonschema.Reflector{DoNotReference: true}).Reflect(outputSchema)
+	}
+	schemaName = reflect.Indirect(reflect.ValueOf(outputSchema)).Type().Name()
+
+	content, err := c.complete(ctx, chatRequest{
+		Messages: toChatMessages(options.BaseOptions.Instructions, options.BaseOptions.Turns, prompt),
+		ResponseFormat: &chatResponseFormat{
+			Type: "json_schema",
+			JSONSchema: &chatJSONSchema{
+				Name:   schemaName,
+				Schema: schema,
+				Strict: true,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(content), outputSchema); err != nil {
+		return fmt.Errorf("error unmarshalling response: %w", err)
+	}
+	return nil
+}
+
+func (c generalCompatibleClient) Prompt(ctx context.Context, prompt string, opts ...llms.GeneralPromptOption) (*llms.Message, error) {
+	options := llms.GeneralPromptOptions{}
+	for _, opt := range opts {
+		opt.ApplyToGeneral(&options)
+	}
+
+	content, err := c.complete(ctx, chatRequest{
+		Messages: toChatMessages(options.BaseOptions.Instructions, options.BaseOptions.Turns, prompt),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &llms.Message{Role: llms.MessageRoleAssistant, Content: content}, nil
+}
+
+func (c *compatibleClient) complete(ctx context.Context, request chatRequest) (string, error) {
+	request.Model = c.model
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", fmt.Errorf("error creating HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, responseBody)
+	}
+
+	var response chatResponse
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		return "", fmt.Errorf("error unmarshalling response: %w", err)
+	}
+	if response.Usage != nil {
+		c.usageMu.Lock()
+		c.usage.InputTokens += response.Usage.PromptTokens
+		c.usage.OutputTokens += response.Usage.CompletionTokens
+		c.usage.TotalTokens += response.Usage.TotalTokens
+		c.usageMu.Unlock()
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("no choices in response")
+	}
+
+	return response.Choices[0].Message.Content, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func toChatMessages(instructions string, turns []llms.Turn, prompt string) []chatMessage {
+	messages := []chatMessage{}
+	if instructions != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: instructions})
+	}
+	for _, turn := range turns {
+		if turn.Content == "" {
+			continue
+		}
+		messages = append(messages, chatMessage{Role: string(turn.Role), Content: turn.Content})
+	}
+	return append(messages, chatMessage{Role: "user", Content: prompt})
+}
+
+type chatRequest struct {
+	Model          string              `json:"model,omitempty"`
+	Messages       []chatMessage       `json:"messages"`
+	ResponseFormat *chatResponseFormat `json:"response_format,omitempty"`
+}
+
+type chatResponseFormat struct {
+	Type       string          `json:"type"`
+	JSONSchema *chatJSONSchema `json:"json_schema,omitempty"`
+}
+
+type chatJSONSchema struct {
+	Name   string             `json:"name"`
+	Schema *jsonschema.Schema `json:"schema"`
+	Strict bool               `json:"strict"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
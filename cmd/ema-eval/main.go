@@ -0,0 +1,158 @@
+// Command ema-eval evaluates parts of the EMA pipeline against labelled
+// datasets.
+//
+// Usage:
+//
+//	ema-eval interruptions -dataset cases.jsonl [flags]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/template"
+
+	"github.com/koscakluka/ema-core/core/interruptions/llm"
+	"github.com/koscakluka/ema-core/core/interruptions/llm/eval"
+	"github.com/koscakluka/ema-core/core/llms/groq"
+	"github.com/koscakluka/ema-core/core/llms/openai"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "interruptions":
+		if err := evaluateInterruptions(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: ema-eval interruptions -dataset cases.jsonl [flags]")
+}
+
+func evaluateInterruptions(args []string) error {
+	flags := flag.NewFlagSet("interruptions", flag.ExitOnError)
+	datasetPath := flags.String("dataset", "", "path to the JSONL dataset (required)")
+	provider := flags.String("provider", "compatible", "classifier provider: compatible, groq or openai")
+	model := flags.String("model", "", "model name, provider specific")
+	mode := flags.String("mode", "structured", "prompting mode for the compatible provider: structured or general")
+	baseURL := flags.String("base-url", "http://localhost:8080/v1", "base URL of the OpenAI compatible API")
+	apiKey := flags.String("api-key", os.Getenv("EMA_EVAL_API_KEY"), "API key of the OpenAI compatible API")
+	promptPath := flags.String("prompt", "", "classifier system prompt template, built-in one is used if empty")
+	label := flags.String("label", "", "label stored in the report, e.g. prompt version")
+	outPath := flags.String("out", "", "path to save the JSON report to")
+	baselinePath := flags.String("baseline", "", "path to a previous JSON report to compare against")
+	flags.Parse(args)
+
+	if *datasetPath == "" {
+		flags.Usage()
+		return fmt.Errorf("dataset is required")
+	}
+
+	cases, err := eval.LoadDataset(*datasetPath)
+	if err != nil {
+		return err
+	}
+
+	classifier, err := newClassifier(*provider, *model, *mode, *baseURL, *apiKey)
+	if err != nil {
+		return err
+	}
+
+	classifyOptions := []llm.ClassifyOption{}
+	if *promptPath != "" {
+		tmpl, err := template.ParseFiles(*promptPath)
+		if err != nil {
+			return fmt.Errorf("failed to parse prompt template: %w", err)
+		}
+		classifyOptions = append(classifyOptions, llm.WithSystemPromptTemplate(tmpl))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	report := eval.NewReport(*label, eval.Run(ctx, cases, classifier, classifyOptions...))
+	report.Print(os.Stdout)
+
+	if *baselinePath != "" {
+		baseline, err := eval.LoadReport(*baselinePath)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		report.PrintDiff(os.Stdout, *baseline)
+	}
+
+	if *outPath != "" {
+		if err := report.Save(*outPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newClassifier(provider, model, mode, baseURL, apiKey string) (llm.LLM, error) {
+	switch provider {
+	case "compatible":
+		client := newCompatibleClient(baseURL, apiKey, model)
+		switch mode {
+		case "structured":
+			return structuredCompatibleClient{client}, nil
+		case "general":
+			return generalCompatibleClient{client}, nil
+		default:
+			return nil, fmt.Errorf("unknown mode: %s", mode)
+		}
+
+	case "groq":
+		switch groq.ChatModel(model) {
+		case groq.ModelGPTOSS20B, "":
+			return asClassifier(groq.NewGPTOSS20BClient())
+		case groq.ModelGPTOSS120B:
+			return asClassifier(groq.NewGPTOSS120BClient())
+		case groq.ModelLlama4Maverick17BInstruct:
+			return asClassifier(groq.NewLlama4Maverick17BInstructClient())
+		case groq.ModelLlama4Scout17BInstruct:
+			return asClassifier(groq.NewLlama4Scout17BInstructClient())
+		case groq.ModelKimiK2Instruct0905:
+			return asClassifier(groq.NewKimiK2Instruct0905Client())
+		default:
+			return nil, fmt.Errorf("groq model %q does not support structured prompts", model)
+		}
+
+	case "openai":
+		switch openai.ChatModel(model) {
+		case openai.ModelGPT5Nano, "":
+			return asClassifier(openai.NewGPT5NanoClient())
+		case openai.ModelGPT41:
+			return asClassifier(openai.NewGPT41Client())
+		case openai.ModelGPT4o:
+			return asClassifier(openai.NewGPT4oClient())
+		default:
+			return nil, fmt.Errorf("unknown openai model %q", model)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown provider: %s", provider)
+	}
+}
+
+func asClassifier[T any](client *T, err error) (llm.LLM, error) {
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,163 @@
+// Command ema-phone makes EMA reachable by phone through Twilio Media Streams.
+// Point the voice webhook of a Twilio number to /twiml on a public HTTPS
+// address of the server. Requests are validated with the account's auth token
+// from TWILIO_AUTH_TOKEN.
+//
+// Usage:
+//
+//	ema-phone [-addr :8080] [-stream-url wss://example.com/media]
+//	ema-phone -test-call caller.wav [-test-output assistant.wav]
+//
+// With -test-call the server calls itself with a fake Twilio client that
+// streams the WAV file as the caller and records what it hears, so the bridge
+// can be tried without Twilio.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	orchestration "github.com/koscakluka/ema-core/core"
+	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/audio/convert"
+	"github.com/koscakluka/ema-core/core/audio/wav"
+	llmInterruptionsHandler "github.com/koscakluka/ema-core/core/interruptions/llm"
+	rulesInterruptionsHandler "github.com/koscakluka/ema-core/core/interruptions/rules"
+	"github.com/koscakluka/ema-core/core/llms/groq"
+	deepgramstt "github.com/koscakluka/ema-core/core/speechtotext/deepgram"
+	deepgramt2s "github.com/koscakluka/ema-core/core/texttospeech/deepgram"
+	"github.com/koscakluka/ema-core/core/transport/twilio"
+	"github.com/koscakluka/ema-core/core/transport/twilio/twiliotest"
+)
+
+// testCallReplyTime is how long the test call stays open after the caller's
+// audio ends, so the assistant can answer
+const testCallReplyTime = 15 * time.Second
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	streamURL := flag.String("stream-url", "", "public WebSocket URL of the media stream, derived from the webhook request if empty")
+	testCall := flag.String("test-call", "", "WAV file to stream as the caller of a fake local call")
+	testOutput := flag.String("test-output", "assistant.wav", "WAV file the assistant's audio of the fake call is written to")
+	flag.Parse()
+
+	if err := run(*addr, *streamURL, *testCall, *testOutput); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(addr, streamURL, testCall, testOutput string) error {
+	llm, err := groq.NewLlama3370BVersatileClient()
+	if err != nil {
+		return fmt.Errorf("failed to create groq client: %w", err)
+	}
+	structuredLlm, err := groq.NewGPTOSS20BClient()
+	if err != nil {
+		return fmt.Errorf("failed to create structured groq client: %w", err)
+	}
+
+	serverOptions := []twilio.ServerOption{}
+	if streamURL != "" {
+		serverOptions = append(serverOptions, twilio.WithStreamURL(streamURL))
+	}
+	server := twilio.NewServer(func(ctx context.Context, call *twilio.Call) error {
+		log.Printf("Call %s started", call.CallSID())
+		defer log.Printf("Call %s ended", call.CallSID())
+
+		speechToTextClient := deepgramstt.NewClient(ctx)
+		textToSpeechClient, err := deepgramt2s.NewTextToSpeechClient(ctx, deepgramt2s.VoiceAuraAsteria)
+		if err != nil {
+			speechToTextClient.Close()
+			return fmt.Errorf("failed to create deepgram speech client: %w", err)
+		}
+
+		orchestrator := orchestration.NewOrchestrator(
+			orchestration.WithStreamingLLM(llm),
+			orchestration.WithSpeechToTextClient(speechToTextClient),
+			orchestration.WithTextToSpeechClient(textToSpeechClient),
+			orchestration.WithAudioInput(call),
+			orchestration.WithAudioOutputV1(call),
+			orchestration.WithInterruptionHandlerV1(
+				rulesInterruptionsHandler.NewInterruptionHandler(
+					rulesInterruptionsHandler.WithFallback(
+						llmInterruptionsHandler.NewInterruptionHandlerWithStructuredPrompt(structuredLlm),
+					),
+				),
+			),
+		)
+
+		err = call.Orchestrate(ctx, orchestrator)
+
+		// NOTE: The clients are closed first so they don't send anything to
+		// the closed orchestrator
+		speechToTextClient.Close()
+		textToSpeechClient.Close(context.Background())
+		orchestrator.Close()
+		return err
+	}, serverOptions...)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	if testCall == "" {
+		log.Printf("Serving Twilio media streams on %s", addr)
+		return http.Serve(listener, server)
+	}
+
+	go http.Serve(listener, server)
+	return runTestCall(fmt.Sprintf("ws://%s/media", listener.Addr()), testCall, testOutput)
+}
+
+// runTestCall calls the server with a fake Twilio client, streaming the WAV
+// file as the caller and recording the assistant
+func runTestCall(url, inputPath, outputPath string) error {
+	phoneEncoding := audio.EncodingInfo{SampleRate: 8000, Encoding: audio.EncodingMulaw, Channels: 1}
+
+	input, err := wav.NewInput(inputPath, wav.WithTrailingSilence(testCallReplyTime))
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	converter, err := convert.NewConverter(input.EncodingInfo(), phoneEncoding)
+	if err != nil {
+		return err
+	}
+
+	output, err := wav.NewOutput(outputPath, phoneEncoding)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	client, err := twiliotest.Dial(context.Background(), url,
+		twiliotest.WithAuthToken(os.Getenv("TWILIO_AUTH_TOKEN")),
+		twiliotest.WithAudioCallback(func(audio []byte) {
+			if err := output.SendAudio(audio); err != nil {
+				log.Printf("Failed to record assistant audio: %v", err)
+			}
+		}),
+		twiliotest.WithClearCallback(output.ClearBuffer),
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Calling with %s, recording the assistant to %s", inputPath, outputPath)
+	if err := input.Stream(context.Background(), func(audio []byte) {
+		if err := client.SendAudio(converter.Convert(audio)); err != nil {
+			log.Printf("Failed to send caller audio: %v", err)
+		}
+	}); err != nil {
+		return err
+	}
+	return client.Hangup()
+}
@@ -0,0 +1,98 @@
+// Command ema-server exposes EMA sessions to other services over HTTP, see
+// the core/server package for the routes.
+//
+// Usage:
+//
+//	ema-server [-addr :8081] [-max-sessions 16]
+//
+// Sessions are text only, prompts are sent over HTTP and responses are
+// streamed back as server-sent events or over a WebSocket. The "groq" and
+// "groq-gpt-oss" profiles are always available, "openai" only when
+// OPENAI_API_KEY is set.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	orchestration "github.com/koscakluka/ema-core/core"
+	"github.com/koscakluka/ema-core/core/llms/groq"
+	"github.com/koscakluka/ema-core/core/llms/openai"
+	"github.com/koscakluka/ema-core/core/server"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	maxSessions := flag.Int("max-sessions", 16, "maximum number of concurrent sessions, 0 for no limit")
+	flag.Parse()
+
+	if err := run(*addr, *maxSessions); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(addr string, maxSessions int) error {
+	llama, err := groq.NewLlama3370BVersatileClient()
+	if err != nil {
+		return fmt.Errorf("failed to create groq client: %w", err)
+	}
+	gptOSS, err := groq.NewGPTOSS120BClient()
+	if err != nil {
+		return fmt.Errorf("failed to create groq gpt-oss client: %w", err)
+	}
+
+	opts := []server.ServerOption{
+		server.WithProfile("groq", textProfile(llama)),
+		server.WithProfile("groq-gpt-oss", textProfile(gptOSS)),
+		server.WithMaxSessions(maxSessions),
+	}
+	if os.Getenv("OPENAI_API_KEY") != "" {
+		gpt41, err := openai.NewGPT41Client()
+		if err != nil {
+			return fmt.Errorf("failed to create openai client: %w", err)
+		}
+		opts = append(opts, server.WithProfile("openai", textProfile(gpt41)))
+	}
+
+	apiServer := server.NewServer(opts...)
+	httpServer := &http.Server{Addr: addr, Handler: apiServer}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		// NOTE: Sessions are closed first so their event streams end and
+		// the shutdown doesn't wait on them
+		apiServer.Close()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shut down server: %v", err)
+		}
+	}()
+
+	log.Printf("Serving EMA API on %s", addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// textProfile creates text only sessions using the llm
+func textProfile(llm orchestration.LLMWithStream) server.OrchestratorFactory {
+	return func(ctx context.Context) (*orchestration.Orchestrator, func(), error) {
+		orchestrator := orchestration.NewOrchestrator(
+			orchestration.WithStreamingLLM(llm),
+			orchestration.WithOrchestrationTools(),
+		)
+		return orchestrator, orchestrator.Close, nil
+	}
+}
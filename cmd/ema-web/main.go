@@ -0,0 +1,88 @@
+// Command ema-web serves EMA to browsers, every connected browser talks to
+// its own assistant through the microphone.
+//
+// Usage:
+//
+//	ema-web [-addr :8080] [-cert cert.pem -key key.pem]
+//
+// Browsers only allow microphone access on secure origins, use -cert and -key
+// (or a tunnel providing HTTPS) to talk to it from a phone.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	orchestration "github.com/koscakluka/ema-core/core"
+	llmInterruptionsHandler "github.com/koscakluka/ema-core/core/interruptions/llm"
+	rulesInterruptionsHandler "github.com/koscakluka/ema-core/core/interruptions/rules"
+	"github.com/koscakluka/ema-core/core/llms/groq"
+	deepgramstt "github.com/koscakluka/ema-core/core/speechtotext/deepgram"
+	deepgramt2s "github.com/koscakluka/ema-core/core/texttospeech/deepgram"
+	"github.com/koscakluka/ema-core/core/transport/websocket"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	certFile := flag.String("cert", "", "TLS certificate file, serves HTTPS if set with -key")
+	keyFile := flag.String("key", "", "TLS key file")
+	flag.Parse()
+
+	llm, err := groq.NewLlama3370BVersatileClient()
+	if err != nil {
+		log.Fatalf("Failed to create groq client: %v", err)
+	}
+	structuredLlm, err := groq.NewGPTOSS20BClient()
+	if err != nil {
+		log.Fatalf("Failed to create structured groq client: %v", err)
+	}
+
+	server := websocket.NewServer(func(ctx context.Context, conn *websocket.Conn) error {
+		speechToTextClient := deepgramstt.NewClient(ctx)
+		textToSpeechClient, err := deepgramt2s.NewTextToSpeechClient(ctx, deepgramt2s.VoiceAuraAsteria)
+		if err != nil {
+			speechToTextClient.Close()
+			return fmt.Errorf("failed to create deepgram speech client: %w", err)
+		}
+
+		orchestrator := orchestration.NewOrchestrator(
+			orchestration.WithStreamingLLM(llm),
+			orchestration.WithSpeechToTextClient(speechToTextClient),
+			orchestration.WithTextToSpeechClient(textToSpeechClient),
+			orchestration.WithAudioInput(conn),
+			orchestration.WithAudioOutputV1(conn),
+			orchestration.WithOrchestrationTools(),
+			orchestration.WithInterruptionHandlerV1(
+				rulesInterruptionsHandler.NewInterruptionHandler(
+					rulesInterruptionsHandler.WithFallback(
+						llmInterruptionsHandler.NewInterruptionHandlerWithStructuredPrompt(structuredLlm),
+					),
+				),
+			),
+		)
+
+		err = conn.Orchestrate(ctx, orchestrator)
+
+		// NOTE: The clients are closed first so they don't send anything to
+		// the closed orchestrator
+		speechToTextClient.Close()
+		textToSpeechClient.Close(context.Background())
+		orchestrator.Close()
+		return err
+	})
+
+	log.Printf("Serving EMA on %s", *addr)
+	if *certFile != "" && *keyFile != "" {
+		err = http.ListenAndServeTLS(*addr, *certFile, *keyFile, server)
+	} else {
+		err = http.ListenAndServe(*addr, server)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
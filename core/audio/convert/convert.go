@@ -0,0 +1,228 @@
+// Package convert converts raw audio between encodings, sample rates and
+// channel layouts.
+//
+// Conversion goes through mono or multichannel float samples: the audio is
+// decoded, its channels are mixed to the target layout, it is low-pass
+// filtered below the target Nyquist frequency when downsampling so higher
+// frequencies don't alias, resampled with linear interpolation and encoded
+// again. Converters keep the state needed to
+// convert a stream chunk by chunk without clicks at chunk boundaries.
+package convert
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/koscakluka/ema-core/core/audio"
+)
+
+// antiAliasCutoff is the cutoff of the anti-aliasing filter relative to the
+// Nyquist frequency of the target sample rate
+const antiAliasCutoff = 0.9
+
+// Converter converts a stream of audio chunks from one encoding to another.
+// It is not safe for concurrent use.
+type Converter struct {
+	from audio.EncodingInfo
+	to   audio.EncodingInfo
+
+	// remainder holds the bytes of an incomplete frame from the last chunk
+	remainder []byte
+	// previous is the last input frame, after mixing, used to interpolate
+	// across chunk boundaries
+	previous []float64
+	// position is the position of the next output frame, in input frames,
+	// relative to the first frame of the next chunk
+	position float64
+	// antiAlias filters the audio before it is downsampled, nil when the
+	// audio isn't downsampled
+	antiAlias *lowPass
+}
+
+// NewConverter returns a converter between the encodings, or an error if
+// either of them is not supported
+func NewConverter(from, to audio.EncodingInfo) (*Converter, error) {
+	for _, info := range []audio.EncodingInfo{from, to} {
+		if info.Encoding.BytesPerSample() == 0 {
+			return nil, fmt.Errorf("unsupported encoding %q", info.Encoding)
+		}
+		if info.SampleRate <= 0 {
+			return nil, fmt.Errorf("invalid sample rate %d", info.SampleRate)
+		}
+	}
+
+	converter := &Converter{from: from, to: to}
+	if to.SampleRate < from.SampleRate {
+		// NOTE: The cutoff is a bit below the Nyquist frequency since the
+		// filter doesn't cut off sharply
+		cutoff := antiAliasCutoff * float64(to.SampleRate) / 2
+		converter.antiAlias = newLowPass(from.SampleRate, to.ChannelCount(), cutoff)
+	}
+	return converter, nil
+}
+
+// From returns the encoding of the converted audio
+func (c *Converter) From() audio.EncodingInfo { return c.from }
+
+// To returns the encoding the audio is converted to
+func (c *Converter) To() audio.EncodingInfo { return c.to }
+
+// Convert converts the next chunk of the stream
+func (c *Converter) Convert(chunk []byte) []byte {
+	if c.from.Matches(c.to) {
+		return chunk
+	}
+
+	if len(c.remainder) > 0 {
+		chunk = append(c.remainder, chunk...)
+		c.remainder = nil
+	}
+	frameSize := c.from.BytesPerFrame()
+	if excess := len(chunk) % frameSize; excess > 0 {
+		c.remainder = append([]byte{}, chunk[len(chunk)-excess:]...)
+		chunk = chunk[:len(chunk)-excess]
+	}
+
+	samples := Decode(chunk, c.from.Encoding)
+	samples = MixChannels(samples, c.from.ChannelCount(), c.to.ChannelCount())
+	if c.antiAlias != nil {
+		c.antiAlias.filter(samples)
+	}
+	samples = c.resample(samples)
+	return Encode(samples, c.to.Encoding)
+}
+
+// Reset drops the state kept between chunks, it should be called when the
+// stream is interrupted
+func (c *Converter) Reset() {
+	c.remainder = nil
+	c.previous = nil
+	c.position = 0
+	if c.antiAlias != nil {
+		c.antiAlias.reset()
+	}
+}
+
+// resample resamples interleaved frames with linear interpolation, carrying
+// the last frame over to the next chunk
+func (c *Converter) resample(samples []float64) []float64 {
+	if c.from.SampleRate == c.to.SampleRate {
+		return samples
+	}
+
+	channels := c.to.ChannelCount()
+	frames := len(samples) / channels
+	if frames == 0 {
+		return nil
+	}
+	frameAt := func(index int) []float64 {
+		if index < 0 {
+			if c.previous == nil {
+				return samples[:channels]
+			}
+			return c.previous
+		}
+		return samples[index*channels : (index+1)*channels]
+	}
+
+	step := float64(c.from.SampleRate) / float64(c.to.SampleRate)
+	resampled := make([]float64, 0, int(float64(frames)/step+1)*channels)
+	// NOTE: Position -1 is the previous chunk's last frame, so output is
+	// produced up to the last frame and the rest is interpolated once the
+	// next chunk arrives
+	for ; c.position < float64(frames-1); c.position += step {
+		index := int(math.Floor(c.position))
+		fraction := c.position - float64(index)
+		current, next := frameAt(index), frameAt(index+1)
+		for channel := range channels {
+			resampled = append(resampled, current[channel]*(1-fraction)+next[channel]*fraction)
+		}
+	}
+	c.position -= float64(frames)
+	c.previous = append(c.previous[:0], samples[(frames-1)*channels:]...)
+
+	return resampled
+}
+
+// Decode decodes audio to interleaved float samples between -1 and 1
+func Decode(data []byte, encoding audio.Encoding) []float64 {
+	size := encoding.BytesPerSample()
+	if size == 0 {
+		return nil
+	}
+
+	samples := make([]float64, len(data)/size)
+	for i := range samples {
+		sample := data[i*size:]
+		switch encoding {
+		case audio.EncodingLinear16:
+			samples[i] = float64(int16(binary.LittleEndian.Uint16(sample))) / 32768
+		case audio.EncodingMulaw:
+			samples[i] = float64(MulawDecode(sample[0])) / 32768
+		case audio.EncodingAlaw:
+			samples[i] = float64(AlawDecode(sample[0])) / 32768
+		case audio.EncodingFloat32:
+			samples[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(sample)))
+		}
+	}
+	return samples
+}
+
+// Encode encodes interleaved float samples between -1 and 1, clipping the
+// samples outside of the range
+func Encode(samples []float64, encoding audio.Encoding) []byte {
+	size := encoding.BytesPerSample()
+	data := make([]byte, len(samples)*size)
+	for i, sample := range samples {
+		sample = max(min(sample, 1), -1)
+		switch encoding {
+		case audio.EncodingLinear16:
+			binary.LittleEndian.PutUint16(data[i*size:], uint16(toInt16(sample)))
+		case audio.EncodingMulaw:
+			data[i] = MulawEncode(toInt16(sample))
+		case audio.EncodingAlaw:
+			data[i] = AlawEncode(toInt16(sample))
+		case audio.EncodingFloat32:
+			binary.LittleEndian.PutUint32(data[i*size:], math.Float32bits(float32(sample)))
+		}
+	}
+	return data
+}
+
+// MixChannels converts interleaved samples to another number of channels.
+// Mixing down averages the channels, mixing up from mono copies the channel
+// and any other change maps the channels in order, repeating the last one.
+func MixChannels(samples []float64, from, to int) []float64 {
+	if from == to || from <= 0 || to <= 0 {
+		return samples
+	}
+
+	frames := len(samples) / from
+	mixed := make([]float64, frames*to)
+	for frame := range frames {
+		input := samples[frame*from : (frame+1)*from]
+		output := mixed[frame*to : (frame+1)*to]
+		if to == 1 {
+			sum := 0.0
+			for _, sample := range input {
+				sum += sample
+			}
+			output[0] = sum / float64(from)
+			continue
+		}
+		for channel := range output {
+			output[channel] = input[min(channel, from-1)]
+		}
+	}
+	return mixed
+}
+
+// Silence returns the byte pattern of a silent sample in the encoding
+func Silence(encoding audio.Encoding) []byte {
+	return Encode([]float64{0}, encoding)
+}
+
+func toInt16(sample float64) int16 {
+	return int16(math.Round(max(min(sample*32768, math.MaxInt16), math.MinInt16)))
+}
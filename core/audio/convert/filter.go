@@ -0,0 +1,64 @@
+package convert
+
+import "math"
+
+// butterworthQ are the quality factors of the biquads of a 4th order
+// Butterworth low-pass filter
+var butterworthQ = [...]float64{0.54119610, 1.30656296}
+
+// lowPass is a 4th order Butterworth low-pass filter made of cascaded
+// biquads, it keeps the state of every channel between chunks
+type lowPass struct {
+	stages []biquad
+}
+
+// biquad is a second order filter section
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	// state holds the last two inputs and outputs of every channel
+	state [][4]float64
+}
+
+// newLowPass returns a low-pass filter for interleaved audio with the cutoff
+// frequency in Hz
+func newLowPass(sampleRate, channels int, cutoff float64) *lowPass {
+	omega := 2 * math.Pi * cutoff / float64(sampleRate)
+	sin, cos := math.Sincos(omega)
+
+	filter := &lowPass{}
+	for _, q := range butterworthQ {
+		alpha := sin / (2 * q)
+		a0 := 1 + alpha
+		filter.stages = append(filter.stages, biquad{
+			b0:    (1 - cos) / 2 / a0,
+			b1:    (1 - cos) / a0,
+			b2:    (1 - cos) / 2 / a0,
+			a1:    -2 * cos / a0,
+			a2:    (1 - alpha) / a0,
+			state: make([][4]float64, channels),
+		})
+	}
+	return filter
+}
+
+// filter filters interleaved samples in place
+func (f *lowPass) filter(samples []float64) {
+	for i := range f.stages {
+		stage := &f.stages[i]
+		channels := len(stage.state)
+		for j, x := range samples {
+			s := &stage.state[j%channels]
+			y := stage.b0*x + stage.b1*s[0] + stage.b2*s[1] - stage.a1*s[2] - stage.a2*s[3]
+			s[1], s[0] = s[0], x
+			s[3], s[2] = s[2], y
+			samples[j] = y
+		}
+	}
+}
+
+// reset clears the state kept between chunks
+func (f *lowPass) reset() {
+	for i := range f.stages {
+		clear(f.stages[i].state)
+	}
+}
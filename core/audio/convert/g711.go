@@ -0,0 +1,83 @@
+package convert
+
+// G.711 companding as described in ITU-T G.711, working on 16-bit linear
+// samples
+
+const (
+	mulawBias = 0x84
+	mulawClip = 32635
+)
+
+// MulawEncode compresses a linear16 sample to mu-law
+func MulawEncode(sample int16) byte {
+	value := int(sample)
+	sign := 0
+	if value < 0 {
+		value = -value
+		sign = 0x80
+	}
+	value = min(value, mulawClip) + mulawBias
+
+	exponent := 7
+	for mask := 0x4000; value&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (value >> (exponent + 3)) & 0x0F
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+// MulawDecode expands a mu-law sample to linear16
+func MulawDecode(encoded byte) int16 {
+	encoded = ^encoded
+	exponent := int(encoded>>4) & 0x07
+	mantissa := int(encoded) & 0x0F
+	value := ((mantissa << 3) + mulawBias) << exponent
+	value -= mulawBias
+	if encoded&0x80 != 0 {
+		return int16(-value)
+	}
+	return int16(value)
+}
+
+// AlawEncode compresses a linear16 sample to A-law
+func AlawEncode(sample int16) byte {
+	value := int(sample) >> 3
+	sign := 0x80
+	if value < 0 {
+		value = -value - 1
+		sign = 0
+	}
+
+	var encoded int
+	if value < 32 {
+		encoded = value >> 1
+	} else {
+		exponent := 1
+		for v := value >> 5; v > 1 && exponent < 7; v >>= 1 {
+			exponent++
+		}
+		encoded = exponent<<4 | (value>>exponent)&0x0F
+		if value >= 4096 {
+			encoded = 0x7F
+		}
+	}
+	return byte(sign|encoded) ^ 0x55
+}
+
+// AlawDecode expands an A-law sample to linear16
+func AlawDecode(encoded byte) int16 {
+	encoded ^= 0x55
+	exponent := int(encoded>>4) & 0x07
+	mantissa := int(encoded) & 0x0F
+
+	var value int
+	if exponent == 0 {
+		value = mantissa<<4 + 8
+	} else {
+		value = (mantissa<<4 + 0x108) << (exponent - 1)
+	}
+	if encoded&0x80 == 0 {
+		return int16(-value)
+	}
+	return int16(value)
+}
@@ -0,0 +1,240 @@
+// Package echo removes the echo of played audio from captured linear16 mono
+// audio.
+//
+// The canceller is given the audio sent to the output as the reference
+// signal. It assumes the output plays the audio in real time as soon as
+// everything sent before it was played, which holds for buffered outputs, and
+// lines the reference up with the captured audio by wall clock time. An NLMS
+// adaptive filter then estimates the echo path and subtracts the estimated
+// echo from the captured audio.
+package echo
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFilterLength = 32 * time.Millisecond
+	defaultStepSize     = 0.3
+	defaultDelay        = 40 * time.Millisecond
+	// doubleTalkRatio is the Geigel detector threshold, adaptation stops when
+	// the captured audio is louder than this part of the recent reference,
+	// which means the user is talking over the output
+	doubleTalkRatio = 0.5
+	// regularization keeps the step size bounded when the reference is silent
+	regularization = 1e3
+	// resyncThreshold is how far the captured sample count can drift from
+	// the wall clock before it is realigned
+	resyncThreshold = 20 * time.Millisecond
+)
+
+// Canceller is an acoustic echo canceller, it is safe for concurrent use
+type Canceller struct {
+	sampleRate int
+	taps       int
+	stepSize   float64
+	delay      time.Duration
+
+	weights []float64
+
+	// reference holds the played samples, reference[0] is played at
+	// referenceStart samples after origin
+	origin         time.Time
+	reference      []float64
+	referenceStart int64
+	// playbackEnd is the sample index at which the output runs out of audio
+	playbackEnd int64
+	// captureEnd is the sample index of the end of the last captured chunk,
+	// it is advanced by the captured samples so scheduling jitter of the
+	// capture callbacks doesn't misalign the filter
+	captureEnd int64
+
+	mu sync.Mutex
+}
+
+func NewCanceller(sampleRate int, opts ...Option) *Canceller {
+	canceller := &Canceller{
+		sampleRate: sampleRate,
+		stepSize:   defaultStepSize,
+		delay:      defaultDelay,
+		origin:     time.Now(),
+	}
+	canceller.taps = toSamples(defaultFilterLength, sampleRate)
+	for _, opt := range opts {
+		opt(canceller)
+	}
+	canceller.weights = make([]float64, canceller.taps)
+
+	return canceller
+}
+
+type Option func(*Canceller)
+
+// WithFilterLength sets the longest echo tail that is cancelled, longer
+// filters cancel more reverberation but cost more CPU
+func WithFilterLength(length time.Duration) Option {
+	return func(c *Canceller) {
+		c.taps = max(toSamples(length, c.sampleRate), 1)
+	}
+}
+
+// WithStepSize sets how fast the filter adapts to changes of the echo path,
+// between 0 and 2, higher values adapt faster but are less stable
+func WithStepSize(stepSize float64) Option {
+	return func(c *Canceller) {
+		c.stepSize = stepSize
+	}
+}
+
+// WithDelay sets the combined output and input latency, the time between
+// the audio starting to play and it showing up in the captured audio
+func WithDelay(delay time.Duration) Option {
+	return func(c *Canceller) {
+		c.delay = delay
+	}
+}
+
+// AddReference adds audio sent to the output, it is scheduled to play after
+// everything added before it
+func (c *Canceller) AddReference(audio []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.sampleIndex(time.Now())
+	start := max(now, c.playbackEnd)
+	referenceEnd := c.referenceStart + int64(len(c.reference))
+	if len(c.reference) == 0 {
+		c.referenceStart = start
+		referenceEnd = start
+	}
+	for ; referenceEnd < start; referenceEnd++ {
+		c.reference = append(c.reference, 0)
+	}
+
+	for i := 0; i+1 < len(audio); i += 2 {
+		c.reference = append(c.reference, float64(int16(binary.LittleEndian.Uint16(audio[i:]))))
+	}
+	c.playbackEnd = start + int64(len(audio)/2)
+}
+
+// ClearReference drops the audio that was not played yet, it should be
+// called whenever the output's buffer is cleared
+func (c *Canceller) ClearReference() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.sampleIndex(time.Now())
+	if played := now - c.referenceStart; played < int64(len(c.reference)) {
+		c.reference = c.reference[:max(played, 0)]
+	}
+	c.playbackEnd = min(c.playbackEnd, now)
+}
+
+// Process removes the echo from the audio that was just captured and returns
+// the cleaned audio
+func (c *Canceller) Process(audio []byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	samples := len(audio) / 2
+	now := c.sampleIndex(time.Now())
+	c.captureEnd += int64(samples)
+	if drift := now - c.captureEnd; max(drift, -drift) > int64(toSamples(resyncThreshold, c.sampleRate)) {
+		c.captureEnd = now
+	}
+	// NOTE: The chunk was captured over the time leading up to its end, echo
+	// in it was played delay earlier
+	start := c.captureEnd - int64(samples) - int64(toSamples(c.delay, c.sampleRate))
+	c.trimReference(start - int64(c.taps))
+
+	if !c.hasReference(start-int64(c.taps), start+int64(samples)) {
+		return audio
+	}
+	return c.cancel(audio, start)
+}
+
+// cancel filters the audio whose first sample lines up with the reference
+// sample at start
+func (c *Canceller) cancel(audio []byte, start int64) []byte {
+	samples := len(audio) / 2
+
+	window := make([]float64, c.taps)
+	energy := 0.0
+	for i := range c.taps {
+		window[i] = c.referenceAt(start - int64(i))
+		energy += window[i] * window[i]
+	}
+	peak := 0.0
+	for _, sample := range window {
+		peak = max(peak, math.Abs(sample))
+	}
+
+	cleaned := make([]byte, len(audio))
+	for n := range samples {
+		if n > 0 {
+			old := window[c.taps-1]
+			copy(window[1:], window[:c.taps-1])
+			window[0] = c.referenceAt(start + int64(n))
+			energy += window[0]*window[0] - old*old
+			peak = max(peak, math.Abs(window[0]))
+		}
+
+		captured := float64(int16(binary.LittleEndian.Uint16(audio[n*2:])))
+		estimate := 0.0
+		for i, weight := range c.weights {
+			estimate += weight * window[i]
+		}
+		residual := captured - estimate
+
+		doubleTalk := math.Abs(captured) > doubleTalkRatio*peak
+		if !doubleTalk {
+			step := c.stepSize * residual / (max(energy, 0) + regularization)
+			for i := range c.weights {
+				c.weights[i] += step * window[i]
+			}
+		}
+
+		binary.LittleEndian.PutUint16(cleaned[n*2:], uint16(int16(max(min(residual, math.MaxInt16), math.MinInt16))))
+	}
+
+	return cleaned
+}
+
+func (c *Canceller) sampleIndex(t time.Time) int64 {
+	return int64(t.Sub(c.origin).Seconds() * float64(c.sampleRate))
+}
+
+func (c *Canceller) referenceAt(index int64) float64 {
+	i := index - c.referenceStart
+	if i < 0 || i >= int64(len(c.reference)) {
+		return 0
+	}
+	return c.reference[i]
+}
+
+func (c *Canceller) hasReference(from, to int64) bool {
+	return len(c.reference) > 0 &&
+		from < c.referenceStart+int64(len(c.reference)) && to > c.referenceStart
+}
+
+// trimReference drops the reference samples before the index
+func (c *Canceller) trimReference(index int64) {
+	drop := index - c.referenceStart
+	if drop <= 0 {
+		return
+	}
+	if drop >= int64(len(c.reference)) {
+		c.reference = c.reference[:0]
+		c.referenceStart = index
+		return
+	}
+	c.reference = c.reference[drop:]
+	c.referenceStart = index
+}
+
+func toSamples(duration time.Duration, sampleRate int) int {
+	return int(duration.Seconds() * float64(sampleRate))
+}
@@ -1,6 +1,61 @@
 package audio
 
+// Encoding is the format of a single audio sample
+type Encoding string
+
+const (
+	// EncodingLinear16 is signed 16-bit little-endian PCM
+	EncodingLinear16 Encoding = "linear16"
+	// EncodingMulaw is 8-bit G.711 mu-law
+	EncodingMulaw Encoding = "mulaw"
+	// EncodingAlaw is 8-bit G.711 A-law
+	EncodingAlaw Encoding = "alaw"
+	// EncodingFloat32 is 32-bit little-endian IEEE float PCM between -1 and 1
+	EncodingFloat32 Encoding = "float32"
+)
+
+// BytesPerSample returns the size of a single sample, zero for unknown
+// encodings
+func (e Encoding) BytesPerSample() int {
+	switch e {
+	case EncodingLinear16:
+		return 2
+	case EncodingMulaw, EncodingAlaw:
+		return 1
+	case EncodingFloat32:
+		return 4
+	default:
+		return 0
+	}
+}
+
 type EncodingInfo struct {
 	SampleRate int
-	Encoding   string // TODO: Make into an enum
+	Encoding   Encoding
+	// Channels is the number of interleaved channels, zero is treated as mono
+	Channels int
+}
+
+// ChannelCount returns the number of channels, treating zero as mono
+func (e EncodingInfo) ChannelCount() int {
+	return max(e.Channels, 1)
+}
+
+// BytesPerFrame returns the size of one sample for every channel
+func (e EncodingInfo) BytesPerFrame() int {
+	return e.Encoding.BytesPerSample() * e.ChannelCount()
+}
+
+// Matches reports whether audio in both encodings can be used without
+// conversion
+func (e EncodingInfo) Matches(other EncodingInfo) bool {
+	return e.SampleRate == other.SampleRate &&
+		e.Encoding == other.Encoding &&
+		e.ChannelCount() == other.ChannelCount()
+}
+
+// Mono returns the linear16 mono encoding at the same sample rate, the format
+// local audio processing works with
+func (e EncodingInfo) Mono() EncodingInfo {
+	return EncodingInfo{SampleRate: e.SampleRate, Encoding: EncodingLinear16, Channels: 1}
 }
@@ -3,6 +3,7 @@ package miniaudio
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/gen2brain/malgo"
 )
@@ -10,35 +11,41 @@ import (
 type captureClient struct {
 	audioContext *malgo.AllocatedContext
 	device       *malgo.Device
-	config       malgo.DeviceConfig
+	options      deviceOptions
 
 	onAudio func(audio []byte) // TODO: Consider a situation where there might be mutliple listeners
 
+	// started is set while capture should be running, so a reopened device
+	// is started again
+	started bool
+	closed  bool
+	// stopping is set while the device is stopped on purpose, any other stop
+	// means the device was lost
+	stopping  atomic.Bool
+	reopening atomic.Bool
+
 	mu sync.Mutex
 }
 
-func (c *captureClient) Init(audioContext *malgo.AllocatedContext) error {
+func (c *captureClient) Init(audioContext *malgo.AllocatedContext, options deviceOptions) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	sampleRate := uint32(sampleRate)
-	channels := 1
-	format := malgo.FormatS16
-	bytesPerFrame := malgo.SampleSizeInBytes(format) * channels
+	c.audioContext = audioContext
+	c.options = options
+	return c.initDevice(false)
+}
 
-	c.config = malgo.DefaultDeviceConfig(malgo.Capture)
-	c.config.SampleRate = sampleRate
-	c.config.Capture.Format = format
-	c.config.Capture.Channels = uint32(channels)
-	c.config.Alsa.NoMMap = 1
-	c.config.PerformanceProfile = malgo.LowLatency
-	c.config.PeriodSizeInFrames = 480
-	c.config.Periods = 3
+// initDevice initializes the capture device, c.mu has to be held when calling
+// it
+func (c *captureClient) initDevice(fallback bool) error {
+	bytesPerFrame := c.options.bytesPerFrame()
 
-	c.audioContext = audioContext
+	config := c.options.config(fallback)
+	config.PerformanceProfile = malgo.LowLatency
+	config.Periods = 3
 
-	var err error
-	c.device, err = malgo.InitDevice(c.audioContext.Context, c.config, malgo.DeviceCallbacks{
+	device, err := malgo.InitDevice(c.audioContext.Context, config, malgo.DeviceCallbacks{
 		Data: func(_, pInput []byte, frameCount uint32) {
 			n := int(frameCount) * bytesPerFrame
 			if len(pInput) < n || n == 0 {
@@ -48,14 +55,29 @@ func (c *captureClient) Init(audioContext *malgo.AllocatedContext) error {
 				c.onAudio(pInput[:n])
 			}
 		},
+		Stop: c.onStopped,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to initialize capture device: %w", err)
 	}
 
+	c.device = device
 	return nil
 }
 
+// uninitDevice releases the capture device, c.mu has to be held when calling
+// it
+func (c *captureClient) uninitDevice() {
+	if c.device == nil {
+		return
+	}
+
+	c.stopping.Store(true)
+	c.device.Uninit()
+	c.stopping.Store(false)
+	c.device = nil
+}
+
 func (c *captureClient) Start(onAudio func(audio []byte)) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -70,6 +92,7 @@ func (c *captureClient) Start(onAudio func(audio []byte)) error {
 	}
 
 	c.onAudio = onAudio
+	c.started = true
 	return nil
 }
 
@@ -82,11 +105,14 @@ func (c *captureClient) Stop() error {
 		return nil
 	}
 
+	c.stopping.Store(true)
+	defer c.stopping.Store(false)
 	if err := c.device.Stop(); err != nil {
 		return fmt.Errorf("failed to stop device: %w", err)
 	}
 
 	c.onAudio = nil
+	c.started = false
 	return nil
 }
 
@@ -94,11 +120,47 @@ func (c *captureClient) Uninit() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.device != nil {
-		c.device.Uninit()
-		c.device = nil
-	}
-
+	c.uninitDevice()
 	c.onAudio = nil
+	c.started = false
+	c.closed = true
 	return nil
 }
+
+// onStopped is called by miniaudio whenever the device stops
+func (c *captureClient) onStopped() {
+	if c.stopping.Load() {
+		return
+	}
+
+	notifyDeviceEvent(c.options.onEvent, DeviceKindCapture, DeviceEventLost)
+	go c.reopen()
+}
+
+// reopen reinitializes the capture device after it was lost or the default
+// device changed, capture continues if it was running
+func (c *captureClient) reopen() {
+	if !c.reopening.CompareAndSwap(false, true) {
+		return
+	}
+	defer c.reopening.Store(false)
+
+	reopenDevice(c.options, func(fallback bool) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.closed {
+			return errDeviceClosed
+		}
+
+		c.uninitDevice()
+		if err := c.initDevice(fallback); err != nil {
+			return err
+		}
+		if c.started {
+			if err := c.device.Start(); err != nil {
+				return fmt.Errorf("failed to start capture device: %w", err)
+			}
+		}
+		return nil
+	})
+}
@@ -4,12 +4,22 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/gen2brain/malgo"
 	"github.com/koscakluka/ema-core/core/audio"
 )
 
-const sampleRate = 48000
+const (
+	defaultSampleRate = 48000
+	defaultChannels   = 1
+	// defaultPeriodSize is ~10ms at 48kHz
+	defaultPeriodSize = 480
+
+	// defaultDevicePollInterval is how often the default devices are checked
+	// for changes
+	defaultDevicePollInterval = 2 * time.Second
+)
 
 type Client struct {
 	// audioContext is only saved to be able to uninitialize it, it is an
@@ -17,23 +27,43 @@ type Client struct {
 	audioContext *malgo.AllocatedContext
 	playbackClient
 	captureClient
+
+	options ClientOptions
+	done    chan struct{}
 }
 
-func NewClient() (*Client, error) {
+func NewClient(opts ...ClientOption) (*Client, error) {
+	options := ClientOptions{
+		SampleRate:         defaultSampleRate,
+		Channels:           defaultChannels,
+		PeriodSize:         defaultPeriodSize,
+		DevicePollInterval: defaultDevicePollInterval,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	audioCtx, err := malgo.InitContext(
 		nil,
 		malgo.ContextConfig{},
 		func(message string) { log.Println("malgo:", message) },
 	)
 	if err != nil {
-		log.Fatalf("malgo InitContext failed: %v", err)
+		return nil, fmt.Errorf("failed to initialize audio context: %w", err)
 	}
 
 	client := Client{
 		audioContext: audioCtx,
+		options:      options,
+		done:         make(chan struct{}),
 	}
 
-	if err := client.playbackClient.Init(audioCtx); err != nil {
+	playbackOptions, err := options.deviceOptions(DeviceKindPlayback)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if err := client.playbackClient.Init(audioCtx, playbackOptions); err != nil {
 		client.Close()
 		return nil, fmt.Errorf("failed to initialize playback client: %w", err)
 	}
@@ -44,11 +74,20 @@ func NewClient() (*Client, error) {
 		return nil, fmt.Errorf("failed to start playback device: %w", err)
 	}
 
-	if err := client.captureClient.Init(audioCtx); err != nil {
+	captureOptions, err := options.deviceOptions(DeviceKindCapture)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if err := client.captureClient.Init(audioCtx, captureOptions); err != nil {
 		client.Close()
 		return nil, fmt.Errorf("failed to initialize capture client: %w", err)
 	}
 
+	if options.DevicePollInterval > 0 && (options.PlaybackDevice == "" || options.CaptureDevice == "") {
+		go client.watchDefaultDevices()
+	}
+
 	return &client, nil
 }
 
@@ -73,6 +112,7 @@ func (c *Client) StopPlayback() error {
 }
 
 func (c *Client) Close() {
+	close(c.done)
 	_ = c.captureClient.Uninit()
 	_ = c.playbackClient.Uninit()
 	_ = c.audioContext.Uninit()
@@ -87,13 +127,69 @@ func (c *Client) ClearBuffer() {
 	c.playbackClient.ClearBuffer()
 }
 
+// PlayedFrames returns the number of frames of the sent audio the playback
+// device consumed
+func (c *Client) PlayedFrames() int64 {
+	return c.playbackClient.PlayedFrames()
+}
+
 func (c *Client) AwaitMark() error {
 	return c.playbackClient.AwaitMark()
 }
 
 func (c *Client) EncodingInfo() audio.EncodingInfo {
 	return audio.EncodingInfo{
-		SampleRate: sampleRate,
-		Encoding:   "linear16",
+		SampleRate: c.options.SampleRate,
+		Encoding:   audio.EncodingLinear16,
+		Channels:   c.options.Channels,
+	}
+}
+
+// watchDefaultDevices reopens the devices that follow the system default
+// when the default device changes
+func (c *Client) watchDefaultDevices() {
+	ticker := time.NewTicker(c.options.DevicePollInterval)
+	defer ticker.Stop()
+
+	defaults := map[DeviceKind]string{}
+	for _, kind := range []DeviceKind{DeviceKindPlayback, DeviceKindCapture} {
+		defaults[kind], _ = defaultDeviceID(c.audioContext.Context, kind)
+	}
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		for kind, previous := range defaults {
+			if (kind == DeviceKindPlayback && c.options.PlaybackDevice != "") ||
+				(kind == DeviceKindCapture && c.options.CaptureDevice != "") {
+				continue
+			}
+
+			current, err := defaultDeviceID(c.audioContext.Context, kind)
+			if err != nil {
+				log.Printf("Failed to check default %s device: %v", kind, err)
+				continue
+			}
+			if current == "" || current == previous {
+				continue
+			}
+			defaults[kind] = current
+
+			c.notifyDeviceEvent(kind, DeviceEventDefaultChanged)
+			switch kind {
+			case DeviceKindPlayback:
+				go c.playbackClient.reopen()
+			case DeviceKindCapture:
+				go c.captureClient.reopen()
+			}
+		}
 	}
 }
+
+func (c *Client) notifyDeviceEvent(kind DeviceKind, event DeviceEvent) {
+	notifyDeviceEvent(c.options.DeviceEventCallback, kind, event)
+}
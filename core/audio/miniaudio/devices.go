@@ -0,0 +1,116 @@
+package miniaudio
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/gen2brain/malgo"
+)
+
+// DeviceKind is the direction of an audio device
+type DeviceKind string
+
+const (
+	DeviceKindPlayback DeviceKind = "playback"
+	DeviceKindCapture  DeviceKind = "capture"
+)
+
+func (k DeviceKind) deviceType() malgo.DeviceType {
+	if k == DeviceKindCapture {
+		return malgo.Capture
+	}
+	return malgo.Playback
+}
+
+// Device is an audio device that can be selected with WithPlaybackDevice or
+// WithCaptureDevice
+type Device struct {
+	// ID identifies the device within the audio backend, it stays the same
+	// while the device is connected
+	ID        string
+	Name      string
+	IsDefault bool
+	// Formats are the native formats of the device, other formats are
+	// converted by miniaudio
+	Formats []DeviceFormat
+}
+
+// DeviceFormat is a native format of a device, zero values mean that any
+// value is supported
+type DeviceFormat struct {
+	SampleRate int
+	Channels   int
+}
+
+// ListDevices returns the connected devices of the kind
+func ListDevices(kind DeviceKind) ([]Device, error) {
+	audioCtx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) { log.Println("malgo:", message) })
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize audio context: %w", err)
+	}
+	defer func() {
+		_ = audioCtx.Uninit()
+		audioCtx.Free()
+	}()
+
+	return listDevices(audioCtx.Context, kind)
+}
+
+// Devices returns the connected devices of the kind
+func (c *Client) Devices(kind DeviceKind) ([]Device, error) {
+	return listDevices(c.audioContext.Context, kind)
+}
+
+func listDevices(audioCtx malgo.Context, kind DeviceKind) ([]Device, error) {
+	infos, err := audioCtx.Devices(kind.deviceType())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s devices: %w", kind, err)
+	}
+
+	devices := make([]Device, 0, len(infos))
+	for _, info := range infos {
+		device := Device{
+			ID:        info.ID.String(),
+			Name:      info.Name(),
+			IsDefault: info.IsDefault != 0,
+		}
+		for _, format := range info.Formats {
+			device.Formats = append(device.Formats, DeviceFormat{
+				SampleRate: int(format.SampleRate),
+				Channels:   int(format.Channels),
+			})
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// defaultDeviceID returns the ID of the current default device of the kind,
+// empty if the backend doesn't report one
+func defaultDeviceID(audioCtx malgo.Context, kind DeviceKind) (string, error) {
+	devices, err := listDevices(audioCtx, kind)
+	if err != nil {
+		return "", err
+	}
+	for _, device := range devices {
+		if device.IsDefault {
+			return device.ID, nil
+		}
+	}
+	return "", nil
+}
+
+func parseDeviceID(id string) (*malgo.DeviceID, error) {
+	decoded, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device id %q: %w", id, err)
+	}
+
+	var deviceID malgo.DeviceID
+	if len(decoded) > len(deviceID) {
+		return nil, fmt.Errorf("invalid device id %q: too long", id)
+	}
+	copy(deviceID[:], decoded)
+	return &deviceID, nil
+}
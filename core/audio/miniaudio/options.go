@@ -0,0 +1,171 @@
+package miniaudio
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gen2brain/malgo"
+)
+
+type ClientOptions struct {
+	// PlaybackDevice and CaptureDevice are device IDs from ListDevices, empty
+	// uses the system default device
+	PlaybackDevice string
+	CaptureDevice  string
+
+	SampleRate int
+	Channels   int
+	// PeriodSize is the number of frames the device processes at once,
+	// smaller periods lower the latency but risk glitches
+	PeriodSize int
+
+	// DevicePollInterval is how often the default devices are checked for
+	// changes, zero disables following the default device
+	DevicePollInterval  time.Duration
+	DeviceEventCallback func(kind DeviceKind, event DeviceEvent)
+}
+
+type ClientOption func(*ClientOptions)
+
+// WithPlaybackDevice plays audio on the device with the ID instead of the
+// default device
+func WithPlaybackDevice(id string) ClientOption {
+	return func(o *ClientOptions) {
+		o.PlaybackDevice = id
+	}
+}
+
+// WithCaptureDevice captures audio from the device with the ID instead of
+// the default device
+func WithCaptureDevice(id string) ClientOption {
+	return func(o *ClientOptions) {
+		o.CaptureDevice = id
+	}
+}
+
+// WithSampleRate sets the sample rate of the played and captured audio,
+// miniaudio resamples if the device doesn't support it natively
+func WithSampleRate(sampleRate int) ClientOption {
+	return func(o *ClientOptions) {
+		o.SampleRate = sampleRate
+	}
+}
+
+// WithChannels sets the number of channels of the played and captured audio
+func WithChannels(channels int) ClientOption {
+	return func(o *ClientOptions) {
+		o.Channels = channels
+	}
+}
+
+// WithPeriodSize sets the number of frames the devices process at once
+func WithPeriodSize(frames int) ClientOption {
+	return func(o *ClientOptions) {
+		o.PeriodSize = frames
+	}
+}
+
+// WithDevicePollInterval sets how often the default devices are checked for
+// changes, zero disables following the default device
+func WithDevicePollInterval(interval time.Duration) ClientOption {
+	return func(o *ClientOptions) {
+		o.DevicePollInterval = interval
+	}
+}
+
+// WithDeviceEventCallback sets the callback that is called when a device is
+// lost, reopened or the default device changes
+func WithDeviceEventCallback(callback func(kind DeviceKind, event DeviceEvent)) ClientOption {
+	return func(o *ClientOptions) {
+		o.DeviceEventCallback = callback
+	}
+}
+
+// DeviceEvent is a change of an opened device
+type DeviceEvent string
+
+const (
+	// DeviceEventLost is sent when the device stops unexpectedly, e.g. when
+	// it is unplugged
+	DeviceEventLost DeviceEvent = "lost"
+	// DeviceEventDefaultChanged is sent when the system default device
+	// changes while the client follows it
+	DeviceEventDefaultChanged DeviceEvent = "default_changed"
+	// DeviceEventReopened is sent when the device's stream is opened again
+	// after it was lost or the default device changed
+	DeviceEventReopened DeviceEvent = "reopened"
+	// DeviceEventFailed is sent when the device can't be opened again
+	DeviceEventFailed DeviceEvent = "failed"
+)
+
+func notifyDeviceEvent(callback func(DeviceKind, DeviceEvent), kind DeviceKind, event DeviceEvent) {
+	if event != DeviceEventReopened {
+		log.Printf("Audio %s device %s", kind, event)
+	}
+	if callback != nil {
+		callback(kind, event)
+	}
+}
+
+// deviceOptions are the settings of a single playback or capture device
+type deviceOptions struct {
+	kind       DeviceKind
+	deviceID   *malgo.DeviceID
+	sampleRate int
+	channels   int
+	periodSize int
+
+	onEvent func(kind DeviceKind, event DeviceEvent)
+}
+
+func (o ClientOptions) deviceOptions(kind DeviceKind) (deviceOptions, error) {
+	options := deviceOptions{
+		kind:       kind,
+		sampleRate: o.SampleRate,
+		channels:   o.Channels,
+		periodSize: o.PeriodSize,
+		onEvent:    o.DeviceEventCallback,
+	}
+
+	id := o.PlaybackDevice
+	if kind == DeviceKindCapture {
+		id = o.CaptureDevice
+	}
+	if id != "" {
+		deviceID, err := parseDeviceID(id)
+		if err != nil {
+			return deviceOptions{}, fmt.Errorf("failed to select %s device: %w", kind, err)
+		}
+		options.deviceID = deviceID
+	}
+
+	return options, nil
+}
+
+// config returns the malgo configuration of the device, falling back to the
+// default device if fallback is set
+func (o deviceOptions) config(fallback bool) malgo.DeviceConfig {
+	config := malgo.DefaultDeviceConfig(o.kind.deviceType())
+	config.SampleRate = uint32(o.sampleRate)
+	config.Alsa.NoMMap = 1
+	config.PeriodSizeInFrames = uint32(o.periodSize)
+
+	subConfig := &config.Playback
+	if o.kind == DeviceKindCapture {
+		subConfig = &config.Capture
+	}
+	subConfig.Format = malgo.FormatS16
+	subConfig.Channels = uint32(o.channels)
+	if o.deviceID != nil && !fallback {
+		// NOTE: The ID is copied to C memory which is never freed, it is only
+		// a few hundred bytes per opened device
+		subConfig.DeviceID = o.deviceID.Pointer()
+	}
+
+	return config
+}
+
+func (o deviceOptions) bytesPerFrame() int {
+	return malgo.SampleSizeInBytes(malgo.FormatS16) * o.channels
+}
@@ -3,6 +3,7 @@ package miniaudio
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/gen2brain/malgo"
 )
@@ -10,7 +11,19 @@ import (
 type playbackClient struct {
 	audioContext *malgo.AllocatedContext
 	device       *malgo.Device
-	config       malgo.DeviceConfig
+	options      deviceOptions
+
+	// started is set while playback should be running, so a reopened device
+	// is started again
+	started bool
+	closed  bool
+	// stopping is set while the device is stopped on purpose, any other stop
+	// means the device was lost
+	stopping  atomic.Bool
+	reopening atomic.Bool
+
+	// playedFrames counts the frames of sent audio the device consumed
+	playedFrames atomic.Int64
 
 	leftoverAudio []byte
 	marks         []playbackMark
@@ -20,27 +33,24 @@ type playbackClient struct {
 	marksMu sync.Mutex
 }
 
-func (c *playbackClient) Init(audioContext *malgo.AllocatedContext) error {
+func (c *playbackClient) Init(audioContext *malgo.AllocatedContext, options deviceOptions) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	sampleRate := uint32(sampleRate)
-	channels := 1
-	format := malgo.FormatS16
-	bytesPerFrame := malgo.SampleSizeInBytes(format) * channels
+	c.audioContext = audioContext
+	c.options = options
+	return c.initDevice(false)
+}
 
-	c.config = malgo.DefaultDeviceConfig(malgo.Playback)
-	c.config.SampleRate = sampleRate
-	c.config.Playback.Format = format
-	c.config.Playback.Channels = uint32(channels)
-	c.config.Alsa.NoMMap = 1
-	c.config.PeriodSizeInFrames = 480 // ~10ms at 48kHz
-	c.config.Periods = 4
+// initDevice initializes the playback device, c.mu has to be held when
+// calling it
+func (c *playbackClient) initDevice(fallback bool) error {
+	bytesPerFrame := c.options.bytesPerFrame()
 
-	c.audioContext = audioContext
+	config := c.options.config(fallback)
+	config.Periods = 4
 
-	var err error
-	if c.device, err = malgo.InitDevice(c.audioContext.Context, c.config, malgo.DeviceCallbacks{
+	device, err := malgo.InitDevice(c.audioContext.Context, config, malgo.DeviceCallbacks{
 		Data: func(pOutput, _ []byte, frameCount uint32) {
 			need := int(frameCount) * bytesPerFrame
 			written := 0
@@ -88,14 +98,31 @@ func (c *playbackClient) Init(audioContext *malgo.AllocatedContext) error {
 				n := copy(pOutput[written:need], cur)
 				written += n
 			}
+			c.playedFrames.Add(int64(written / bytesPerFrame))
 		},
-	}); err != nil {
-		return err
+		Stop: c.onStopped,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize playback device: %w", err)
 	}
 
+	c.device = device
 	return nil
 }
 
+// uninitDevice releases the playback device, c.mu has to be held when
+// calling it
+func (c *playbackClient) uninitDevice() {
+	if c.device == nil {
+		return
+	}
+
+	c.stopping.Store(true)
+	c.device.Uninit()
+	c.stopping.Store(false)
+	c.device = nil
+}
+
 func (c *playbackClient) Start() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -107,6 +134,7 @@ func (c *playbackClient) Start() error {
 		return fmt.Errorf("failed to start playback device: %w", err)
 	}
 
+	c.started = true
 	return nil
 }
 
@@ -117,18 +145,26 @@ func (c *playbackClient) Stop() error {
 		return fmt.Errorf("device not initialized")
 	}
 
+	c.stopping.Store(true)
+	defer c.stopping.Store(false)
 	if err := c.device.Stop(); err != nil {
 		return fmt.Errorf("failed to stop playback device: %w", err)
 	}
 
+	c.started = false
 	c.ClearBuffer()
 	return nil
 }
 
 func (c *playbackClient) SendAudio(audio []byte) error {
-	if c.device == nil {
-		return fmt.Errorf("device not initialized")
-	} else if !c.device.IsStarted() {
+	c.mu.Lock()
+	started, closed := c.started, c.closed
+	c.mu.Unlock()
+	// NOTE: The device itself isn't checked, audio is buffered while a lost
+	// device is being reopened
+	if closed {
+		return fmt.Errorf("device closed")
+	} else if !started {
 		return fmt.Errorf("device not started")
 	}
 
@@ -167,6 +203,10 @@ func (c *playbackClient) Mark(mark string, callback func(string)) error {
 	return nil
 }
 
+func (c *playbackClient) PlayedFrames() int64 {
+	return c.playedFrames.Load()
+}
+
 func (c *playbackClient) Uninit() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -175,12 +215,51 @@ func (c *playbackClient) Uninit() error {
 		return fmt.Errorf("device not initialized")
 	}
 
-	c.device.Uninit()
-	c.device = nil
-
+	c.uninitDevice()
+	c.started = false
+	c.closed = true
 	return nil
 }
 
+// onStopped is called by miniaudio whenever the device stops
+func (c *playbackClient) onStopped() {
+	if c.stopping.Load() {
+		return
+	}
+
+	notifyDeviceEvent(c.options.onEvent, DeviceKindPlayback, DeviceEventLost)
+	go c.reopen()
+}
+
+// reopen reinitializes the playback device after it was lost or the default
+// device changed, the buffered audio and marks are kept so playback continues
+// where it stopped
+func (c *playbackClient) reopen() {
+	if !c.reopening.CompareAndSwap(false, true) {
+		return
+	}
+	defer c.reopening.Store(false)
+
+	reopenDevice(c.options, func(fallback bool) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.closed {
+			return errDeviceClosed
+		}
+
+		c.uninitDevice()
+		if err := c.initDevice(fallback); err != nil {
+			return err
+		}
+		if c.started {
+			if err := c.device.Start(); err != nil {
+				return fmt.Errorf("failed to start playback device: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
 type playbackMark struct {
 	name     string
 	position int
@@ -0,0 +1,46 @@
+package miniaudio
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	reopenInitialBackoff = 250 * time.Millisecond
+	reopenMaxBackoff     = 4 * time.Second
+	reopenMaxAttempts    = 8
+	// reopenFallbackAttempt is the attempt from which the default device is
+	// opened instead of a selected device that doesn't come back, e.g. an
+	// unplugged headset
+	reopenFallbackAttempt = 3
+)
+
+// errDeviceClosed is returned by open functions when the client was closed
+// and the device shouldn't be reopened
+var errDeviceClosed = errors.New("device closed")
+
+// reopenDevice calls open with backoff until it succeeds or the attempts run
+// out, open reinitializes the device and starts it if it was running
+func reopenDevice(options deviceOptions, open func(fallback bool) error) {
+	backoff := reopenInitialBackoff
+	for attempt := range reopenMaxAttempts {
+		fallback := options.deviceID != nil && attempt >= reopenFallbackAttempt
+		err := open(fallback)
+		if errors.Is(err, errDeviceClosed) {
+			return
+		} else if err == nil {
+			if fallback {
+				log.Printf("Warning: selected %s device is gone, using the default device", options.kind)
+			}
+			notifyDeviceEvent(options.onEvent, options.kind, DeviceEventReopened)
+			return
+		}
+
+		log.Printf("Failed to reopen %s device: %v", options.kind, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, reopenMaxBackoff)
+	}
+
+	notifyDeviceEvent(options.onEvent, options.kind, DeviceEventFailed)
+}
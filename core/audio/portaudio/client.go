@@ -118,6 +118,7 @@ func (c *Client) AwaitMark() error {
 func (c *Client) EncodingInfo() audio.EncodingInfo {
 	return audio.EncodingInfo{
 		SampleRate: sampleRate,
-		Encoding:   "linear16",
+		Encoding:   audio.EncodingLinear16,
+		Channels:   1,
 	}
 }
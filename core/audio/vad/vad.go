@@ -0,0 +1,227 @@
+// Package vad detects voice activity in linear16 mono audio locally, without
+// relying on the speech to text provider.
+//
+// The detector combines the short-term energy of the audio with its zero
+// crossing rate: speech is loud enough compared to the background noise and
+// crosses zero at a rate typical for voice, while hum is too low and hiss too
+// high.
+package vad
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// TODO: Add a WebRTC-style GMM classifier as an alternative to the energy and
+// zero crossing rate heuristics
+
+const (
+	defaultEnergyThreshold    = 500
+	defaultNoiseFactor        = 3
+	defaultMinZeroCrossRate   = 0.01
+	defaultMaxZeroCrossRate   = 0.35
+	defaultMinSpeechDuration  = 100 * time.Millisecond
+	defaultMinSilenceDuration = 500 * time.Millisecond
+	// noiseFloorSmoothing is the weight of a new silent chunk in the noise
+	// floor estimate
+	noiseFloorSmoothing = 0.05
+)
+
+// Event is a change in voice activity
+type Event string
+
+const (
+	EventNone          Event = ""
+	EventSpeechStarted Event = "speech_started"
+	EventSpeechEnded   Event = "speech_ended"
+)
+
+// Detector detects the start and end of speech in a stream of audio chunks.
+// It is not safe for concurrent use.
+type Detector struct {
+	sampleRate         int
+	energyThreshold    float64
+	noiseFactor        float64
+	minZeroCrossRate   float64
+	maxZeroCrossRate   float64
+	minSpeechDuration  time.Duration
+	minSilenceDuration time.Duration
+
+	speaking   bool
+	voiced     time.Duration
+	silent     time.Duration
+	noiseFloor float64
+}
+
+func NewDetector(sampleRate int, opts ...Option) *Detector {
+	detector := &Detector{
+		sampleRate:         sampleRate,
+		energyThreshold:    defaultEnergyThreshold,
+		noiseFactor:        defaultNoiseFactor,
+		minZeroCrossRate:   defaultMinZeroCrossRate,
+		maxZeroCrossRate:   defaultMaxZeroCrossRate,
+		minSpeechDuration:  defaultMinSpeechDuration,
+		minSilenceDuration: defaultMinSilenceDuration,
+	}
+	for _, opt := range opts {
+		opt(detector)
+	}
+
+	return detector
+}
+
+type Option func(*Detector)
+
+// WithEnergyThreshold sets the minimum RMS amplitude of speech
+func WithEnergyThreshold(threshold float64) Option {
+	return func(d *Detector) {
+		d.energyThreshold = threshold
+	}
+}
+
+// WithNoiseFactor sets how many times louder than the estimated background
+// noise speech has to be, zero disables noise estimation
+func WithNoiseFactor(factor float64) Option {
+	return func(d *Detector) {
+		d.noiseFactor = factor
+	}
+}
+
+// WithZeroCrossRate sets the range of the zero crossing rate (crossings per
+// sample) of speech
+func WithZeroCrossRate(min, max float64) Option {
+	return func(d *Detector) {
+		d.minZeroCrossRate = min
+		d.maxZeroCrossRate = max
+	}
+}
+
+// WithMinSpeechDuration sets how long voice has to be detected for speech to
+// start, shorter sounds are ignored
+func WithMinSpeechDuration(duration time.Duration) Option {
+	return func(d *Detector) {
+		d.minSpeechDuration = duration
+	}
+}
+
+// WithMinSilenceDuration sets how long the silence has to be for speech to
+// end
+func WithMinSilenceDuration(duration time.Duration) Option {
+	return func(d *Detector) {
+		d.minSilenceDuration = duration
+	}
+}
+
+// Process analyses the next chunk of audio and returns the resulting event,
+// EventNone if voice activity did not change
+func (d *Detector) Process(chunk []byte) Event {
+	samples := len(chunk) / 2
+	if samples == 0 || d.sampleRate == 0 {
+		return EventNone
+	}
+	duration := time.Duration(samples) * time.Second / time.Duration(d.sampleRate)
+
+	voice := d.IsVoice(chunk)
+	if !voice && !d.speaking {
+		d.updateNoiseFloor(RMS(chunk))
+	}
+
+	if !d.speaking {
+		if !voice {
+			d.voiced = 0
+			return EventNone
+		}
+		d.voiced += duration
+		if d.voiced < d.minSpeechDuration {
+			return EventNone
+		}
+		d.speaking = true
+		d.voiced = 0
+		d.silent = 0
+		return EventSpeechStarted
+	}
+
+	if voice {
+		d.silent = 0
+		return EventNone
+	}
+	d.silent += duration
+	if d.silent < d.minSilenceDuration {
+		return EventNone
+	}
+	d.speaking = false
+	d.silent = 0
+	return EventSpeechEnded
+}
+
+// IsSpeaking reports whether speech is ongoing
+func (d *Detector) IsSpeaking() bool {
+	return d.speaking
+}
+
+// IsVoice reports whether the chunk on its own sounds like voice, without
+// taking the duration of speech or silence into account
+func (d *Detector) IsVoice(chunk []byte) bool {
+	threshold := d.energyThreshold
+	if d.noiseFactor > 0 {
+		threshold = max(threshold, d.noiseFloor*d.noiseFactor)
+	}
+	if RMS(chunk) < threshold {
+		return false
+	}
+
+	zeroCrossRate := ZeroCrossRate(chunk)
+	return zeroCrossRate >= d.minZeroCrossRate && zeroCrossRate <= d.maxZeroCrossRate
+}
+
+// Reset forgets the ongoing speech and the estimated background noise
+func (d *Detector) Reset() {
+	d.speaking = false
+	d.voiced = 0
+	d.silent = 0
+	d.noiseFloor = 0
+}
+
+func (d *Detector) updateNoiseFloor(energy float64) {
+	if d.noiseFloor == 0 {
+		d.noiseFloor = energy
+		return
+	}
+	d.noiseFloor += noiseFloorSmoothing * (energy - d.noiseFloor)
+}
+
+// RMS returns the root mean square amplitude of linear16 audio
+func RMS(chunk []byte) float64 {
+	samples := len(chunk) / 2
+	if samples == 0 {
+		return 0
+	}
+
+	var sumOfSquares float64
+	for i := range samples {
+		sample := float64(int16(binary.LittleEndian.Uint16(chunk[i*2:])))
+		sumOfSquares += sample * sample
+	}
+	return math.Sqrt(sumOfSquares / float64(samples))
+}
+
+// ZeroCrossRate returns the share of consecutive linear16 samples that change
+// sign
+func ZeroCrossRate(chunk []byte) float64 {
+	samples := len(chunk) / 2
+	if samples < 2 {
+		return 0
+	}
+
+	crossings := 0
+	previous := int16(binary.LittleEndian.Uint16(chunk))
+	for i := 1; i < samples; i++ {
+		sample := int16(binary.LittleEndian.Uint16(chunk[i*2:]))
+		if (previous < 0) != (sample < 0) {
+			crossings++
+		}
+		previous = sample
+	}
+	return float64(crossings) / float64(samples-1)
+}
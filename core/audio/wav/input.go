@@ -0,0 +1,188 @@
+package wav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/audio/convert"
+)
+
+// Input streams the audio of a WAV file as if it was captured by a
+// microphone, it implements both AudioInput and AudioInputFine
+type Input struct {
+	file         *os.File
+	reader       io.Reader
+	encodingInfo audio.EncodingInfo
+	options      Options
+
+	// silenceLeft is the trailing silence that is still to be streamed
+	// after the file ended
+	silenceLeft time.Duration
+	cancel      context.CancelFunc
+	// generation identifies the latest stream, so a stream that was stopped
+	// doesn't clear the cancel function of the one that replaced it
+	generation int
+	done       chan struct{}
+	doneOnce   sync.Once
+	mu         sync.Mutex
+	// streamMu is held while streaming, so a stopped stream finishes before
+	// the next one reads from the file
+	streamMu sync.Mutex
+}
+
+// NewInput opens the WAV file for streaming
+func NewInput(path string, opts ...Option) (*Input, error) {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wav file: %w", err)
+	}
+	header, err := ReadHeader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read wav file: %w", err)
+	}
+
+	var reader io.Reader = file
+	// NOTE: Streamed WAV files leave the data size empty or at its maximum,
+	// those are read until the end of the file
+	if header.DataSize > 0 && header.DataSize != 0xFFFFFFFF {
+		reader = io.LimitReader(file, int64(header.DataSize))
+	}
+
+	return &Input{
+		file:         file,
+		reader:       reader,
+		encodingInfo: header.EncodingInfo,
+		options:      options,
+		silenceLeft:  options.TrailingSilence,
+		done:         make(chan struct{}),
+	}, nil
+}
+
+func (i *Input) EncodingInfo() audio.EncodingInfo {
+	return i.encodingInfo
+}
+
+// Stream streams the file until it ends or the context is cancelled
+func (i *Input) Stream(ctx context.Context, onAudio func(audio []byte)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	i.mu.Lock()
+	if i.cancel != nil {
+		i.mu.Unlock()
+		return errors.New("already streaming")
+	}
+	i.cancel = cancel
+	i.generation++
+	generation := i.generation
+	i.mu.Unlock()
+
+	return i.stream(ctx, generation, onAudio)
+}
+
+// StartCapture starts streaming the file in the background, it continues
+// from where the previous capture stopped
+func (i *Input) StartCapture(ctx context.Context, onAudio func(audio []byte)) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cancel != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	i.cancel = cancel
+	i.generation++
+	generation := i.generation
+	go func() {
+		if err := i.stream(ctx, generation, onAudio); err != nil {
+			log.Printf("Failed to stream wav file: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (i *Input) StopCapture() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.cancel != nil {
+		i.cancel()
+		i.cancel = nil
+	}
+	return nil
+}
+
+// Done is closed when the whole file, including the trailing silence, was
+// streamed
+func (i *Input) Done() <-chan struct{} {
+	return i.done
+}
+
+func (i *Input) Close() {
+	_ = i.StopCapture()
+	i.file.Close()
+}
+
+func (i *Input) stream(ctx context.Context, generation int, onAudio func(audio []byte)) error {
+	i.streamMu.Lock()
+	defer i.streamMu.Unlock()
+	defer func() {
+		i.mu.Lock()
+		if i.generation == generation {
+			i.cancel = nil
+		}
+		i.mu.Unlock()
+	}()
+
+	frameSize := i.encodingInfo.BytesPerFrame()
+	chunkFrames := i.options.chunkFrames(i.encodingInfo.SampleRate)
+	silence := bytes.Repeat(convert.Silence(i.encodingInfo.Encoding), chunkFrames*i.encodingInfo.ChannelCount())
+	chunkDuration := time.Duration(chunkFrames) * time.Second / time.Duration(i.encodingInfo.SampleRate)
+
+	start := time.Now()
+	frames := 0
+	for {
+		if i.options.Speed > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Until(i.options.pace(start, frames, i.encodingInfo.SampleRate))):
+			}
+		} else if ctx.Err() != nil {
+			return nil
+		}
+
+		chunk := make([]byte, chunkFrames*frameSize)
+		n, err := io.ReadFull(i.reader, chunk)
+		chunk = chunk[:n-n%frameSize]
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			if len(chunk) == 0 {
+				if i.silenceLeft <= 0 {
+					i.doneOnce.Do(func() { close(i.done) })
+					return nil
+				}
+				i.silenceLeft -= chunkDuration
+				chunk = silence
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to read wav file: %w", err)
+		}
+
+		onAudio(chunk)
+		frames += len(chunk) / frameSize
+	}
+}
@@ -0,0 +1,66 @@
+package wav
+
+import "time"
+
+const (
+	defaultSpeed           = 1
+	defaultChunkDuration   = 20 * time.Millisecond
+	defaultTrailingSilence = 2 * time.Second
+)
+
+type Options struct {
+	// Speed is how many times faster than real time the audio is read or
+	// played, zero means as fast as possible
+	Speed float64
+	// ChunkDuration is the duration of the chunks the audio is read or
+	// played in
+	ChunkDuration time.Duration
+	// TrailingSilence is the silence streamed by the input after the file
+	// ends, so speech to text can finalize the last utterance
+	TrailingSilence time.Duration
+}
+
+type Option func(*Options)
+
+func defaultOptions() Options {
+	return Options{
+		Speed:           defaultSpeed,
+		ChunkDuration:   defaultChunkDuration,
+		TrailingSilence: defaultTrailingSilence,
+	}
+}
+
+// WithSpeed sets how many times faster than real time the audio is read or
+// played, zero means as fast as possible
+func WithSpeed(speed float64) Option {
+	return func(o *Options) {
+		o.Speed = speed
+	}
+}
+
+// WithChunkDuration sets the duration of the chunks the audio is read or
+// played in
+func WithChunkDuration(duration time.Duration) Option {
+	return func(o *Options) {
+		o.ChunkDuration = duration
+	}
+}
+
+// WithTrailingSilence sets the silence streamed by the input after the file
+// ends
+func WithTrailingSilence(duration time.Duration) Option {
+	return func(o *Options) {
+		o.TrailingSilence = duration
+	}
+}
+
+// chunkFrames returns the number of frames in a chunk at the sample rate
+func (o Options) chunkFrames(sampleRate int) int {
+	return max(int(o.ChunkDuration.Seconds()*float64(sampleRate)), 1)
+}
+
+// pace returns the time at which the frames should be done at the sample
+// rate, measured from start
+func (o Options) pace(start time.Time, frames int, sampleRate int) time.Time {
+	return start.Add(time.Duration(float64(frames) / float64(sampleRate) / o.Speed * float64(time.Second)))
+}
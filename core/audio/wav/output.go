@@ -0,0 +1,209 @@
+package wav
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/audio/convert"
+)
+
+// Output writes the played audio to a WAV file, it implements
+// AudioOutputV1. Audio is "played" at the configured speed and marks are
+// reported once all audio before them was written. While playing in real
+// time, gaps between the audio are filled with silence so the file lines up
+// with the wall clock, e.g. with a recorded input.
+type Output struct {
+	file         *os.File
+	writer       *Writer
+	encodingInfo audio.EncodingInfo
+	options      Options
+
+	queue []byte
+	marks []outputMark
+	// playedFrames counts the frames of sent audio written to the file
+	playedFrames atomic.Int64
+
+	closed bool
+	done   chan struct{}
+	mu     sync.Mutex
+	signal *sync.Cond
+}
+
+type outputMark struct {
+	name string
+	// position is the number of queued bytes that have to be written before
+	// the mark is reached
+	position int
+	callback func(string)
+}
+
+// NewOutput creates the WAV file the played audio is written to, the audio
+// sent to the output has to be in the given encoding
+func NewOutput(path string, encodingInfo audio.EncodingInfo, opts ...Option) (*Output, error) {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if encodingInfo.Encoding.BytesPerSample() == 0 || encodingInfo.SampleRate <= 0 {
+		return nil, fmt.Errorf("unsupported encoding %q at %d Hz", encodingInfo.Encoding, encodingInfo.SampleRate)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wav file: %w", err)
+	}
+	writer, err := NewWriter(file, encodingInfo)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	output := &Output{
+		file:         file,
+		writer:       writer,
+		encodingInfo: encodingInfo,
+		options:      options,
+		done:         make(chan struct{}),
+	}
+	output.signal = sync.NewCond(&output.mu)
+	go output.play()
+
+	return output, nil
+}
+
+func (o *Output) EncodingInfo() audio.EncodingInfo {
+	return o.encodingInfo
+}
+
+func (o *Output) SendAudio(audio []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return fmt.Errorf("output closed")
+	}
+
+	o.queue = append(o.queue, audio...)
+	o.signal.Broadcast()
+	return nil
+}
+
+// ClearBuffer drops the audio that wasn't played yet, along with its marks
+func (o *Output) ClearBuffer() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.queue = nil
+	o.marks = nil
+}
+
+// Mark calls the callback once all audio sent before it was played
+func (o *Output) Mark(name string, callback func(string)) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return fmt.Errorf("output closed")
+	}
+
+	o.marks = append(o.marks, outputMark{name: name, position: len(o.queue), callback: callback})
+	o.signal.Broadcast()
+	return nil
+}
+
+// PlayedFrames returns the number of frames of the sent audio that were
+// written, it implements AudioOutputWithPosition
+func (o *Output) PlayedFrames() int64 {
+	return o.playedFrames.Load()
+}
+
+// Close writes the remaining audio and finalizes the file
+func (o *Output) Close() error {
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return nil
+	}
+	o.closed = true
+	o.signal.Broadcast()
+	o.mu.Unlock()
+
+	<-o.done
+	if err := o.writer.Close(); err != nil {
+		o.file.Close()
+		return err
+	}
+	return o.file.Close()
+}
+
+// play writes the queued audio chunk by chunk at the configured speed
+func (o *Output) play() {
+	defer close(o.done)
+
+	frameSize := o.encodingInfo.BytesPerFrame()
+	chunkSize := o.options.chunkFrames(o.encodingInfo.SampleRate) * frameSize
+	silence := bytes.Repeat(convert.Silence(o.encodingInfo.Encoding), chunkSize/o.encodingInfo.Encoding.BytesPerSample())
+
+	start := time.Now()
+	for {
+		if o.options.Speed > 0 {
+			time.Sleep(time.Until(o.options.pace(start, o.writer.Frames(), o.encodingInfo.SampleRate)))
+		}
+
+		o.mu.Lock()
+		if o.options.Speed <= 0 {
+			for len(o.queue) == 0 && len(o.marks) == 0 && !o.closed {
+				o.signal.Wait()
+			}
+			chunkSize = max(len(o.queue)-len(o.queue)%frameSize, frameSize)
+		}
+		if o.closed && len(o.queue) == 0 {
+			o.mu.Unlock()
+			o.reachMarks(o.takeMarks(0))
+			return
+		}
+
+		chunk := o.queue[:min(chunkSize, len(o.queue))]
+		o.queue = o.queue[len(chunk):]
+		reached := o.takeMarks(len(chunk))
+		o.mu.Unlock()
+
+		played := int64(len(chunk) / frameSize)
+		if len(chunk) == 0 && o.options.Speed > 0 {
+			chunk = silence
+		}
+		if _, err := o.writer.Write(chunk); err != nil {
+			log.Printf("Failed to write audio to wav file: %v", err)
+		}
+		o.playedFrames.Add(played)
+		o.reachMarks(reached)
+	}
+}
+
+// takeMarks removes and returns the marks reached after written bytes of the
+// queue were played, o.mu has to be held when calling it
+func (o *Output) takeMarks(written int) []outputMark {
+	reached := 0
+	for i := range o.marks {
+		o.marks[i].position -= written
+		if o.marks[i].position <= 0 {
+			reached++
+		}
+	}
+
+	marks := o.marks[:reached]
+	o.marks = o.marks[reached:]
+	return marks
+}
+
+func (o *Output) reachMarks(marks []outputMark) {
+	for _, mark := range marks {
+		if mark.callback != nil {
+			mark.callback(mark.name)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package wav reads and writes WAV files and provides audio input and output
+// adapters backed by them, so the voice pipeline can run without a sound
+// card, e.g. for regression runs or batch processing of recorded questions.
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/koscakluka/ema-core/core/audio"
+)
+
+// WAV format tags of the supported encodings
+const (
+	formatPCM   = 1
+	formatFloat = 3
+	formatAlaw  = 6
+	formatMulaw = 7
+	// formatExtensible stores the actual format in the first two bytes of
+	// the sub format GUID
+	formatExtensible = 0xFFFE
+
+	headerSize = 44
+)
+
+// Header is the format and the size of the audio in a WAV file
+type Header struct {
+	EncodingInfo audio.EncodingInfo
+	// DataSize is the size of the audio in bytes
+	DataSize int
+}
+
+// ReadHeader reads the header of a WAV file up to the start of the audio
+// data, skipping chunks other than the format chunk
+func ReadHeader(r io.Reader) (Header, error) {
+	var riff struct {
+		ID   [4]byte
+		Size uint32
+		Wave [4]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &riff); err != nil {
+		return Header{}, fmt.Errorf("failed to read riff header: %w", err)
+	}
+	if string(riff.ID[:]) != "RIFF" || string(riff.Wave[:]) != "WAVE" {
+		return Header{}, errors.New("not a wav file")
+	}
+
+	var header Header
+	formatFound := false
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			return Header{}, fmt.Errorf("failed to read chunk header: %w", err)
+		}
+
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			body := make([]byte, chunk.Size+chunk.Size%2)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return Header{}, fmt.Errorf("failed to read format chunk: %w", err)
+			}
+			encodingInfo, err := parseFormat(body[:chunk.Size])
+			if err != nil {
+				return Header{}, err
+			}
+			header.EncodingInfo = encodingInfo
+			formatFound = true
+
+		case "data":
+			if !formatFound {
+				return Header{}, errors.New("data chunk before format chunk")
+			}
+			header.DataSize = int(chunk.Size)
+			return header, nil
+
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(chunk.Size+chunk.Size%2)); err != nil {
+				return Header{}, fmt.Errorf("failed to skip %q chunk: %w", chunk.ID, err)
+			}
+		}
+	}
+}
+
+func parseFormat(body []byte) (audio.EncodingInfo, error) {
+	if len(body) < 16 {
+		return audio.EncodingInfo{}, errors.New("format chunk too short")
+	}
+
+	format := binary.LittleEndian.Uint16(body[0:])
+	channels := int(binary.LittleEndian.Uint16(body[2:]))
+	sampleRate := int(binary.LittleEndian.Uint32(body[4:]))
+	bitsPerSample := binary.LittleEndian.Uint16(body[14:])
+	if format == formatExtensible {
+		if len(body) < 26 {
+			return audio.EncodingInfo{}, errors.New("extensible format chunk too short")
+		}
+		format = binary.LittleEndian.Uint16(body[24:])
+	}
+
+	encodingInfo := audio.EncodingInfo{SampleRate: sampleRate, Channels: channels}
+	switch {
+	case format == formatPCM && bitsPerSample == 16:
+		encodingInfo.Encoding = audio.EncodingLinear16
+	case format == formatFloat && bitsPerSample == 32:
+		encodingInfo.Encoding = audio.EncodingFloat32
+	case format == formatMulaw && bitsPerSample == 8:
+		encodingInfo.Encoding = audio.EncodingMulaw
+	case format == formatAlaw && bitsPerSample == 8:
+		encodingInfo.Encoding = audio.EncodingAlaw
+	default:
+		return audio.EncodingInfo{}, fmt.Errorf("unsupported wav format %d with %d bits per sample", format, bitsPerSample)
+	}
+	return encodingInfo, nil
+}
+
+// WriteHeader writes the header of a WAV file with the given amount of audio
+// data
+func WriteHeader(w io.Writer, header Header) error {
+	encodingInfo := header.EncodingInfo
+	var format uint16
+	switch encodingInfo.Encoding {
+	case audio.EncodingLinear16:
+		format = formatPCM
+	case audio.EncodingFloat32:
+		format = formatFloat
+	case audio.EncodingMulaw:
+		format = formatMulaw
+	case audio.EncodingAlaw:
+		format = formatAlaw
+	default:
+		return fmt.Errorf("unsupported encoding %q", encodingInfo.Encoding)
+	}
+
+	bytesPerFrame := encodingInfo.BytesPerFrame()
+	dataSize := uint32(header.DataSize)
+	fields := []any{
+		[]byte("RIFF"), uint32(headerSize-8) + dataSize, []byte("WAVE"),
+		[]byte("fmt "), uint32(16), format, uint16(encodingInfo.ChannelCount()),
+		uint32(encodingInfo.SampleRate), uint32(encodingInfo.SampleRate * bytesPerFrame),
+		uint16(bytesPerFrame), uint16(encodingInfo.Encoding.BytesPerSample() * 8),
+		[]byte("data"), dataSize,
+	}
+	for _, field := range fields {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
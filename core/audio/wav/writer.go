@@ -0,0 +1,52 @@
+package wav
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/koscakluka/ema-core/core/audio"
+)
+
+// Writer writes audio to a WAV file, the sizes in the header are filled in
+// when it is closed. It is not safe for concurrent use.
+type Writer struct {
+	w            io.WriteSeeker
+	encodingInfo audio.EncodingInfo
+	dataSize     int
+}
+
+// NewWriter writes the header to w and returns a writer for the audio
+func NewWriter(w io.WriteSeeker, encodingInfo audio.EncodingInfo) (*Writer, error) {
+	if err := WriteHeader(w, Header{EncodingInfo: encodingInfo}); err != nil {
+		return nil, fmt.Errorf("failed to write wav header: %w", err)
+	}
+
+	return &Writer{w: w, encodingInfo: encodingInfo}, nil
+}
+
+// Write appends the audio, it has to be in the writer's encoding
+func (w *Writer) Write(audio []byte) (int, error) {
+	n, err := w.w.Write(audio)
+	w.dataSize += n
+	return n, err
+}
+
+// Frames returns the number of frames written so far
+func (w *Writer) Frames() int {
+	return w.dataSize / max(w.encodingInfo.BytesPerFrame(), 1)
+}
+
+// Close fills in the sizes in the header, it doesn't close the underlying
+// writer
+func (w *Writer) Close() error {
+	if _, err := w.w.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to wav header: %w", err)
+	}
+	if err := WriteHeader(w.w, Header{EncodingInfo: w.encodingInfo, DataSize: w.dataSize}); err != nil {
+		return fmt.Errorf("failed to write wav header: %w", err)
+	}
+	if _, err := w.w.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("failed to seek to end of wav: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package orchestration
+
+import (
+	"log"
+	"sync"
+
+	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/audio/convert"
+)
+
+// defaultEncodingInfo is assumed for audio whose encoding isn't known, e.g.
+// audio sent through SendAudio without an audio input
+var defaultEncodingInfo = audio.EncodingInfo{SampleRate: 48000, Encoding: audio.EncodingLinear16, Channels: 1}
+
+// EncodingNegotiator is implemented by speech to text and text to speech
+// clients that can report which encodings they accept. Clients that don't
+// implement it are assumed to accept the encoding they are given.
+type EncodingNegotiator interface {
+	SupportsEncoding(encodingInfo audio.EncodingInfo) bool
+}
+
+// audioFormats holds the encodings negotiated between the audio input, the
+// speech to text client, the text to speech client and the audio output, and
+// the converters between them
+type audioFormats struct {
+	// toLocal converts the input to linear16 mono, which echo cancellation,
+	// voice activity detection and wake word detection work with
+	toLocal *streamConverter
+	// toSpeechToText converts the audio after local processing to the
+	// encoding of the speech to text client
+	toSpeechToText *streamConverter
+
+	textToSpeech audio.EncodingInfo
+	// toOutput converts the text to speech audio to the output's encoding
+	toOutput *streamConverter
+	// toEchoReference converts the text to speech audio to the local input
+	// encoding, which the echo canceller's reference has to match
+	toEchoReference *streamConverter
+}
+
+// negotiateInput picks the encoding of the speech to text client and sets
+// up the converters for the input audio
+func (o *Orchestrator) negotiateInput() (audio.EncodingInfo, bool) {
+	if o.audioInput == nil {
+		return audio.EncodingInfo{}, false
+	}
+
+	input := o.audioInput.EncodingInfo()
+	local := input
+	localProcessing := o.voiceActivity.enabled || o.echoSuppression.cancellation || o.wakeWord.options.Detector != nil
+	if localProcessing && !input.Matches(input.Mono()) {
+		local = input.Mono()
+		o.audioFormats.toLocal = newStreamConverter(input, local)
+	}
+
+	speechToText := negotiateEncoding(o.speechToTextClient, local,
+		local.Mono(),
+		audio.EncodingInfo{SampleRate: 16000, Encoding: audio.EncodingLinear16, Channels: 1},
+	)
+	if !local.Matches(speechToText) {
+		o.audioFormats.toSpeechToText = newStreamConverter(local, speechToText)
+	}
+	return speechToText, true
+}
+
+// negotiateOutput picks the encoding of the text to speech client and sets
+// up the converter for the output audio
+func (o *Orchestrator) negotiateOutput() (audio.EncodingInfo, bool) {
+	if o.audioOutput == nil {
+		return audio.EncodingInfo{}, false
+	}
+
+	output := o.audioOutput.EncodingInfo()
+	textToSpeech := negotiateEncoding(o.textToSpeechClient, output,
+		output.Mono(),
+		audio.EncodingInfo{SampleRate: 48000, Encoding: audio.EncodingLinear16, Channels: 1},
+		audio.EncodingInfo{SampleRate: 24000, Encoding: audio.EncodingLinear16, Channels: 1},
+		audio.EncodingInfo{SampleRate: 16000, Encoding: audio.EncodingLinear16, Channels: 1},
+	)
+	o.audioFormats.textToSpeech = textToSpeech
+	if !output.Matches(textToSpeech) {
+		o.audioFormats.toOutput = newStreamConverter(textToSpeech, output)
+	}
+	if o.echoSuppression.cancellation && o.audioInput != nil {
+		if local := o.audioInput.EncodingInfo().Mono(); !textToSpeech.Matches(local) {
+			o.audioFormats.toEchoReference = newStreamConverter(textToSpeech, local)
+		}
+	}
+	o.outputAudioBuffer.sampleRate = textToSpeech.SampleRate
+	o.outputAudioBuffer.encoding = textToSpeech.Encoding
+	return textToSpeech, true
+}
+
+// negotiateEncoding returns the first of the preferred encodings the client
+// supports, the most preferred one if it supports none or can't tell
+func negotiateEncoding(client any, preferred audio.EncodingInfo, fallbacks ...audio.EncodingInfo) audio.EncodingInfo {
+	negotiator, ok := client.(EncodingNegotiator)
+	if !ok {
+		return preferred
+	}
+
+	for _, encodingInfo := range append([]audio.EncodingInfo{preferred}, fallbacks...) {
+		if negotiator.SupportsEncoding(encodingInfo) {
+			return encodingInfo
+		}
+	}
+	log.Printf("Warning: client supports none of the encodings, using %s at %d Hz", preferred.Encoding, preferred.SampleRate)
+	return preferred
+}
+
+// streamConverter is a converter that is safe for concurrent use, a nil
+// streamConverter passes the audio through unchanged
+type streamConverter struct {
+	converter *convert.Converter
+	mu        sync.Mutex
+}
+
+func newStreamConverter(from, to audio.EncodingInfo) *streamConverter {
+	converter, err := convert.NewConverter(from, to)
+	if err != nil {
+		log.Printf("Failed to create audio converter, passing audio through unconverted: %v", err)
+		return nil
+	}
+	return &streamConverter{converter: converter}
+}
+
+func (c *streamConverter) Convert(audio []byte) []byte {
+	if c == nil {
+		return audio
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.converter.Convert(audio)
+}
+
+func (c *streamConverter) Reset() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.converter.Reset()
+}
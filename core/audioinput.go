@@ -12,7 +12,7 @@ func (o *Orchestrator) initAudioInput() {
 	if o.audioInput != nil && o.speechToTextClient != nil {
 		switch o.audioInput.(type) {
 		case AudioInputFine:
-			if o.config.AlwaysRecording {
+			if o.capturesContinuously() {
 				go func() {
 					if err := o.startCapture(); err != nil {
 						log.Printf("Failed to start audio input: %v", err)
@@ -34,13 +34,44 @@ func (o *Orchestrator) sendAudio(audio []byte) error {
 		return nil
 	}
 
-	if o.IsRecording || o.config.AlwaysRecording {
-		return o.speechToTextClient.SendAudio(audio)
+	if o.IsRecording || o.config.AlwaysRecording || o.wakeWord.isEnabled() {
+		if preRoll := o.preRoll.flush(); len(preRoll) > 0 {
+			if err := o.processInputAudio(preRoll); err != nil {
+				log.Printf("Failed to send pre-roll audio: %v", err)
+			}
+		}
+		return o.processInputAudio(audio)
 	}
 
+	o.addToPreRoll(audio)
 	return nil
 }
 
+// processInputAudio records, cleans up and sends the captured audio to the
+// speech to text client
+func (o *Orchestrator) processInputAudio(audio []byte) error {
+	if o.sessionRecorder != nil {
+		o.sessionRecorder.RecordInputAudio(audio)
+	}
+	audio = o.audioFormats.toLocal.Convert(audio)
+	audio = o.cancelEcho(audio)
+	o.detectWakeWord(audio)
+	if o.voiceActivity.enabled {
+		return o.sendAudioThroughVAD(audio)
+	}
+	return o.sendAudioToSpeechToText(audio)
+}
+
+// sendAudioToSpeechToText converts the audio to the speech to text client's
+// encoding and sends it
+func (o *Orchestrator) sendAudioToSpeechToText(audio []byte) error {
+	audio = o.audioFormats.toSpeechToText.Convert(audio)
+	if len(audio) == 0 {
+		return nil
+	}
+	return o.speechToTextClient.SendAudio(audio)
+}
+
 // startCapture start the audio capture for AudioInputFine, does nothing
 // if AudioInputFine interface is not satisfied
 func (o *Orchestrator) startCapture() error {
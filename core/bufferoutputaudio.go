@@ -3,9 +3,13 @@ package orchestration
 import (
 	"context"
 	"log"
+	"math"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/audio/convert"
 	"github.com/koscakluka/ema-core/core/texttospeech"
 )
 
@@ -14,9 +18,17 @@ func (o *Orchestrator) initTTS() {
 		ttsOptions := []texttospeech.TextToSpeechOption{
 			texttospeech.WithAudioCallback(o.outputAudioBuffer.AddAudio),
 			texttospeech.WithAudioEndedCallback(o.outputAudioBuffer.AudioMark),
+			texttospeech.WithConnectionStateCallback(func(state texttospeech.ConnectionState) {
+				if state != texttospeech.ConnectionStateConnected {
+					log.Printf("Text to speech connection %s", state)
+				}
+				if o.orchestrateOptions.onTextToSpeechConnectionStateChanged != nil {
+					o.orchestrateOptions.onTextToSpeechConnectionStateChanged(state)
+				}
+			}),
 		}
-		if o.audioOutput != nil {
-			ttsOptions = append(ttsOptions, texttospeech.WithEncodingInfo(o.audioOutput.EncodingInfo()))
+		if encodingInfo, ok := o.negotiateOutput(); ok {
+			ttsOptions = append(ttsOptions, texttospeech.WithEncodingInfo(encodingInfo))
 		}
 
 		if err := o.textToSpeechClient.OpenStream(context.TODO(), ttsOptions...); err != nil {
@@ -25,14 +37,33 @@ func (o *Orchestrator) initTTS() {
 	}
 }
 
+// closeTTS closes the stream opened by initTTS if the client supports it
+func (o *Orchestrator) closeTTS() {
+	if closer, ok := o.textToSpeechClient.(TextToSpeechStreamCloser); ok {
+		if err := closer.CloseStream(context.TODO()); err != nil {
+			log.Printf("Failed to close text to speech stream: %v", err)
+		}
+	}
+}
+
+// passSpeechToAudioCallback passes the audio of the active turn to the audio
+// callback as the text to speech client produces it
+func (o *Orchestrator) passSpeechToAudioCallback() {
+	for audio := range o.outputAudioBuffer.Listen {
+		o.orchestrateOptions.onAudio(audio)
+	}
+}
+
 func (o *Orchestrator) passSpeechToAudioOutput() {
 bufferReadingLoop:
 	for audioOrMark := range o.outputAudioBuffer.Audio {
 		switch audioOrMark.Type {
 		case "audio":
 			audio := audioOrMark.Audio
-			if o.orchestrateOptions.onAudio != nil {
-				o.orchestrateOptions.onAudio(audio)
+			// NOTE: The recorder isn't a listener since it records the audio
+			// when it is sent to the output, rewound audio included
+			if o.sessionRecorder != nil {
+				o.sessionRecorder.RecordOutputAudio(audio)
 			}
 
 			if o.audioOutput == nil {
@@ -40,11 +71,11 @@ bufferReadingLoop:
 			}
 
 			if !o.IsSpeaking || (o.turns.activeTurn() != nil && o.turns.activeTurn().Cancelled) {
-				o.audioOutput.ClearBuffer()
+				o.clearAudioOutput()
 				break bufferReadingLoop
 			}
 
-			o.audioOutput.SendAudio(audio)
+			o.sendAudioToOutput(audio)
 
 		case "mark":
 			mark := audioOrMark.Mark
@@ -72,7 +103,7 @@ bufferReadingLoop:
 	}()
 
 	if o.orchestrateOptions.onAudioEnded != nil {
-		o.orchestrateOptions.onAudioEnded(o.outputAudioBuffer.audioTranscript)
+		o.orchestrateOptions.onAudioEnded(o.outputAudioBuffer.Transcript())
 	}
 
 	if o.audioOutput == nil {
@@ -83,189 +114,418 @@ bufferReadingLoop:
 	// o.audioOutput.SendAudio([]byte{})
 
 	if !o.IsSpeaking || (o.turns.activeTurn() != nil && o.turns.activeTurn().Cancelled) {
-		o.audioOutput.ClearBuffer()
+		o.clearAudioOutput()
 		return
 	}
 
 }
 
-// TODO: Calculate the error factor based on marks' timestamps
-const errorFactor = 0.5
-
-// TODO: Optimize memory at some point, it is not a great idea to just append
-// to a slice when we already consumed a part of it. But it needs to be synced
-// properly, probably a ring buffer makes sense.
+const (
+	// maxOutputLatency is the longest output latency that is accepted from
+	// marks, longer delays come from the audio arriving slower than it plays
+	maxOutputLatency = time.Second
+	// latencySmoothing is the weight of a new latency measurement
+	latencySmoothing = 0.3
+)
 
+// audioBufferCapacity is the number of audio chunks kept in the buffer. Once
+// it is full, adding audio blocks until the output and all listeners take the
+// oldest chunk, audio that was sent but not confirmed as played is
+// overwritten instead.
+const audioBufferCapacity = 2048
+
+// audioBuffer passes the text to speech audio of the active turn to the
+// output and any number of listeners. It keeps the audio that was sent but
+// not confirmed as played, so a paused turn can be rewound to where the
+// output stopped. Listeners get every chunk once as it is added, pausing and
+// rewinding only affect the output.
 type audioBuffer struct {
 	sampleRate int
+	encoding   audio.Encoding
 
 	chunksDone bool
 
-	audio               [][]byte
+	// audio is addressed by absolute positions, audioConsumed and
+	// audioPlayed are positions in it
+	audio               ring[[]byte]
 	audioConsumed       int
 	audioPlayed         int
 	audioPlayingStarted time.Time
-	audioDone           bool
-	audioTranscript     string
-	audioSignal         *sync.Cond
+	// playingFromIdle is set if the output was idle when the audio playing
+	// since audioPlayingStarted was sent, so its latency delayed playback
+	playingFromIdle bool
+	audioDone       bool
+	audioTranscript strings.Builder
 
-	audioMarks []struct {
-		name     string
-		position int
-	}
+	audioMarks         []audioMark
 	audioMarksConsumed int
 
-	paused       bool
-	pausedSignal *sync.Cond
+	// outputLatency is the delay between sending audio to an idle output and
+	// hearing it, calibrated from marks
+	outputLatency time.Duration
+
+	// turnStart is the position of the first chunk of the active turn
+	turnStart int
+	listeners map[*bufferConsumer]struct{}
+
+	paused bool
+	// consumerDone is set when the output stopped taking audio in the active
+	// turn (or there is no turn yet), adding audio no longer waits for free
+	// space then
+	consumerDone bool
+	// turn is increased on Clear, it ends the consumer of the previous turn
+	turn int
+
+	mu     sync.Mutex
+	signal *sync.Cond
 }
 
 func newAudioBuffer() *audioBuffer {
-	return &audioBuffer{
-		audioSignal:  sync.NewCond(&sync.Mutex{}),
-		pausedSignal: sync.NewCond(&sync.Mutex{}),
+	b := &audioBuffer{
+		audio:        newRing[[]byte](audioBufferCapacity),
 		sampleRate:   1,
+		encoding:     audio.EncodingLinear16,
+		consumerDone: true,
+		listeners:    map[*bufferConsumer]struct{}{},
 	}
+	b.signal = sync.NewCond(&b.mu)
+	return b
 }
 
 func (b *audioBuffer) AddAudio(audio []byte) {
-	b.audio = append(b.audio, audio)
-	b.audioSignal.Broadcast()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// NOTE: Waiting stops when the turn is cleared, the audio then goes to a
+	// buffer nobody is waiting for anymore
+	turn := b.turn
+	for b.audio.Full() && b.turn == turn &&
+		((!b.consumerDone && b.audio.Start() >= b.audioConsumed) || b.slowestListener() == b.audio.Start()) {
+		b.signal.Wait()
+	}
+	b.audio.Push(audio)
+	b.signal.Broadcast()
 }
 
+// Audio yields the audio chunks and marks of the active turn as they are
+// added, until all of it was played or the buffer is cleared
 func (b *audioBuffer) Audio(yield func(audio audioOrMark) bool) {
+	b.mu.Lock()
+	turn := b.turn
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.turn == turn {
+			b.consumerDone = true
+		}
+		b.signal.Broadcast()
+	}()
+
 	for {
-		for {
-			if len(b.audio) == b.audioConsumed {
-				break
-			}
-			for b.paused {
-				b.pausedSignal.L.Lock()
-				b.pausedSignal.Wait()
-				b.pausedSignal.L.Unlock()
-			}
-			audio := b.audio[b.audioConsumed]
-			b.audioConsumed++
-			if !yield(audioOrMark{Type: "audio", Audio: audio}) {
-				return
-			}
-			if b.audioPlayingStarted.IsZero() {
-				b.audioPlayingStarted = time.Now()
-			}
-			for ; b.audioMarksConsumed < len(b.audioMarks); b.audioMarksConsumed++ {
-				if b.audioMarks[b.audioMarksConsumed].position > b.audioConsumed {
-					break
-				}
-				if !yield(audioOrMark{Type: "mark", Mark: b.audioMarks[b.audioMarksConsumed].name}) {
-					return
-				}
-			}
+		audioOrMark, ok := b.next(turn)
+		if !ok || !yield(audioOrMark) {
+			return
 		}
-		for ; b.audioMarksConsumed < len(b.audioMarks); b.audioMarksConsumed++ {
-			if b.audioMarks[b.audioMarksConsumed].position > b.audioConsumed {
-				break
-			}
-			if !yield(audioOrMark{Type: "mark", Mark: b.audioMarks[b.audioMarksConsumed].name}) {
-				return
+	}
+}
+
+// next waits for the next mark or audio chunk to pass to the output, ok is
+// false once the turn's audio is done or the buffer was cleared
+func (b *audioBuffer) next(turn int) (audioOrMark, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.turn == turn {
+		if b.audioMarksConsumed < len(b.audioMarks) && b.audioMarks[b.audioMarksConsumed].position <= b.audioConsumed {
+			mark := b.audioMarks[b.audioMarksConsumed]
+			b.audioMarksConsumed++
+			return audioOrMark{Type: "mark", Mark: mark.name}, true
+		}
+
+		if !b.paused && b.audioConsumed < b.audio.End() {
+			b.audioConsumed = max(b.audioConsumed, b.audio.Start())
+			audio, _ := b.audio.Get(b.audioConsumed)
+			if b.audioPlayingStarted.IsZero() || b.audioConsumed == b.audioPlayed {
+				b.audioPlayingStarted = time.Now()
+				b.playingFromIdle = true
 			}
+			b.audioConsumed++
+			b.signal.Broadcast()
+			return audioOrMark{Type: "audio", Audio: audio}, true
 		}
+
 		if b.audioDone {
+			break
+		}
+		b.signal.Wait()
+	}
+	return audioOrMark{}, false
+}
+
+// Listen yields the audio chunks of the active turn in the order they were
+// added, starting with the first one, until all of the turn's audio was
+// played or the buffer is cleared. It can be called by multiple listeners at
+// the same time.
+func (b *audioBuffer) Listen(yield func(audio []byte) bool) {
+	b.mu.Lock()
+	turn := b.turn
+	listener := &bufferConsumer{position: b.turnStart}
+	b.listeners[listener] = struct{}{}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.listeners, listener)
+		b.discard()
+		b.signal.Broadcast()
+	}()
+
+	for {
+		audio, ok := b.nextListened(turn, listener)
+		if !ok || !yield(audio) {
 			return
 		}
-		b.audioSignal.L.Lock()
-		b.audioSignal.Wait()
-		b.audioSignal.L.Unlock()
 	}
 }
 
+// nextListened waits for the listener's next audio chunk, ok is false once
+// the turn's audio was played or the buffer was cleared
+func (b *audioBuffer) nextListened(turn int, listener *bufferConsumer) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.turn == turn {
+		if listener.position < b.audio.End() {
+			// NOTE: A listener that started late skips the audio that was
+			// already played and read by the other listeners
+			listener.position = max(listener.position, b.audio.Start())
+			audio, _ := b.audio.Get(listener.position)
+			listener.position++
+			b.discard()
+			b.signal.Broadcast()
+			return audio, true
+		}
+
+		if b.audioDone {
+			break
+		}
+		b.signal.Wait()
+	}
+	return nil, false
+}
+
+// slowestListener returns the lowest read position of the listeners, the end
+// of the buffer if there are none. b.mu has to be held when calling it.
+func (b *audioBuffer) slowestListener() int {
+	position := b.audio.End()
+	for listener := range b.listeners {
+		position = min(position, listener.position)
+	}
+	return position
+}
+
+// discard drops the audio that was played and read by all listeners, b.mu
+// has to be held when calling it
+func (b *audioBuffer) discard() {
+	b.audio.Discard(min(b.audioPlayed, b.slowestListener()))
+}
+
 func (b *audioBuffer) AudioMark(name string) {
-	b.audioMarks = append(b.audioMarks, struct {
-		name     string
-		position int
-	}{
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.audioMarks = append(b.audioMarks, audioMark{
 		name:     name,
-		position: len(b.audio),
+		position: b.audio.End(),
 	})
-	b.audioSignal.Broadcast()
+	b.signal.Broadcast()
 }
 
 func (b *audioBuffer) MarkPlayed(name string) {
-	for _, mark := range b.audioMarks {
-		if mark.name == name {
-			// "duration", audioDuration(b.audio[b.audioPlayed:mark.position], b.sampleRate),
-			// "actual_duration", time.Since(b.audioPlayingStarted),
-			b.audioTranscript += name
-			b.audioPlayed = mark.position
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, mark := range b.audioMarks {
+		if mark.name == name && !mark.played {
+			if b.playingFromIdle && !b.audioPlayingStarted.IsZero() && mark.position > b.audioPlayed {
+				b.calibrateLatency(time.Since(b.audioPlayingStarted) - b.audioDuration(b.audioLen(b.audioPlayed, mark.position)))
+			}
+			b.markPlayed(i)
 			b.audioPlayingStarted = time.Now()
-			if b.chunksDone && b.audioPlayed == len(b.audio) {
+			b.playingFromIdle = false
+			if b.chunksDone && b.audioPlayed == b.audio.End() {
 				b.audioDone = true
-				b.audioSignal.Broadcast()
+				b.signal.Broadcast()
 			}
 			break
 		}
 	}
 }
 
+// markPlayed records the mark as played and drops the audio before it, b.mu
+// has to be held when calling it
+func (b *audioBuffer) markPlayed(i int) {
+	b.audioMarks[i].played = true
+	b.audioTranscript.WriteString(b.audioMarks[i].name)
+	b.audioPlayed = max(b.audioPlayed, b.audioMarks[i].position)
+	b.discard()
+}
+
+// calibrateLatency updates the output latency with the difference between
+// how long it took for a mark to be played and the duration of the audio
+// before it
+func (b *audioBuffer) calibrateLatency(measured time.Duration) {
+	if measured < 0 || measured > maxOutputLatency {
+		return
+	}
+
+	if b.outputLatency == 0 {
+		b.outputLatency = measured
+		return
+	}
+	b.outputLatency += time.Duration(latencySmoothing * float64(measured-b.outputLatency))
+}
+
 func (b *audioBuffer) ChunksDone() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	b.chunksDone = true
 }
 
-func (b *audioBuffer) PauseAudio() {
+// PauseAudio stops passing audio and rewinds to where the output stopped
+// playing. If the output reported how much of the sent audio it didn't play
+// yet (positionKnown) it is used, otherwise the played audio is estimated from
+// the time since it was sent.
+func (b *audioBuffer) PauseAudio(unplayed time.Duration, positionKnown bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	if b.audioDone {
 		return
 	}
 
+	if b.paused {
+		return
+	}
+
 	b.paused = true
-	// TODO: Account for the latency of the audio sink (i.e. time it takes from
-	// when audio leaves the buffer to when it is actually played + the time
-	// it takes for use to receive the information that the audio was played)
-	// TODO: Consider identifying silences in the audio so we can continue from
-	// there and make the unpausing seem smoother (as a human would do)
-	playedDuration := time.Since(b.audioPlayingStarted)
-	samplesPlayed := audioSamples(playedDuration, b.sampleRate)
-	chunksPlayed := 0
-	for _, chunk := range b.audio[b.audioPlayed:] {
-		samplesPlayed -= len(chunk)
-		if samplesPlayed < 0 {
-			// TODO: See what to do with underplayed audio, so far it hasn't
-			// been an issue
+	// NOTE: Sent audio that was overwritten can't be rewound to, it is
+	// treated as played
+	sentStart := max(b.audioPlayed, b.audio.Start())
+	bytesPlayed := 0
+	if positionKnown {
+		bytesPlayed = b.audioLen(sentStart, b.audioConsumed) - b.audioBytes(unplayed)
+	} else if !b.audioPlayingStarted.IsZero() {
+		playedDuration := time.Since(b.audioPlayingStarted)
+		if b.playingFromIdle {
+			playedDuration -= b.outputLatency
+		}
+		bytesPlayed = b.audioBytes(playedDuration)
+	}
+	played := sentStart
+	for ; played < b.audioConsumed; played++ {
+		chunk, _ := b.audio.Get(played)
+		bytesPlayed -= len(chunk)
+		if bytesPlayed < 0 {
 			break
 		}
-		chunksPlayed++
 	}
-	b.audioPlayed += chunksPlayed
-	b.audioConsumed = b.audioPlayed
+
+	// NOTE: Marks the output reached before it was cleared might not have
+	// been confirmed yet, with a known position they are counted as played
+	// so the transcript matches what was heard
+	if positionKnown {
+		for i, mark := range b.audioMarks {
+			if !mark.played && mark.position <= played {
+				b.markPlayed(i)
+			}
+		}
+	}
+
+	// NOTE: Marks that weren't confirmed as played were dropped by the audio
+	// output, so they need to be passed again
+	b.audioMarksConsumed = len(b.audioMarks)
 	for i, mark := range b.audioMarks {
-		if mark.position > b.audioConsumed {
+		if !mark.played {
 			b.audioMarksConsumed = i
 			break
 		}
 	}
-	b.pausedSignal.Broadcast()
+	b.audioPlayed = b.resumePosition(played)
+	b.audioConsumed = b.audioPlayed
+	b.signal.Broadcast()
+}
+
+// resumePosition finds the chunk from which paused audio should continue. It
+// is the closest sentence end (mark) or silence before the played position,
+// so the resumed speech doesn't start mid word. Audio that was confirmed as
+// played is never repeated.
+func (b *audioBuffer) resumePosition(played int) int {
+	boundary := max(b.audioPlayed, b.audio.Start())
+	for _, mark := range b.audioMarks {
+		if mark.position > played {
+			break
+		}
+		boundary = max(boundary, mark.position)
+	}
+
+	for i := min(played, b.audio.End()-1); i > boundary; i-- {
+		if chunk, ok := b.audio.Get(i); ok && isSilence(chunk, b.encoding) {
+			return i
+		}
+	}
+	return boundary
 }
 
 func (b *audioBuffer) UnpauseAudio() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.paused {
+		return
+	}
+
 	b.paused = false
 	b.audioPlayingStarted = time.Time{}
-	b.pausedSignal.Broadcast()
-	b.audioSignal.Broadcast()
+	b.signal.Broadcast()
 }
 
+// Clear drops the audio and marks of the previous turn and ends its consumer
 func (b *audioBuffer) Clear() {
-	// TODO: This should probably be locked
-	b.chunksDone = true
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.turn++
+	b.chunksDone = false
 	b.paused = false
-	b.audio = [][]byte{}
-	b.audioConsumed = 0
-	b.audioDone = true
-	b.audioSignal.Broadcast()
+	b.consumerDone = false
+	b.audio.DiscardAll()
+	b.turnStart = b.audio.End()
+	b.audioConsumed = b.audio.End()
+	b.audioPlayed = b.audio.End()
 	b.audioDone = false
-	b.audioMarks = []struct {
-		name     string
-		position int
-	}{}
+	b.audioTranscript.Reset()
+	b.audioMarks = nil
 	b.audioMarksConsumed = 0
-	b.audioPlayed = 0
 	b.audioPlayingStarted = time.Time{}
+	b.signal.Broadcast()
+}
+
+// Transcript returns the text of the marks played in the active turn
+func (b *audioBuffer) Transcript() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.audioTranscript.String()
+}
+
+type audioMark struct {
+	name string
+	// position is the position of the first audio chunk after the mark
+	position int
+	played   bool
 }
 
 type audioOrMark struct {
@@ -274,18 +534,47 @@ type audioOrMark struct {
 	Mark  string
 }
 
-func audioLen(audio [][]byte) int {
-	chunksTotalLength := 0
-	for _, audioChunk := range audio {
-		chunksTotalLength += len(audioChunk)
+// audioLen returns the number of bytes in the stored chunks between the
+// positions
+func (b *audioBuffer) audioLen(from, to int) int {
+	length := 0
+	for i := from; i < to; i++ {
+		chunk, _ := b.audio.Get(i)
+		length += len(chunk)
+	}
+	return length
+}
+
+func (b *audioBuffer) audioDuration(bytes int) time.Duration {
+	bytesPerSecond := b.sampleRate * b.encoding.BytesPerSample()
+	if bytesPerSecond <= 0 {
+		return 0
 	}
-	return chunksTotalLength
+	return time.Duration(bytes) * time.Second / time.Duration(bytesPerSecond)
 }
 
-func audioDuration(audio [][]byte, sampleRate int) time.Duration {
-	return time.Duration(float64(audioLen(audio)) / float64(sampleRate) * float64(time.Second) * errorFactor)
+func (b *audioBuffer) audioBytes(duration time.Duration) int {
+	if duration <= 0 {
+		return 0
+	}
+	return int(duration.Seconds()*float64(b.sampleRate)) * b.encoding.BytesPerSample()
 }
 
-func audioSamples(duration time.Duration, sampleRate int) int {
-	return int(float64(duration) / float64(time.Second) * float64(sampleRate) / errorFactor)
+// silenceThreshold is the RMS amplitude of a linear16 chunk under which it is
+// considered silent
+const silenceThreshold = 500
+
+// isSilence checks if the audio chunk is quiet enough to be considered a
+// pause in speech
+func isSilence(chunk []byte, encoding audio.Encoding) bool {
+	samples := convert.Decode(chunk, encoding)
+	if len(samples) == 0 {
+		return false
+	}
+
+	var sumOfSquares float64
+	for _, sample := range samples {
+		sumOfSquares += sample * sample
+	}
+	return math.Sqrt(sumOfSquares/float64(len(samples)))*32768 < silenceThreshold
 }
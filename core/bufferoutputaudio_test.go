@@ -0,0 +1,112 @@
+package orchestration
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// audioFrame is 20ms of linear16 mono audio at 24 kHz
+var audioFrame = make([]byte, 960)
+
+// playAudio consumes the buffer's audio like an output that plays it
+// instantly, confirming every mark right away
+func playAudio(b *audioBuffer, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for audioOrMark := range b.Audio {
+		if audioOrMark.Type == "mark" {
+			b.MarkPlayed(audioOrMark.Mark)
+		}
+	}
+}
+
+// listenAudio consumes the buffer's audio like a listener, counting the
+// received frames
+func listenAudio(b *audioBuffer, wg *sync.WaitGroup, frames *int) {
+	defer wg.Done()
+	for range b.Listen {
+		*frames++
+	}
+}
+
+func BenchmarkAudioBuffer(b *testing.B) {
+	for _, listeners := range []int{0, 1, 3} {
+		b.Run(fmt.Sprintf("listeners=%d", listeners), func(b *testing.B) {
+			buffer := newAudioBuffer()
+			buffer.Clear()
+
+			var wg sync.WaitGroup
+			wg.Add(1 + listeners)
+			go playAudio(buffer, &wg)
+			frames := make([]int, listeners)
+			for i := range listeners {
+				go listenAudio(buffer, &wg, &frames[i])
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := range b.N {
+				buffer.AddAudio(audioFrame)
+				// NOTE: Sentences are around a second of audio
+				if i%50 == 49 {
+					buffer.AudioMark("sentence")
+				}
+			}
+			buffer.ChunksDone()
+			buffer.AudioMark("end")
+			wg.Wait()
+		})
+	}
+}
+
+func TestAudioBufferListeners(t *testing.T) {
+	buffer := newAudioBuffer()
+	buffer.sampleRate = 24000
+	buffer.Clear()
+
+	const frameCount = 3 * audioBufferCapacity
+	var wg sync.WaitGroup
+	wg.Add(3)
+	frames := make([]int, 2)
+	go listenAudio(buffer, &wg, &frames[0])
+	go listenAudio(buffer, &wg, &frames[1])
+	go playAudio(buffer, &wg)
+	for listening := false; !listening; time.Sleep(time.Millisecond) {
+		buffer.mu.Lock()
+		listening = len(buffer.listeners) == 2
+		buffer.mu.Unlock()
+	}
+
+	for i := range frameCount {
+		buffer.AddAudio(audioFrame)
+		if i == frameCount/2 {
+			// NOTE: Rewinding replays audio to the output, but not to the
+			// listeners
+			buffer.PauseAudio(time.Second, true)
+			buffer.UnpauseAudio()
+		}
+		if i%50 == 49 {
+			buffer.AudioMark("sentence")
+		}
+	}
+	buffer.ChunksDone()
+	buffer.AudioMark("end")
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumers didn't finish")
+	}
+
+	for i, got := range frames {
+		if got != frameCount {
+			t.Errorf("listener %d got %d frames, want %d", i, got, frameCount)
+		}
+	}
+}
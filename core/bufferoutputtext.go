@@ -16,14 +16,14 @@ textLoop:
 			break textLoop
 		}
 		if activeTurn != nil && activeTurn.Stage != llms.TurnStageSpeaking {
-			activeTurn.Stage = llms.TurnStageSpeaking
-			o.turns.updateActiveTurn(*activeTurn)
+			o.turns.updateActiveTurn(func(turn *llms.Turn) { turn.Stage = llms.TurnStageSpeaking })
 		}
 
 		if o.orchestrateOptions.onResponse != nil {
 			o.orchestrateOptions.onResponse(chunk)
 		}
 		if o.textToSpeechClient != nil {
+			o.echoSuppression.addSpokenText(chunk)
 			if err := o.textToSpeechClient.SendText(chunk); err != nil {
 				log.Printf("Failed to send text to deepgram: %v", err)
 			}
@@ -55,63 +55,130 @@ textLoop:
 
 }
 
-// TODO: Optimize memory at some point, it is not a great idea to just append
-// to a slice when we already consumed a part of it. But it needs to be synced
-// properly, probably a ring buffer makes sense.
+// textBufferCapacity is the number of chunks kept in the buffer. Once it is
+// full, adding text blocks until the slowest consumer catches up.
+const textBufferCapacity = 1024
 
+// textBuffer passes the response text of the active turn to any number of
+// consumers. Every consumer gets all chunks of the turn and the chunks are
+// dropped once all consumers read them.
 type textBuffer struct {
-	chunks         []string
-	chunksConsumed int
-	chunksDone     bool
-	chunksSignal   *sync.Cond
+	chunks ring[string]
+	// turnStart is the position of the first chunk of the active turn
+	turnStart  int
+	chunksDone bool
+	// turn is increased on Clear, it ends the consumers of the previous turn
+	turn      int
+	consumers map[*bufferConsumer]struct{}
+
+	mu     sync.Mutex
+	signal *sync.Cond
+}
+
+// bufferConsumer is the read position of a single consumer
+type bufferConsumer struct {
+	position int
 }
 
 func newTextBuffer() *textBuffer {
-	return &textBuffer{
-		chunksSignal: sync.NewCond(&sync.Mutex{}),
+	b := &textBuffer{
+		chunks:    newRing[string](textBufferCapacity),
+		consumers: map[*bufferConsumer]struct{}{},
 	}
+	b.signal = sync.NewCond(&b.mu)
+	return b
 }
 
 func (b *textBuffer) AddChunk(chunk string) {
-	b.chunks = append(b.chunks, chunk)
-	b.chunksSignal.Broadcast()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// NOTE: Waiting stops when the turn is cleared, its consumers are done
+	// reading then
+	turn := b.turn
+	for b.chunks.Full() && b.slowestConsumer() == b.chunks.Start() && b.turn == turn {
+		b.signal.Wait()
+	}
+	b.chunks.Push(chunk)
+	b.signal.Broadcast()
 }
 
 func (b *textBuffer) ChunksDone() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	b.chunksDone = true
-	b.chunksSignal.Broadcast()
+	b.signal.Broadcast()
 }
 
+// Chunks yields the chunks of the active turn, starting with the first one,
+// until the turn's text is done or the buffer is cleared. It can be called by
+// multiple consumers at the same time.
 func (b *textBuffer) Chunks(yield func(string) bool) {
+	b.mu.Lock()
+	turn := b.turn
+	consumer := &bufferConsumer{position: b.turnStart}
+	b.consumers[consumer] = struct{}{}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.consumers, consumer)
+		b.signal.Broadcast()
+	}()
+
 	for {
-		for {
-			if len(b.chunks) == b.chunksConsumed {
-				break
-			}
-			chunk := b.chunks[b.chunksConsumed]
-			b.chunksConsumed++
-			if !yield(chunk) {
-				return
-			}
-		}
-		if b.chunksDone && b.chunksConsumed == len(b.chunks) {
+		chunk, ok := b.next(turn, consumer)
+		if !ok || !yield(chunk) {
 			return
 		}
-		b.chunksSignal.L.Lock()
-		b.chunksSignal.Wait()
-		b.chunksSignal.L.Unlock()
 	}
 }
 
-func (b *textBuffer) AllChunks() string {
-	return strings.Join(b.chunks, "")
+// next waits for the consumer's next chunk, ok is false once the turn's text
+// is done or the buffer was cleared
+func (b *textBuffer) next(turn int, consumer *bufferConsumer) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.turn == turn {
+		if consumer.position < b.chunks.End() {
+			// NOTE: Chunks all other consumers read are dropped, a consumer
+			// that started late skips them
+			consumer.position = max(consumer.position, b.chunks.Start())
+			chunk, _ := b.chunks.Get(consumer.position)
+			consumer.position++
+			b.chunks.Discard(b.slowestConsumer())
+			b.signal.Broadcast()
+			return chunk, true
+		}
+
+		if b.chunksDone {
+			break
+		}
+		b.signal.Wait()
+	}
+	return "", false
+}
+
+// slowestConsumer returns the lowest read position of the consumers, the end
+// of the buffer if there are none. b.mu has to be held when calling it.
+func (b *textBuffer) slowestConsumer() int {
+	position := b.chunks.End()
+	for consumer := range b.consumers {
+		position = min(position, consumer.position)
+	}
+	return position
 }
 
+// Clear drops the chunks of the previous turn and ends its consumers
 func (b *textBuffer) Clear() {
-	// TODO: This should probably be locked
-	b.chunks = []string{}
-	b.chunksConsumed = 0
-	b.chunksDone = true
-	b.chunksSignal.Broadcast()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.turn++
+	b.chunks.DiscardAll()
+	b.turnStart = b.chunks.End()
 	b.chunksDone = false
+	b.signal.Broadcast()
 }
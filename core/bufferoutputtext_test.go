@@ -0,0 +1,34 @@
+package orchestration
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func BenchmarkTextBuffer(b *testing.B) {
+	for _, consumers := range []int{1, 3} {
+		b.Run(fmt.Sprintf("consumers=%d", consumers), func(b *testing.B) {
+			buffer := newTextBuffer()
+			buffer.Clear()
+
+			var wg sync.WaitGroup
+			wg.Add(consumers)
+			for range consumers {
+				go func() {
+					defer wg.Done()
+					for range buffer.Chunks {
+					}
+				}()
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				buffer.AddChunk("word ")
+			}
+			buffer.ChunksDone()
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+
+	orchestration "github.com/koscakluka/ema-core/core"
+	"github.com/koscakluka/ema-core/core/audio/miniaudio"
+	llmInterruptionsHandler "github.com/koscakluka/ema-core/core/interruptions/llm"
+	rulesInterruptionsHandler "github.com/koscakluka/ema-core/core/interruptions/rules"
+	"github.com/koscakluka/ema-core/core/speechtotext"
+	deepgramstt "github.com/koscakluka/ema-core/core/speechtotext/deepgram"
+	whisperstt "github.com/koscakluka/ema-core/core/speechtotext/whisper"
+	deepgramt2s "github.com/koscakluka/ema-core/core/texttospeech/deepgram"
+)
+
+// NewOrchestrator creates an orchestrator with the clients the config
+// describes. The release function closes the clients and then the
+// orchestrator.
+func (c *Config) NewOrchestrator(ctx context.Context) (*orchestration.Orchestrator, func(), error) {
+	opts, releaseClients, err := c.Build(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	orchestrator := orchestration.NewOrchestrator(opts...)
+	return orchestrator, func() {
+		// NOTE: The clients are closed first so they don't send anything
+		// to the closed orchestrator
+		releaseClients()
+		orchestrator.Close()
+	}, nil
+}
+
+// Build creates the clients the config describes and returns the options
+// passing them to NewOrchestrator, the release function closes the clients
+func (c *Config) Build(ctx context.Context) (opts []orchestration.OrchestratorOption, release func(), err error) {
+	var closers []func()
+	release = func() {
+		for _, close := range slices.Backward(closers) {
+			close()
+		}
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	llm, err := newLLM(c.LLM, defaultLLMModels)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create llm client: %w", err)
+	}
+	streamingLLM, ok := llm.(orchestration.LLMWithStream)
+	if !ok {
+		return nil, nil, fmt.Errorf("llm %q doesn't support streaming", c.LLM.Model)
+	}
+	opts = append(opts, orchestration.WithStreamingLLM(streamingLLM))
+
+	switch c.SpeechToText.Provider {
+	case "", ProviderDeepgram:
+		clientOpts := []deepgramstt.ClientOption{}
+		if c.SpeechToText.APIKey != "" {
+			clientOpts = append(clientOpts, deepgramstt.WithAPIKey(c.SpeechToText.APIKey))
+		}
+		client := deepgramstt.NewClient(ctx, clientOpts...)
+		closers = append(closers, func() { client.Close() })
+		opts = append(opts, orchestration.WithSpeechToTextClient(client, c.transcriptionOptions()...))
+	case ProviderWhisper:
+		client := whisperstt.NewClient(ctx, whisperstt.WithServerURL(c.SpeechToText.ServerURL))
+		closers = append(closers, func() { client.Close() })
+		opts = append(opts, orchestration.WithSpeechToTextClient(client, c.transcriptionOptions()...))
+	}
+
+	switch c.TextToSpeech.Provider {
+	case "", ProviderDeepgram:
+		voice := deepgramt2s.VoiceAuraAsteria
+		for _, availableVoice := range deepgramt2s.GetAvailableVoices() {
+			if string(availableVoice) == c.TextToSpeech.Voice {
+				voice = availableVoice
+			}
+		}
+		clientOpts := []deepgramt2s.TextToSpeechOption{}
+		if c.TextToSpeech.APIKey != "" {
+			clientOpts = append(clientOpts, deepgramt2s.WithAPIKey(c.TextToSpeech.APIKey))
+		}
+		client, err := deepgramt2s.NewTextToSpeechClient(ctx, voice, clientOpts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create deepgram speech client: %w", err)
+		}
+		closers = append(closers, func() { client.Close(context.Background()) })
+		opts = append(opts, orchestration.WithTextToSpeechClient(client))
+	}
+
+	switch c.Audio.Provider {
+	case "", ProviderMiniaudio:
+		clientOpts := []miniaudio.ClientOption{}
+		if c.Audio.PlaybackDevice != "" {
+			clientOpts = append(clientOpts, miniaudio.WithPlaybackDevice(c.Audio.PlaybackDevice))
+		}
+		if c.Audio.CaptureDevice != "" {
+			clientOpts = append(clientOpts, miniaudio.WithCaptureDevice(c.Audio.CaptureDevice))
+		}
+		if c.Audio.SampleRate != 0 {
+			clientOpts = append(clientOpts, miniaudio.WithSampleRate(c.Audio.SampleRate))
+		}
+		client, err := miniaudio.NewClient(clientOpts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create audio client: %w", err)
+		}
+		closers = append(closers, client.Close)
+		opts = append(opts,
+			orchestration.WithAudioInput(client),
+			orchestration.WithAudioOutputV1(client),
+		)
+	}
+
+	handler, err := c.interruptionHandler()
+	if err != nil {
+		return nil, nil, err
+	}
+	opts = append(opts, orchestration.WithInterruptionHandlerV1(handler))
+
+	if c.Tools.Orchestration == nil || *c.Tools.Orchestration {
+		opts = append(opts, orchestration.WithOrchestrationTools())
+	}
+	opts = append(opts, orchestration.WithConfig(&orchestration.Config{
+		AlwaysRecording: c.Orchestrator.AlwaysRecording,
+	}))
+	if wakeWord := c.Orchestrator.WakeWord; wakeWord.Phrase != "" {
+		wakeWordOpts := []orchestration.WakeWordOption{
+			orchestration.WithWakePhraseVariants(wakeWord.Variants...),
+		}
+		if wakeWord.FollowUpWindow > 0 {
+			wakeWordOpts = append(wakeWordOpts, orchestration.WithFollowUpWindow(wakeWord.FollowUpWindow))
+		}
+		if wakeWord.DisableEarcon {
+			wakeWordOpts = append(wakeWordOpts, orchestration.WithoutEarcon())
+		}
+		opts = append(opts, orchestration.WithWakeWord(wakeWord.Phrase, wakeWordOpts...))
+	}
+	if c.Orchestrator.PreRoll > 0 {
+		opts = append(opts, orchestration.WithPreRoll(c.Orchestrator.PreRoll))
+	}
+
+	return opts, release, nil
+}
+
+func (c *Config) transcriptionOptions() []speechtotext.TranscriptionOption {
+	opts := []speechtotext.TranscriptionOption{}
+	if c.SpeechToText.Language != "" {
+		opts = append(opts, speechtotext.WithLanguage(c.SpeechToText.Language))
+	}
+	if c.SpeechToText.Model != "" {
+		opts = append(opts, speechtotext.WithModel(c.SpeechToText.Model))
+	}
+	for _, keyword := range c.SpeechToText.Keywords {
+		opts = append(opts, speechtotext.WithKeywords(speechtotext.Keyword{Term: keyword}))
+	}
+	return opts
+}
+
+func (c *Config) interruptionHandler() (*rulesInterruptionsHandler.InterruptionHandler, error) {
+	handlerOpts := []rulesInterruptionsHandler.InterruptionHandlerOption{}
+	if len(c.Interruptions.Rules) > 0 {
+		rules := make([]rulesInterruptionsHandler.Rule, 0, len(c.Interruptions.Rules))
+		for i, ruleConfig := range c.Interruptions.Rules {
+			rule := rulesInterruptionsHandler.Phrases(ruleConfig.Type, ruleConfig.Phrases...)
+			if ruleConfig.Pattern != "" {
+				pattern, err := regexp.Compile(ruleConfig.Pattern)
+				if err != nil {
+					return nil, fmt.Errorf("interruptions.rules[%d]: invalid pattern: %w", i, err)
+				}
+				rule.Pattern = pattern
+			}
+			rules = append(rules, rule)
+		}
+		handlerOpts = append(handlerOpts, rulesInterruptionsHandler.WithRules(rules...))
+	}
+
+	if c.Interruptions.Classifier.Provider != ProviderNone {
+		classifier, err := newLLM(c.Interruptions.Classifier, defaultClassifierModels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create interruption classifier client: %w", err)
+		}
+
+		switch classifier := classifier.(type) {
+		case llmInterruptionsHandler.LLMWithStructuredPrompt:
+			handlerOpts = append(handlerOpts, rulesInterruptionsHandler.WithFallback(
+				llmInterruptionsHandler.NewInterruptionHandlerWithStructuredPrompt(classifier),
+			))
+		case llmInterruptionsHandler.LLMWithGeneralPrompt:
+			handlerOpts = append(handlerOpts, rulesInterruptionsHandler.WithFallback(
+				llmInterruptionsHandler.NewInterruptionHandlerWithGeneralPrompt(classifier),
+			))
+		default:
+			return nil, fmt.Errorf("interruption classifier %q can't classify interruptions", c.Interruptions.Classifier.Model)
+		}
+	}
+
+	return rulesInterruptionsHandler.NewInterruptionHandler(handlerOpts...), nil
+}
+
+// validDeepgramVoice reports whether the voice is empty, i.e. the default
+// voice, or one of Deepgram's voices
+func validDeepgramVoice(name string) bool {
+	if name == "" {
+		return true
+	}
+	for _, voice := range deepgramt2s.GetAvailableVoices() {
+		if string(voice) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+// Package config builds orchestrators from declarative YAML or JSON files, so
+// switching providers, models or voices doesn't need a recompile.
+//
+// A config file looks like:
+//
+//	llm:
+//	  provider: groq
+//	  model: llama-3.3-70b-versatile
+//	  apiKey: ${GROQ_API_KEY}
+//	  systemPrompt: You are a helpful assistant.
+//	speechToText:
+//	  provider: deepgram
+//	  language: en
+//	textToSpeech:
+//	  provider: deepgram
+//	  voice: aura-asteria-en
+//	audio:
+//	  provider: miniaudio
+//	  playbackDevice: ${PLAYBACK_DEVICE_ID:-}
+//	interruptions:
+//	  classifier:
+//	    provider: groq
+//	    model: openai/gpt-oss-20b
+//	tools:
+//	  orchestration: true
+//	orchestrator:
+//	  alwaysRecording: false
+//	  wakeWord:
+//	    phrase: hey EMA
+//	    variants: [hey emma]
+//	    followUpWindow: 10s
+//
+// Every section is optional, the defaults match the ema command. Providers can
+// be set to "none" to leave the component out. String values can reference
+// environment variables as ${NAME}, or ${NAME:-default} if the variable is
+// optional, which keeps secrets out of the file.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ProviderNone      = "none"
+	ProviderGroq      = "groq"
+	ProviderOpenAI    = "openai"
+	ProviderDeepgram  = "deepgram"
+	ProviderWhisper   = "whisper"
+	ProviderMiniaudio = "miniaudio"
+)
+
+type Config struct {
+	LLM           LLMConfig           `yaml:"llm" json:"llm"`
+	SpeechToText  SpeechToTextConfig  `yaml:"speechToText" json:"speechToText"`
+	TextToSpeech  TextToSpeechConfig  `yaml:"textToSpeech" json:"textToSpeech"`
+	Audio         AudioConfig         `yaml:"audio" json:"audio"`
+	Interruptions InterruptionsConfig `yaml:"interruptions" json:"interruptions"`
+	Tools         ToolsConfig         `yaml:"tools" json:"tools"`
+	Orchestrator  OrchestratorConfig  `yaml:"orchestrator" json:"orchestrator"`
+}
+
+type LLMConfig struct {
+	// Provider is groq (default) or openai
+	Provider string `yaml:"provider" json:"provider"`
+	// Model is the provider's model name, empty uses the provider's default
+	Model        string `yaml:"model" json:"model"`
+	APIKey       string `yaml:"apiKey" json:"apiKey"`
+	SystemPrompt string `yaml:"systemPrompt" json:"systemPrompt"`
+}
+
+type SpeechToTextConfig struct {
+	// Provider is deepgram (default), whisper or none
+	Provider string `yaml:"provider" json:"provider"`
+	APIKey   string `yaml:"apiKey" json:"apiKey"`
+	// ServerURL is the address of the whisper server
+	ServerURL string   `yaml:"serverURL" json:"serverURL"`
+	Language  string   `yaml:"language" json:"language"`
+	Model     string   `yaml:"model" json:"model"`
+	Keywords  []string `yaml:"keywords" json:"keywords"`
+}
+
+type TextToSpeechConfig struct {
+	// Provider is deepgram (default) or none
+	Provider string `yaml:"provider" json:"provider"`
+	APIKey   string `yaml:"apiKey" json:"apiKey"`
+	Voice    string `yaml:"voice" json:"voice"`
+}
+
+type AudioConfig struct {
+	// Provider is miniaudio (default) or none
+	Provider string `yaml:"provider" json:"provider"`
+	// PlaybackDevice and CaptureDevice are device IDs, empty uses the system
+	// default device
+	PlaybackDevice string `yaml:"playbackDevice" json:"playbackDevice"`
+	CaptureDevice  string `yaml:"captureDevice" json:"captureDevice"`
+	SampleRate     int    `yaml:"sampleRate" json:"sampleRate"`
+}
+
+type InterruptionsConfig struct {
+	// Rules replace the default interruption rules
+	Rules []RuleConfig `yaml:"rules" json:"rules"`
+	// Classifier classifies the interruptions no rule matched, its provider
+	// can be none to only use the rules
+	Classifier LLMConfig `yaml:"classifier" json:"classifier"`
+}
+
+type RuleConfig struct {
+	Type    string   `yaml:"type" json:"type"`
+	Phrases []string `yaml:"phrases" json:"phrases"`
+	Pattern string   `yaml:"pattern" json:"pattern"`
+}
+
+type ToolsConfig struct {
+	// Orchestration adds the tools controlling the orchestrator, e.g.
+	// recording and speaking, defaults to true
+	Orchestration *bool `yaml:"orchestration" json:"orchestration"`
+}
+
+type OrchestratorConfig struct {
+	AlwaysRecording bool           `yaml:"alwaysRecording" json:"alwaysRecording"`
+	WakeWord        WakeWordConfig `yaml:"wakeWord" json:"wakeWord"`
+	// PreRoll is the audio kept from before push to talk, e.g. "300ms"
+	PreRoll time.Duration `yaml:"preRoll" json:"preRoll"`
+}
+
+type WakeWordConfig struct {
+	// Phrase turns on the wake word mode, e.g. "hey EMA"
+	Phrase string `yaml:"phrase" json:"phrase"`
+	// Variants are other spellings the phrase might be transcribed as
+	Variants []string `yaml:"variants" json:"variants"`
+	// FollowUpWindow is how long after a response the user can speak
+	// without the phrase, e.g. "10s"
+	FollowUpWindow time.Duration `yaml:"followUpWindow" json:"followUpWindow"`
+	DisableEarcon  bool          `yaml:"disableEarcon" json:"disableEarcon"`
+}
+
+// Load reads and validates the config file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	config, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Parse parses and validates a YAML or JSON config, the environment variables
+// it references are expanded
+func Parse(data []byte) (*Config, error) {
+	config := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if err := errors.Join(expandEnv(config), config.Validate()); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate checks the config for unknown providers, models and voices and
+// invalid rules, all problems are reported at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if err := validateLLM(c.LLM, false); err != nil {
+		errs = append(errs, fmt.Errorf("llm: %w", err))
+	}
+
+	switch c.SpeechToText.Provider {
+	case "", ProviderDeepgram, ProviderNone:
+	case ProviderWhisper:
+		if c.SpeechToText.ServerURL == "" {
+			errs = append(errs, fmt.Errorf("speechToText: serverURL is required for whisper"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("speechToText: unknown provider %q, expected deepgram, whisper or none", c.SpeechToText.Provider))
+	}
+
+	switch c.TextToSpeech.Provider {
+	case "", ProviderDeepgram:
+		if !validDeepgramVoice(c.TextToSpeech.Voice) {
+			errs = append(errs, fmt.Errorf("textToSpeech: unknown deepgram voice %q", c.TextToSpeech.Voice))
+		}
+	case ProviderNone:
+	default:
+		errs = append(errs, fmt.Errorf("textToSpeech: unknown provider %q, expected deepgram or none", c.TextToSpeech.Provider))
+	}
+
+	switch c.Audio.Provider {
+	case "", ProviderMiniaudio, ProviderNone:
+	default:
+		errs = append(errs, fmt.Errorf("audio: unknown provider %q, expected miniaudio or none", c.Audio.Provider))
+	}
+	if c.Audio.SampleRate < 0 {
+		errs = append(errs, fmt.Errorf("audio: sampleRate must be positive"))
+	}
+
+	if c.Orchestrator.WakeWord.FollowUpWindow < 0 {
+		errs = append(errs, fmt.Errorf("orchestrator.wakeWord: followUpWindow must be positive"))
+	}
+	if c.Orchestrator.PreRoll < 0 {
+		errs = append(errs, fmt.Errorf("orchestrator: preRoll must be positive"))
+	}
+
+	for i, rule := range c.Interruptions.Rules {
+		if rule.Type == "" {
+			errs = append(errs, fmt.Errorf("interruptions.rules[%d]: type is required", i))
+		}
+		if len(rule.Phrases) == 0 && rule.Pattern == "" {
+			errs = append(errs, fmt.Errorf("interruptions.rules[%d]: phrases or pattern is required", i))
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("interruptions.rules[%d]: invalid pattern: %w", i, err))
+		}
+	}
+	if c.Interruptions.Classifier.Provider != ProviderNone {
+		if err := validateLLM(c.Interruptions.Classifier, true); err != nil {
+			errs = append(errs, fmt.Errorf("interruptions.classifier: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
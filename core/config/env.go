@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+)
+
+// envReference matches ${NAME} and ${NAME:-default}
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces the environment variable references in all string
+// values of the config, referenced variables without a default must be set
+func expandEnv(config *Config) error {
+	var errs []error
+	expandValue(reflect.ValueOf(config).Elem(), func(s string) string {
+		return envReference.ReplaceAllStringFunc(s, func(reference string) string {
+			match := envReference.FindStringSubmatch(reference)
+			if value, ok := os.LookupEnv(match[1]); ok {
+				return value
+			}
+			if match[2] == "" {
+				errs = append(errs, fmt.Errorf("environment variable %s is not set", match[1]))
+			}
+			return match[3]
+		})
+	})
+	return errors.Join(errs...)
+}
+
+func expandValue(v reflect.Value, expand func(string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(expand(v.String()))
+	case reflect.Struct:
+		for i := range v.NumField() {
+			expandValue(v.Field(i), expand)
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			expandValue(v.Index(i), expand)
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			expandValue(v.Elem(), expand)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+
+	orchestration "github.com/koscakluka/ema-core/core"
+	llmInterruptionsHandler "github.com/koscakluka/ema-core/core/interruptions/llm"
+	"github.com/koscakluka/ema-core/core/llms/groq"
+	"github.com/koscakluka/ema-core/core/llms/openai"
+)
+
+// llmModel creates the client of a model
+type llmModel struct {
+	newClient func(apiKey, systemPrompt string) (any, error)
+	// streaming and classifier tell whether the client can be used as the
+	// orchestrator's LLM and as the interruption classifier
+	streaming  bool
+	classifier bool
+}
+
+var llmModels = map[string]map[string]llmModel{
+	ProviderGroq: {
+		string(groq.ModelLlama318BInstant):          groqModel(groq.NewLlama318BInstructClient),
+		string(groq.ModelLlama3370BVersatile):       groqModel(groq.NewLlama3370BVersatileClient),
+		string(groq.ModelGPTOSS20B):                 groqModel(groq.NewGPTOSS20BClient),
+		string(groq.ModelGPTOSS120B):                groqModel(groq.NewGPTOSS120BClient),
+		string(groq.ModelLlama4Maverick17BInstruct): groqModel(groq.NewLlama4Maverick17BInstructClient),
+		string(groq.ModelLlama4Scout17BInstruct):    groqModel(groq.NewLlama4Scout17BInstructClient),
+		string(groq.ModelKimiK2Instruct0905):        groqModel(groq.NewKimiK2Instruct0905Client),
+		string(groq.ModelQwen332B):                  groqModel(groq.NewQwen332BClient),
+	},
+	ProviderOpenAI: {
+		string(openai.ModelGPT4o):    openaiModel(openai.NewGPT4oClient),
+		string(openai.ModelGPT41):    openaiModel(openai.NewGPT41Client),
+		string(openai.ModelGPT5Nano): openaiModel(openai.NewGPT5NanoClient),
+	},
+}
+
+// defaultLLMModels and defaultClassifierModels are the models used when the
+// config only sets the provider
+var (
+	defaultLLMModels = map[string]string{
+		ProviderGroq:   string(groq.ModelLlama3370BVersatile),
+		ProviderOpenAI: string(openai.ModelGPT41),
+	}
+	defaultClassifierModels = map[string]string{
+		ProviderGroq:   string(groq.ModelGPTOSS20B),
+		ProviderOpenAI: string(openai.ModelGPT5Nano),
+	}
+)
+
+func groqModel[T any](newClient func(opts ...groq.ClientOption) (*T, error)) llmModel {
+	return llmModel{
+		newClient: func(apiKey, systemPrompt string) (any, error) {
+			opts := []groq.ClientOption{}
+			if apiKey != "" {
+				opts = append(opts, groq.WithAPIKey(apiKey))
+			}
+			if systemPrompt != "" {
+				opts = append(opts, groq.WithSystemPrompt(systemPrompt))
+			}
+			return asLLM(newClient(opts...))
+		},
+		streaming:  implements[T, orchestration.LLMWithStream](),
+		classifier: canClassify[T](),
+	}
+}
+
+func openaiModel[V any, T any](newClient func(opts ...openai.BaseOption[V]) (*T, error)) llmModel {
+	return llmModel{
+		newClient: func(apiKey, systemPrompt string) (any, error) {
+			opts := []openai.BaseOption[V]{}
+			if apiKey != "" {
+				opts = append(opts, openai.WithAPIKey[V](apiKey))
+			}
+			if systemPrompt != "" {
+				opts = append(opts, openai.WithSystemPrompt[V](systemPrompt))
+			}
+			return asLLM(newClient(opts...))
+		},
+		streaming:  implements[T, orchestration.LLMWithStream](),
+		classifier: canClassify[T](),
+	}
+}
+
+// asLLM keeps a failed constructor's nil client from becoming a non-nil
+// interface value
+func asLLM[T any](client *T, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func implements[T any, I any]() bool {
+	_, ok := any((*T)(nil)).(I)
+	return ok
+}
+
+func canClassify[T any]() bool {
+	return implements[T, llmInterruptionsHandler.LLMWithStructuredPrompt]() ||
+		implements[T, llmInterruptionsHandler.LLMWithGeneralPrompt]()
+}
+
+// lookupLLM finds the model of the config, defaults are used for the missing
+// provider and model
+func lookupLLM(config LLMConfig, defaultModels map[string]string) (llmModel, error) {
+	provider := config.Provider
+	if provider == "" {
+		provider = ProviderGroq
+	}
+	models, ok := llmModels[provider]
+	if !ok {
+		return llmModel{}, fmt.Errorf("unknown provider %q, expected groq or openai", config.Provider)
+	}
+
+	modelName := config.Model
+	if modelName == "" {
+		modelName = defaultModels[provider]
+	}
+	model, ok := models[modelName]
+	if !ok {
+		return llmModel{}, fmt.Errorf("unknown %s model %q", provider, modelName)
+	}
+	return model, nil
+}
+
+func validateLLM(config LLMConfig, classifier bool) error {
+	defaultModels := defaultLLMModels
+	if classifier {
+		defaultModels = defaultClassifierModels
+	}
+	model, err := lookupLLM(config, defaultModels)
+	if err != nil {
+		return err
+	}
+
+	if classifier && !model.classifier {
+		return fmt.Errorf("model %q can't classify interruptions", config.Model)
+	}
+	if !classifier && !model.streaming {
+		return fmt.Errorf("model %q doesn't support streaming", config.Model)
+	}
+	return nil
+}
+
+func newLLM(config LLMConfig, defaultModels map[string]string) (any, error) {
+	model, err := lookupLLM(config, defaultModels)
+	if err != nil {
+		return nil, err
+	}
+	return model.newClient(config.APIKey, config.SystemPrompt)
+}
@@ -1,12 +1,26 @@
 package orchestration
 
+import (
+	"sync"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/llms"
+)
+
 func (o *Orchestrator) CancelTurn() {
 	// TODO: This could potentially be done directly on the turn instead of
 	// as an exposed method
-	activeTurn := o.turns.activeTurn()
-	if activeTurn != nil && !activeTurn.Cancelled {
-		activeTurn.Cancelled = true
-		o.turns.updateActiveTurn(*activeTurn)
+	cancelled := false
+	o.turns.updateActiveTurn(func(turn *llms.Turn) {
+		cancelled = !turn.Cancelled
+		turn.Cancelled = true
+	})
+	if cancelled {
+		// NOTE: Audio already queued on the output would otherwise play to
+		// the end
+		if o.audioOutput != nil {
+			o.clearAudioOutput()
+		}
 		if o.orchestrateOptions.onCancellation != nil {
 			o.orchestrateOptions.onCancellation()
 		}
@@ -15,12 +29,110 @@ func (o *Orchestrator) CancelTurn() {
 }
 
 func (o *Orchestrator) PauseTurn() {
+	// NOTE: The unplayed audio has to be measured before the output drops it
+	unplayed, positionKnown := o.unplayedOutput()
 	if o.audioOutput != nil {
-		o.audioOutput.ClearBuffer()
+		o.clearAudioOutput()
 	}
-	o.outputAudioBuffer.PauseAudio()
+	o.outputAudioBuffer.PauseAudio(unplayed, positionKnown)
 }
 
 func (o *Orchestrator) UnpauseTurn() {
+	o.interruptionPause.clear()
 	o.outputAudioBuffer.UnpauseAudio()
 }
+
+// speechStartPauseTimeout is how long the output stays paused after speech
+// was detected if no transcript shows up, speech start events can be
+// triggered by noise that never gets transcribed
+const speechStartPauseTimeout = 2 * time.Second
+
+// interruptionPause keeps track of the active turn being paused because the
+// user started speaking over it
+type interruptionPause struct {
+	paused bool
+	// confirmed is set when the interruption produced a transcript, from then
+	// on only the interruption handling can unpause the turn
+	confirmed bool
+	timeout   *time.Timer
+	mu        sync.Mutex
+}
+
+func (p *interruptionPause) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.paused = false
+	p.confirmed = false
+	if p.timeout != nil {
+		p.timeout.Stop()
+		p.timeout = nil
+	}
+}
+
+// confirm marks the pause as caused by an actual interruption, if there is
+// one, so it is no longer automatically unpaused
+func (p *interruptionPause) confirm() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.confirmed = p.paused
+	if p.timeout != nil {
+		p.timeout.Stop()
+		p.timeout = nil
+	}
+}
+
+// pauseForInterruption pauses the active turn if it is speaking and not
+// already paused. If timeout is set the turn is unpaused after it runs out,
+// unless confirmPauseForInterruption is called in the meantime.
+func (o *Orchestrator) pauseForInterruption(withTimeout bool) {
+	activeTurn := o.turns.activeTurn()
+	if activeTurn == nil || activeTurn.Cancelled {
+		return
+	}
+
+	o.interruptionPause.mu.Lock()
+	defer o.interruptionPause.mu.Unlock()
+	if o.interruptionPause.paused {
+		return
+	}
+
+	o.interruptionPause.paused = true
+	o.PauseTurn()
+	if withTimeout {
+		o.interruptionPause.timeout = time.AfterFunc(speechStartPauseTimeout, o.resumeUnconfirmedInterruption)
+	}
+}
+
+// confirmPauseForInterruption keeps the turn paused until the interruption is
+// handled, it pauses the turn if it wasn't paused already
+func (o *Orchestrator) confirmPauseForInterruption() {
+	o.pauseForInterruption(false)
+	o.interruptionPause.confirm()
+}
+
+// resumeAfterInterruption unpauses the active turn if it was paused by an
+// interruption that never got resolved (e.g. noise that produced no
+// transcript or a failed interruption handler)
+func (o *Orchestrator) resumeAfterInterruption() {
+	o.interruptionPause.mu.Lock()
+	paused := o.interruptionPause.paused
+	o.interruptionPause.mu.Unlock()
+
+	if paused {
+		o.UnpauseTurn()
+	}
+}
+
+// resumeUnconfirmedInterruption unpauses the active turn if it was paused on
+// speech start, but no transcript followed
+func (o *Orchestrator) resumeUnconfirmedInterruption() {
+	o.interruptionPause.mu.Lock()
+	unconfirmed := o.interruptionPause.paused && !o.interruptionPause.confirmed
+	o.interruptionPause.mu.Unlock()
+
+	if unconfirmed {
+		o.UnpauseTurn()
+	}
+}
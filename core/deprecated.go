@@ -66,7 +66,7 @@ func (o *Orchestrator) Cancel() {
 //
 // Deprecated: (since v0.0.13) use Turns instead
 func (o *Orchestrator) Messages() []llms.Message {
-	return llms.ToMessages(o.turns.turns)
+	return llms.ToMessages(o.turns.Snapshot())
 }
 
 // respondToInterruption
@@ -120,7 +120,7 @@ func (o *Orchestrator) respondToInterruption(prompt string, t interruptionType)
 		case LLMWithPrompt:
 			if _, err := o.llm.(LLMWithPrompt).Prompt(context.TODO(), prompt,
 				llms.WithForcedTools(o.tools...),
-				llms.WithTurns(o.turns.turns...),
+				llms.WithTurns(o.turns.Snapshot()...),
 			); err != nil {
 				// TODO: Retry?
 				return nil, fmt.Errorf("failed to call tool LLM: %w", err)
@@ -128,7 +128,7 @@ func (o *Orchestrator) respondToInterruption(prompt string, t interruptionType)
 		case LLMWithGeneralPrompt:
 			resp, err := o.llm.(LLMWithGeneralPrompt).Prompt(context.TODO(), prompt,
 				llms.WithForcedTools(o.tools...),
-				llms.WithTurns(o.turns.turns...),
+				llms.WithTurns(o.turns.Snapshot()...),
 			)
 			if err != nil {
 				// TODO: Retry?
@@ -0,0 +1,187 @@
+package orchestration
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/koscakluka/ema-core/core/audio/echo"
+	"github.com/koscakluka/ema-core/core/session"
+)
+
+const (
+	defaultEchoTranscriptThreshold = 0.8
+	// echoTail is how long after the output stopped playing transcripts can
+	// still be the echo of it, it covers transcription latency
+	echoTail = 2 * time.Second
+	// minEchoTranscriptWords is the shortest transcript that can be filtered,
+	// short commands like "stop" are likely to be in the spoken text as well
+	minEchoTranscriptWords = 2
+)
+
+// echoSuppression keeps the assistant from hearing itself when the output is
+// played through speakers while recording
+type echoSuppression struct {
+	cancellation     bool
+	cancellerOptions []echo.Option
+	canceller        *echo.Canceller
+
+	transcriptFilter    bool
+	transcriptThreshold float64
+	// spokenWords are the words of the current and previous assistant turns
+	spokenWords   []string
+	previousWords []string
+	// playbackEnd is the estimated time the output finishes playing
+	playbackEnd time.Time
+
+	mu sync.Mutex
+}
+
+// sendAudioToOutput sends the audio to the output and keeps it as the
+// reference for echo suppression
+func (o *Orchestrator) sendAudioToOutput(audio []byte) {
+	output := o.audioFormats.toOutput.Convert(audio)
+	if err := o.audioOutput.SendAudio(output); err != nil {
+		log.Printf("Failed to send audio to output: %v", err)
+		return
+	}
+
+	encodingInfo := o.audioOutput.EncodingInfo()
+	if encodingInfo.BytesPerFrame() > 0 {
+		o.outputSent(len(output) / encodingInfo.BytesPerFrame())
+	}
+
+	o.echoSuppression.mu.Lock()
+	defer o.echoSuppression.mu.Unlock()
+
+	if encodingInfo.SampleRate > 0 && encodingInfo.BytesPerFrame() > 0 {
+		duration := time.Duration(len(output)/encodingInfo.BytesPerFrame()) * time.Second / time.Duration(encodingInfo.SampleRate)
+		o.echoSuppression.playbackEnd = later(o.echoSuppression.playbackEnd, time.Now()).Add(duration)
+	}
+	if o.echoSuppression.canceller != nil {
+		o.echoSuppression.canceller.AddReference(o.audioFormats.toEchoReference.Convert(audio))
+	}
+}
+
+// clearAudioOutput clears the output's buffer and drops the unplayed audio
+// from the echo reference
+func (o *Orchestrator) clearAudioOutput() {
+	o.audioOutput.ClearBuffer()
+	o.outputCleared()
+	o.audioFormats.toOutput.Reset()
+	o.audioFormats.toEchoReference.Reset()
+	if o.sessionRecorder != nil {
+		o.sessionRecorder.ClearOutputAudio()
+		o.recordEvent(session.EventTypeOutputCleared, nil)
+	}
+
+	o.echoSuppression.mu.Lock()
+	defer o.echoSuppression.mu.Unlock()
+
+	if o.echoSuppression.playbackEnd.After(time.Now()) {
+		o.echoSuppression.playbackEnd = time.Now()
+	}
+	if o.echoSuppression.canceller != nil {
+		o.echoSuppression.canceller.ClearReference()
+	}
+}
+
+// cancelEcho removes the echo of the output from the captured audio if echo
+// cancellation is enabled
+func (o *Orchestrator) cancelEcho(audio []byte) []byte {
+	if !o.echoSuppression.cancellation || o.audioInput == nil || o.audioOutput == nil {
+		return audio
+	}
+
+	o.echoSuppression.mu.Lock()
+	if o.echoSuppression.canceller == nil {
+		// NOTE: The reference is converted to the input's sample rate, so
+		// the canceller works at it
+		inputRate := o.audioInput.EncodingInfo().SampleRate
+		o.echoSuppression.canceller = echo.NewCanceller(inputRate, o.echoSuppression.cancellerOptions...)
+	}
+	canceller := o.echoSuppression.canceller
+	o.echoSuppression.mu.Unlock()
+
+	return canceller.Process(audio)
+}
+
+// startSpokenTurn starts collecting the spoken text of a new assistant turn,
+// the previous turn's text is kept since its echo can still be transcribed
+func (s *echoSuppression) startSpokenTurn() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.previousWords = s.spokenWords
+	s.spokenWords = nil
+}
+
+func (s *echoSuppression) addSpokenText(text string) {
+	if !s.transcriptFilter {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.spokenWords = append(s.spokenWords, normalizeWords(text)...)
+}
+
+// isEcho reports whether the transcript is most likely the assistant's own
+// speech picked up by the microphone
+func (s *echoSuppression) isEcho(transcript string) bool {
+	if !s.transcriptFilter {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Now().After(s.playbackEnd.Add(echoTail)) {
+		return false
+	}
+
+	words := normalizeWords(transcript)
+	if len(words) < minEchoTranscriptWords {
+		return false
+	}
+
+	spoken := append(append([]string{}, s.previousWords...), s.spokenWords...)
+	matched := longestCommonRun(words, spoken)
+	return float64(matched)/float64(len(words)) >= s.transcriptThreshold
+}
+
+// longestCommonRun returns the length of the longest run of consecutive words
+// found in both a and b
+func longestCommonRun(a, b []string) int {
+	longest := 0
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				current[j+1] = previous[j] + 1
+				longest = max(longest, current[j+1])
+			} else {
+				current[j+1] = 0
+			}
+		}
+		previous, current = current, previous
+	}
+	return longest
+}
+
+func normalizeWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -1,5 +1,7 @@
 package llm
 
+import "github.com/koscakluka/ema-core/core/interruptions"
+
 type interruptionType string
 
 const (
@@ -12,3 +14,60 @@ const (
 	InterruptionTypeAction        interruptionType = "action"
 	InterruptionTypeNewPrompt     interruptionType = "new prompt"
 )
+
+// BuiltInTypes returns the interruption types handled out of the box, they
+// can be individually overridden by registering a type with the same name
+func BuiltInTypes() []interruptions.Type {
+	return []interruptions.Type{
+		{
+			Name:        string(InterruptionTypeContinuation),
+			Description: `The interruption is a continuation of the previous sentence/request (e.g. "Tell me about Star Wars.", "Ships design").`,
+			Respond:     respondToContinuation,
+		},
+		{
+			Name:        string(InterruptionTypeCancellation),
+			Description: "Anything that indicates that the response should not be finished. Only used if the interruption cannot be addressed by a listed tool.",
+			Respond:     respondToCancellation,
+		},
+		{
+			Name:        string(InterruptionTypeClarification),
+			Description: `The interruption is a clarification or restatement of the previous instruction (e.g. "It's actually about the TV show, not the movie").`,
+			Respond:     respondToClarification,
+		},
+		{
+			Name:        string(InterruptionTypeIgnorable),
+			Description: "The interruption is ignorable and should not be responded to.",
+			Respond:     respondByResuming,
+		},
+		{
+			Name:        string(InterruptionTypeRepetition),
+			Description: "The interruption is a repetition of the previous sentence/request.",
+			Respond:     respondByResuming,
+		},
+		{
+			Name:        string(InterruptionTypeNoise),
+			Description: "The interruption is noise and should be ignored.",
+			Respond:     respondByResuming,
+		},
+		{
+			Name:        string(InterruptionTypeAction),
+			Description: "The interruption is a addressable with a listed tool.",
+			Respond:     respondToAction,
+		},
+		{
+			Name:        string(InterruptionTypeNewPrompt),
+			Description: "The interruption is a new prompt to be responded to that could not be understood as a continuation of the previous sentence",
+			Respond:     respondToNewPrompt,
+		},
+	}
+}
+
+// NewRegistry creates an interruption type registry with the built-in types
+// and the passed ones, passed types override built-in ones with the same name
+func NewRegistry(types ...interruptions.Type) *interruptions.Registry {
+	registry := interruptions.NewRegistry(BuiltInTypes()...)
+	for _, t := range types {
+		registry.Register(t)
+	}
+	return registry
+}
@@ -4,8 +4,13 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"text/template"
 
+	"github.com/invopop/jsonschema"
+	"github.com/koscakluka/ema-core/core/interruptions"
 	"github.com/koscakluka/ema-core/core/llms"
 )
 
@@ -15,102 +20,250 @@ var interruptionClassifierSystemPrompt string
 //go:embed classifierSturctInsttr.tmpl
 var interruptionClassifierStructuredSystemPrompt string
 
+var (
+	interruptionClassifierSystemPromptTemplate           = template.Must(template.New("classifier").Parse(interruptionClassifierSystemPrompt))
+	interruptionClassifierStructuredSystemPromptTemplate = template.Must(template.New("structuredClassifier").Parse(interruptionClassifierStructuredSystemPrompt))
+)
+
 type Classification struct {
-	Type string `json:"type" jsonschema:"title=Type,description=The type of interruption" enum:"continuation,clarification,cancellation,ignorable,repetition,noise,action,new prompt"`
+	Type       string  `json:"type" jsonschema:"title=Type,description=The type of interruption"`
+	Confidence float64 `json:"confidence" jsonschema:"title=Confidence,description=Confidence in the type from 0 to 1,minimum=0,maximum=1"`
+	Rationale  string  `json:"rationale" jsonschema:"title=Rationale,description=Short explanation of the chosen type"`
+
+	// types are the names allowed for Type, built-in type names are used if
+	// empty
+	types []string
+	// hasConfidence is set if the classifier returned a confidence
+	hasConfidence bool
+}
+
+// UnmarshalJSON decodes the classification and records whether it has a
+// confidence, classifications without one are trusted (e.g. custom prompt
+// templates might not ask for confidence)
+func (c *Classification) UnmarshalJSON(data []byte) error {
+	type classification Classification
+	decoded := struct {
+		*classification
+		Confidence *float64 `json:"confidence"`
+	}{classification: (*classification)(c)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	c.hasConfidence = decoded.Confidence != nil
+	if c.hasConfidence {
+		c.Confidence = *decoded.Confidence
+	}
+	return nil
 }
 
-func classify(interruption llms.InterruptionV0, llm LLM, opts ...ClassifyOption) (*llms.InterruptionV0, error) {
+// JSONSchema generates the schema for the classification, limiting the type
+// to the registered interruption types
+func (c Classification) JSONSchema() *jsonschema.Schema {
+	types := c.types
+	if len(types) == 0 {
+		types = interruptions.NewRegistry(BuiltInTypes()...).Names()
+	}
+	enum := make([]any, 0, len(types))
+	for _, t := range types {
+		enum = append(enum, t)
+	}
+
+	properties := jsonschema.NewProperties()
+	properties.Set("type", &jsonschema.Schema{
+		Type:        "string",
+		Title:       "Type",
+		Description: "The type of interruption",
+		Enum:        enum,
+	})
+	properties.Set("confidence", &jsonschema.Schema{
+		Type:        "number",
+		Title:       "Confidence",
+		Description: "Confidence in the type from 0 to 1",
+		Minimum:     json.Number("0"),
+		Maximum:     json.Number("1"),
+	})
+	properties.Set("rationale", &jsonschema.Schema{
+		Type:        "string",
+		Title:       "Rationale",
+		Description: "Short explanation of the chosen type",
+	})
+	return &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           properties,
+		Required:             []string{"type", "confidence", "rationale"},
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+}
+
+// Classify determines the type of the interruption using the llm, it doesn't
+// respond to the interruption. Failed attempts are retried and recorded in the
+// interruption's decision path, the interruption is returned even if all of
+// them fail.
+func Classify(interruption llms.InterruptionV0, llm LLM, opts ...ClassifyOption) (*llms.InterruptionV0, error) {
 	options := ClassifyOptions{}
 	for _, opt := range opts {
 		opt(&options)
 	}
+	if options.Registry == nil {
+		options.Registry = builtInRegistry
+	}
+
+	var err error
+	for attempt := range options.Retries + 1 {
+		var classification *Classification
+		if classification, err = classifyOnce(interruption, llm, options); err == nil {
+			interruption.Type = classification.Type
+			interruption.Confidence = classification.Confidence
+			interruption.Rationale = classification.Rationale
+			interruption.DecisionPath = append(interruption.DecisionPath,
+				fmt.Sprintf("classified as %s with confidence %.2f", classification.Type, classification.Confidence))
+			return &interruption, nil
+		}
+		interruption.DecisionPath = append(interruption.DecisionPath,
+			fmt.Sprintf("classification attempt %d failed: %v", attempt+1, err))
+
+		// NOTE: Only the classifier's failures and malformed output can
+		// turn out differently on the next attempt
+		var permanentErr permanentError
+		if errors.As(err, &permanentErr) {
+			return &interruption, permanentErr.err
+		}
+	}
+
+	return &interruption, err
+}
+
+// permanentError marks classification failures that retrying can't fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// checkConfidence returns an error if the classifier's confidence is outside
+// of the 0 to 1 range
+func checkConfidence(confidence float64) error {
+	if confidence < 0 || confidence > 1 {
+		return fmt.Errorf("interruption classification confidence %v is outside of the 0 to 1 range", confidence)
+	}
+	return nil
+}
+
+func classifyOnce(interruption llms.InterruptionV0, llm LLM, options ClassifyOptions) (*Classification, error) {
+	structuredSystemPromptTemplate := interruptionClassifierStructuredSystemPromptTemplate
+	systemPromptTemplate := interruptionClassifierSystemPromptTemplate
+	if options.SystemPromptTemplate != nil {
+		structuredSystemPromptTemplate = options.SystemPromptTemplate
+		systemPromptTemplate = options.SystemPromptTemplate
+	}
 
 	switch llm.(type) {
 	case LLMWithStructuredPrompt:
-		systemPrompt := interruptionClassifierStructuredSystemPrompt
-		for _, tool := range options.Tools {
-			systemPrompt += fmt.Sprintf("- %s: %s", tool.Function.Name, tool.Function.Description)
+		systemPrompt, err := renderSystemPrompt(structuredSystemPromptTemplate, options)
+		if err != nil {
+			return nil, permanentError{err}
 		}
 
-		resp := Classification{}
+		resp := Classification{types: options.Registry.Names()}
 		if err := llm.(LLMWithStructuredPrompt).PromptWithStructure(context.TODO(), interruption.Source,
 			&resp,
 			llms.WithSystemPrompt(systemPrompt),
 			llms.WithTurns(options.History...),
 		); err != nil {
-			// TODO: Retry?
-			return &interruption, err
+			return nil, err
 		}
 
-		interruptionType, err := toInterruptionType(resp.Type)
-		if err != nil {
+		if _, ok := options.Registry.Get(resp.Type); !ok {
+			return nil, fmt.Errorf("unknown interruption type: %s", resp.Type)
+		}
+		if !resp.hasConfidence {
+			resp.Confidence = 1
+		} else if err := checkConfidence(resp.Confidence); err != nil {
 			return nil, err
 		}
-		interruption.Type = string(interruptionType)
-		return &interruption, nil
+		return &resp, nil
 
 	case LLMWithGeneralPrompt:
-		systemPrompt := interruptionClassifierSystemPrompt
-		for _, tool := range options.Tools {
-			systemPrompt += fmt.Sprintf("- %s: %s", tool.Function.Name, tool.Function.Description)
+		systemPrompt, err := renderSystemPrompt(systemPromptTemplate, options)
+		if err != nil {
+			return nil, permanentError{err}
 		}
 
-		response, _ := llm.(LLMWithGeneralPrompt).Prompt(context.TODO(), interruption.Source,
+		response, err := llm.(LLMWithGeneralPrompt).Prompt(context.TODO(), interruption.Source,
 			llms.WithSystemPrompt(systemPrompt),
 			llms.WithTurns(options.History...),
 		)
+		if err != nil {
+			return nil, err
+		}
 
-		if len(response.Content) == 0 {
+		if response == nil || len(response.Content) == 0 {
 			return nil, fmt.Errorf("no response from interruption classifier")
 		}
 
 		var unmarshalledResponse struct {
-			Classification string `json:"classification"`
+			Classification string   `json:"classification"`
+			Confidence     *float64 `json:"confidence"`
+			Rationale      string   `json:"rationale"`
 		}
 		if err := json.Unmarshal([]byte(response.Content), &unmarshalledResponse); err != nil {
-			// TODO: Retry
 			return nil, fmt.Errorf("failed to unmarshal interruption classification response: %w", err)
 		}
 
-		interruptionType, err := toInterruptionType(unmarshalledResponse.Classification)
-		if err != nil {
-			return nil, err
+		if _, ok := options.Registry.Get(unmarshalledResponse.Classification); !ok {
+			return nil, fmt.Errorf("unknown interruption type: %s", unmarshalledResponse.Classification)
+		}
+
+		classification := &Classification{
+			Type:       unmarshalledResponse.Classification,
+			Rationale:  unmarshalledResponse.Rationale,
+			Confidence: 1,
 		}
-		interruption.Type = string(interruptionType)
-		return &interruption, nil
+		if unmarshalledResponse.Confidence != nil {
+			if err := checkConfidence(*unmarshalledResponse.Confidence); err != nil {
+				return nil, err
+			}
+			classification.Confidence = *unmarshalledResponse.Confidence
+		}
+		return classification, nil
 	}
 
-	return nil, fmt.Errorf("unknown llm type")
+	return nil, permanentError{fmt.Errorf("unknown llm type")}
 }
 
-func toInterruptionType(classification string) (interruptionType, error) {
-	switch classification {
-	case "continuation":
-		return InterruptionTypeContinuation, nil
-	case "clarification":
-		return InterruptionTypeClarification, nil
-	case "cancellation":
-		return InterruptionTypeCancellation, nil
-	case "ignorable":
-		return InterruptionTypeIgnorable, nil
-	case "repetition":
-		return InterruptionTypeRepetition, nil
-	case "noise":
-		return InterruptionTypeNoise, nil
-	case "action":
-		return InterruptionTypeAction, nil
-	case "new prompt":
-		return InterruptionTypeNewPrompt, nil
-	default:
-		return "", fmt.Errorf("unknown interruption type: %s", classification)
+func renderSystemPrompt(tmpl *template.Template, options ClassifyOptions) (string, error) {
+	var systemPrompt strings.Builder
+	if err := tmpl.Execute(&systemPrompt, struct {
+		Types []interruptions.Type
+		Tools []llms.Tool
+	}{
+		Types: options.Registry.Types(),
+		Tools: options.Tools,
+	}); err != nil {
+		return "", fmt.Errorf("failed to render classifier system prompt: %w", err)
 	}
+	return systemPrompt.String(), nil
 }
 
 type ClassifyOption func(*ClassifyOptions)
 
 type ClassifyOptions struct {
-	History []llms.Turn
-	Tools   []llms.Tool
+	History  []llms.Turn
+	Tools    []llms.Tool
+	Registry *interruptions.Registry
+	// Retries is the number of times classification is repeated if it fails
+	Retries int
+
+	// SystemPromptTemplate replaces the built-in classifier instructions, it
+	// is rendered with Types ([]interruptions.Type) and Tools ([]llms.Tool)
+	SystemPromptTemplate *template.Template
 }
 
 func WithTools(tools []llms.Tool) ClassifyOption {
@@ -124,3 +277,27 @@ func WithHistory(history []llms.Turn) ClassifyOption {
 		o.History = history
 	}
 }
+
+// WithRegistry sets the interruption types the interruption can be
+// classified as
+func WithRegistry(registry *interruptions.Registry) ClassifyOption {
+	return func(o *ClassifyOptions) {
+		o.Registry = registry
+	}
+}
+
+// WithSystemPromptTemplate replaces the built-in classifier instructions with
+// the template, useful for evaluating prompt changes
+func WithSystemPromptTemplate(tmpl *template.Template) ClassifyOption {
+	return func(o *ClassifyOptions) {
+		o.SystemPromptTemplate = tmpl
+	}
+}
+
+// WithRetries sets how many times the classification is repeated if the
+// classifier fails or returns malformed output
+func WithRetries(retries int) ClassifyOption {
+	return func(o *ClassifyOptions) {
+		o.Retries = max(retries, 0)
+	}
+}
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/koscakluka/ema-core/core/llms"
+)
+
+type structuredClassifier struct {
+	responses []string
+	calls     int
+}
+
+func (c *structuredClassifier) PromptWithStructure(ctx context.Context, prompt string, outputSchema any, opts ...llms.StructuredPromptOption) error {
+	response := c.responses[min(c.calls, len(c.responses)-1)]
+	c.calls++
+	if response == "" {
+		return errors.New("transport error")
+	}
+	return json.Unmarshal([]byte(response), outputSchema)
+}
+
+func TestClassifyRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		llm       *structuredClassifier
+		wantCalls int
+		wantErr   bool
+	}{
+		{"success", &structuredClassifier{responses: []string{`{"type": "cancellation", "confidence": 0.9}`}}, 1, false},
+		{"transport error", &structuredClassifier{responses: []string{"", `{"type": "cancellation", "confidence": 0.9}`}}, 2, false},
+		{"unknown type", &structuredClassifier{responses: []string{`{"type": "unknown"}`}}, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Classify(llms.InterruptionV0{Source: "stop"}, tt.llm, WithRetries(2))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Classify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.llm.calls != tt.wantCalls {
+				t.Errorf("Classify() called the classifier %d times, want %d", tt.llm.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestClassifyDoesNotRetryUnknownLLM(t *testing.T) {
+	interruption, err := Classify(llms.InterruptionV0{Source: "stop"}, struct{}{}, WithRetries(2))
+	if err == nil {
+		t.Fatal("Classify() expected an error")
+	}
+	if len(interruption.DecisionPath) != 1 {
+		t.Errorf("Classify() made %d attempts, want 1", len(interruption.DecisionPath))
+	}
+}
+
+func TestClassifyMissingConfidence(t *testing.T) {
+	classifier := &structuredClassifier{responses: []string{`{"type": "cancellation"}`}}
+	interruption, err := Classify(llms.InterruptionV0{Source: "stop"}, classifier)
+	if err != nil {
+		t.Fatalf("Classify() error = %v", err)
+	}
+	if interruption.Confidence != 1 {
+		t.Errorf("Classify() confidence = %v, want 1", interruption.Confidence)
+	}
+}
+
+type generalClassifier struct {
+	response string
+}
+
+func (c *generalClassifier) Prompt(ctx context.Context, prompt string, opts ...llms.GeneralPromptOption) (*llms.Message, error) {
+	return &llms.Message{Content: c.response}, nil
+}
+
+func TestClassifyConfidenceOutOfRange(t *testing.T) {
+	tests := []struct {
+		name string
+		llm  LLM
+	}{
+		{"structured above range", &structuredClassifier{responses: []string{`{"type": "cancellation", "confidence": 1.5}`}}},
+		{"structured below range", &structuredClassifier{responses: []string{`{"type": "cancellation", "confidence": -0.2}`}}},
+		{"structured negative one", &structuredClassifier{responses: []string{`{"type": "cancellation", "confidence": -1}`}}},
+		{"general above range", &generalClassifier{response: `{"classification": "cancellation", "confidence": 1.5}`}},
+		{"general below range", &generalClassifier{response: `{"classification": "cancellation", "confidence": -0.2}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Classify(llms.InterruptionV0{Source: "stop"}, tt.llm); err == nil {
+				t.Error("Classify() expected an error")
+			}
+		})
+	}
+}
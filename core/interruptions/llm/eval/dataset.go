@@ -0,0 +1,113 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/koscakluka/ema-core/core/llms"
+)
+
+// Case is a single labelled interruption
+type Case struct {
+	// ID identifies the case across runs, line number is used if empty
+	ID string `json:"id"`
+	// History is the conversation before the assistant started responding
+	History []HistoryTurn `json:"history"`
+	// ActiveResponse is the assistant text that was being spoken when the
+	// interruption happened
+	ActiveResponse string `json:"active_response"`
+	// Tools are the tools available to the assistant, the classifier needs
+	// them to recognise action interruptions
+	Tools []DatasetTool `json:"tools"`
+	// Interruption is what the user said
+	Interruption string `json:"interruption"`
+	// Expected is the interruption type the case should be classified as
+	Expected string `json:"expected"`
+}
+
+// HistoryTurn is a simplified llms.Turn used in datasets
+type HistoryTurn struct {
+	Role    llms.TurnRole `json:"role"`
+	Content string        `json:"content"`
+}
+
+// DatasetTool is a simplified llms.Tool used in datasets, only the name and
+// the description are shown to the classifier
+type DatasetTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// tools converts the case's tools into the tools that the classifier sees
+func (c Case) tools() []llms.Tool {
+	tools := make([]llms.Tool, 0, len(c.Tools))
+	for _, datasetTool := range c.Tools {
+		tool := llms.Tool{Type: "function"}
+		tool.Function.Name = datasetTool.Name
+		tool.Function.Description = datasetTool.Description
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// turns converts the case into the history that the classifier sees, the
+// active response is included as an unfinished assistant turn
+func (c Case) turns() []llms.Turn {
+	turns := make([]llms.Turn, 0, len(c.History)+1)
+	for _, turn := range c.History {
+		turns = append(turns, llms.Turn{Role: turn.Role, Content: turn.Content})
+	}
+	if c.ActiveResponse != "" {
+		turns = append(turns, llms.Turn{
+			Role:    llms.TurnRoleAssistant,
+			Content: c.ActiveResponse,
+			Stage:   llms.TurnStageSpeaking,
+		})
+	}
+	return turns
+}
+
+// LoadDataset reads cases from a JSONL file, one case per line. Empty lines
+// and lines starting with '#' or '//' are skipped.
+func LoadDataset(path string) ([]Case, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer file.Close()
+
+	cases := []Case{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		var c Case
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			return nil, fmt.Errorf("failed to parse dataset line %d: %w", lineNumber, err)
+		}
+		if c.Interruption == "" {
+			return nil, fmt.Errorf("dataset line %d: interruption is empty", lineNumber)
+		}
+		if c.Expected == "" {
+			return nil, fmt.Errorf("dataset line %d: expected type is empty", lineNumber)
+		}
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("line-%d", lineNumber)
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+
+	return cases, nil
+}
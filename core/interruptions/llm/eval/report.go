@@ -0,0 +1,221 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Report summarises results of a run
+type Report struct {
+	Label   string   `json:"label,omitempty"`
+	Results []Result `json:"results"`
+
+	Total    int     `json:"total"`
+	Correct  int     `json:"correct"`
+	Errors   int     `json:"errors"`
+	Accuracy float64 `json:"accuracy"`
+
+	// Confusion counts predictions per expected type, errors are counted
+	// under the "error" prediction
+	Confusion map[string]map[string]int `json:"confusion"`
+
+	LatencyP50 time.Duration `json:"latency_p50"`
+	LatencyP90 time.Duration `json:"latency_p90"`
+	LatencyP99 time.Duration `json:"latency_p99"`
+	LatencyMax time.Duration `json:"latency_max"`
+
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+const errorPrediction = "error"
+
+func NewReport(label string, results []Result) Report {
+	report := Report{
+		Label:     label,
+		Results:   results,
+		Total:     len(results),
+		Confusion: map[string]map[string]int{},
+	}
+
+	latencies := make([]time.Duration, 0, len(results))
+	for _, result := range results {
+		predicted := result.Predicted
+		if result.Error != "" {
+			predicted = errorPrediction
+			report.Errors++
+		}
+		if result.Correct() {
+			report.Correct++
+		}
+		if report.Confusion[result.Expected] == nil {
+			report.Confusion[result.Expected] = map[string]int{}
+		}
+		report.Confusion[result.Expected][predicted]++
+
+		latencies = append(latencies, result.Latency)
+		report.InputTokens += result.InputTokens
+		report.OutputTokens += result.OutputTokens
+	}
+
+	if report.Total > 0 {
+		report.Accuracy = float64(report.Correct) / float64(report.Total)
+	}
+
+	slices.Sort(latencies)
+	report.LatencyP50 = percentile(latencies, 0.5)
+	report.LatencyP90 = percentile(latencies, 0.9)
+	report.LatencyP99 = percentile(latencies, 0.99)
+	if len(latencies) > 0 {
+		report.LatencyMax = latencies[len(latencies)-1]
+	}
+
+	return report
+}
+
+// percentile uses the nearest rank method on sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+// LoadReport reads a report previously saved with Save
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report: %w", err)
+	}
+	return &report, nil
+}
+
+// Save writes the report as indented JSON, results keep the dataset order so
+// reports from different runs can be diffed directly
+func (r Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// Print writes a human readable summary with the confusion matrix
+func (r Report) Print(w io.Writer) {
+	if r.Label != "" {
+		fmt.Fprintf(w, "Run: %s\n", r.Label)
+	}
+	fmt.Fprintf(w, "Accuracy: %.1f%% (%d/%d, %d errors)\n", r.Accuracy*100, r.Correct, r.Total, r.Errors)
+	fmt.Fprintf(w, "Latency: p50 %s, p90 %s, p99 %s, max %s\n",
+		r.LatencyP50.Round(time.Millisecond), r.LatencyP90.Round(time.Millisecond),
+		r.LatencyP99.Round(time.Millisecond), r.LatencyMax.Round(time.Millisecond))
+	if r.InputTokens > 0 || r.OutputTokens > 0 {
+		fmt.Fprintf(w, "Tokens: %d input, %d output", r.InputTokens, r.OutputTokens)
+		if r.Total > 0 {
+			fmt.Fprintf(w, " (%.0f input, %.0f output per case)",
+				float64(r.InputTokens)/float64(r.Total), float64(r.OutputTokens)/float64(r.Total))
+		}
+		fmt.Fprintln(w)
+	} else {
+		fmt.Fprintln(w, "Tokens: not reported by the classifier")
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Confusion matrix (rows expected, columns predicted):")
+	labels := r.labels()
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(table, "\t%s\t\n", strings.Join(labels, "\t"))
+	for _, expected := range labels {
+		if _, ok := r.Confusion[expected]; !ok {
+			continue
+		}
+		row := []string{expected}
+		for _, predicted := range labels {
+			row = append(row, fmt.Sprint(r.Confusion[expected][predicted]))
+		}
+		fmt.Fprintf(table, "%s\t\n", strings.Join(row, "\t"))
+	}
+	table.Flush()
+}
+
+func (r Report) labels() []string {
+	labelSet := map[string]bool{}
+	for expected, predictions := range r.Confusion {
+		labelSet[expected] = true
+		for predicted := range predictions {
+			labelSet[predicted] = true
+		}
+	}
+
+	labels := make([]string, 0, len(labelSet))
+	for label := range labelSet {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// PrintDiff writes the accuracy change and cases whose prediction changed
+// between the baseline and this report
+func (r Report) PrintDiff(w io.Writer, baseline Report) {
+	fmt.Fprintf(w, "Accuracy: %.1f%% -> %.1f%% (%+.1f)\n",
+		baseline.Accuracy*100, r.Accuracy*100, (r.Accuracy-baseline.Accuracy)*100)
+	fmt.Fprintf(w, "Latency p50: %s -> %s\n",
+		baseline.LatencyP50.Round(time.Millisecond), r.LatencyP50.Round(time.Millisecond))
+	fmt.Fprintf(w, "Tokens: %d -> %d input, %d -> %d output\n",
+		baseline.InputTokens, r.InputTokens, baseline.OutputTokens, r.OutputTokens)
+
+	baselineResults := map[string]Result{}
+	for _, result := range baseline.Results {
+		baselineResults[result.ID] = result
+	}
+
+	changed := 0
+	for _, result := range r.Results {
+		baselineResult, ok := baselineResults[result.ID]
+		if !ok || baselineResult.prediction() == result.prediction() {
+			continue
+		}
+		if changed == 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "Changed predictions:")
+		}
+		changed++
+
+		marker := " "
+		if result.Correct() {
+			marker = "+"
+		} else if baselineResult.Correct() {
+			marker = "-"
+		}
+		fmt.Fprintf(w, "%s %s %q expected %s: %s -> %s\n", marker, result.ID, result.Interruption,
+			result.Expected, baselineResult.prediction(), result.prediction())
+	}
+	if changed == 0 {
+		fmt.Fprintln(w, "No predictions changed")
+	}
+}
+
+func (r Result) prediction() string {
+	if r.Error != "" {
+		return errorPrediction
+	}
+	return r.Predicted
+}
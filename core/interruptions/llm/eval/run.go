@@ -0,0 +1,81 @@
+package eval
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/interruptions/llm"
+	"github.com/koscakluka/ema-core/core/llms"
+)
+
+// UsageReporter can be implemented by classifier LLMs to report tokens they
+// used so far, the runner attributes the difference between calls to cases.
+// The compatible, groq and openai classifiers implement it.
+type UsageReporter interface {
+	Usage() llms.Usage
+}
+
+// Result is the outcome of classifying a single case
+type Result struct {
+	ID           string        `json:"id"`
+	Interruption string        `json:"interruption"`
+	Expected     string        `json:"expected"`
+	Predicted    string        `json:"predicted"`
+	Confidence   float64       `json:"confidence,omitempty"`
+	Rationale    string        `json:"rationale,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	Latency      time.Duration `json:"latency"`
+	InputTokens  int           `json:"input_tokens,omitempty"`
+	OutputTokens int           `json:"output_tokens,omitempty"`
+}
+
+func (r Result) Correct() bool {
+	return r.Error == "" && r.Predicted == r.Expected
+}
+
+// Run classifies all the cases sequentially with the classifier
+func Run(ctx context.Context, cases []Case, classifier llm.LLM, opts ...llm.ClassifyOption) []Result {
+	usageReporter, reportsUsage := classifier.(UsageReporter)
+
+	results := make([]Result, 0, len(cases))
+	for _, c := range cases {
+		if ctx.Err() != nil {
+			break
+		}
+
+		var usageBefore llms.Usage
+		if reportsUsage {
+			usageBefore = usageReporter.Usage()
+		}
+
+		start := time.Now()
+		interruption, err := llm.Classify(
+			llms.InterruptionV0{Source: c.Interruption},
+			classifier,
+			append(slices.Clone(opts), llm.WithHistory(c.turns()), llm.WithTools(c.tools()))...,
+		)
+		result := Result{
+			ID:           c.ID,
+			Interruption: c.Interruption,
+			Expected:     c.Expected,
+			Latency:      time.Since(start),
+		}
+		if err != nil {
+			result.Error = err.Error()
+		} else if interruption != nil {
+			result.Predicted = interruption.Type
+			result.Confidence = interruption.Confidence
+			result.Rationale = interruption.Rationale
+		}
+
+		if reportsUsage {
+			usageAfter := usageReporter.Usage()
+			result.InputTokens = usageAfter.InputTokens - usageBefore.InputTokens
+			result.OutputTokens = usageAfter.OutputTokens - usageBefore.OutputTokens
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
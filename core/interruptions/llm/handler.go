@@ -10,23 +10,22 @@ import (
 )
 
 type InterruptionHandlerWithStructuredPrompt struct {
-	llm LLMWithStructuredPrompt
+	llm     LLMWithStructuredPrompt
+	options HandlerOptions
 }
 
-func NewInterruptionHandlerWithStructuredPrompt(classificationLLM LLMWithStructuredPrompt) *InterruptionHandlerWithStructuredPrompt {
+func NewInterruptionHandlerWithStructuredPrompt(classificationLLM LLMWithStructuredPrompt, opts ...HandlerOption) *InterruptionHandlerWithStructuredPrompt {
 	handler := &InterruptionHandlerWithStructuredPrompt{
-		llm: classificationLLM,
+		llm:     classificationLLM,
+		options: newHandlerOptions(opts...),
 	}
 	return handler
 }
 
 func (h *InterruptionHandlerWithStructuredPrompt) HandleV0(prompt string, history []llms.Turn, tools []llms.Tool, orchestrator interruptions.OrchestratorV0) error {
 	interruption := &llms.InterruptionV0{ID: 0, Source: prompt}
-	interruption, err := classify(*interruption, h.llm, WithHistory(history), WithTools(tools))
-	if err != nil {
-		return err
-	}
-	_, err = respond(*interruption, orchestrator)
+	interruption, err := Classify(*interruption, h.llm, h.options.classifyOptions(history, tools)...)
+	_, err = h.options.respond(*interruption, err, orchestrator)
 	return err
 }
 
@@ -35,10 +34,7 @@ func (h *InterruptionHandlerWithStructuredPrompt) HandleV1(id int64, orchestrato
 	if interruption == nil {
 		return nil, fmt.Errorf("interruption not found")
 	}
-	interruption, err := classify(*interruption, h.llm, WithHistory(getHistory(orchestrator.Turns())), WithTools(tools))
-	if err != nil {
-		return nil, err
-	}
+	interruption, err := Classify(*interruption, h.llm, h.options.classifyOptions(getHistory(orchestrator.Turns()), tools)...)
 	// TODO: How do we handle interruption changing in the middle of resolving it?
 	// activeInterruption := findInterruption(id, orchestrator.Turns())
 	// if activeInterruption == nil {
@@ -47,7 +43,7 @@ func (h *InterruptionHandlerWithStructuredPrompt) HandleV1(id int64, orchestrato
 	// 	return nil, fmt.Errorf("interruption already resolved")
 	// }
 
-	return respond(*interruption, orchestrator)
+	return h.options.respond(*interruption, err, orchestrator)
 }
 
 type LLMWithStructuredPrompt interface {
@@ -56,12 +52,14 @@ type LLMWithStructuredPrompt interface {
 
 type InterruptionHandlerWithGeneralPrompt struct {
 	LLM
-	llm LLMWithGeneralPrompt
+	llm     LLMWithGeneralPrompt
+	options HandlerOptions
 }
 
-func NewInterruptionHandlerWithGeneralPrompt(classificationLLM LLMWithGeneralPrompt) *InterruptionHandlerWithGeneralPrompt {
+func NewInterruptionHandlerWithGeneralPrompt(classificationLLM LLMWithGeneralPrompt, opts ...HandlerOption) *InterruptionHandlerWithGeneralPrompt {
 	handler := &InterruptionHandlerWithGeneralPrompt{
-		llm: classificationLLM,
+		llm:     classificationLLM,
+		options: newHandlerOptions(opts...),
 	}
 	return handler
 }
@@ -73,11 +71,8 @@ type LLMWithGeneralPrompt interface {
 
 func (h *InterruptionHandlerWithGeneralPrompt) HandleV0(prompt string, history []llms.Turn, tools []llms.Tool, orchestrator interruptions.OrchestratorV0) error {
 	interruption := &llms.InterruptionV0{ID: 0, Source: prompt}
-	interruption, err := classify(*interruption, h.llm, WithHistory(history), WithTools(tools))
-	if err != nil {
-		return err
-	}
-	_, err = respond(*interruption, orchestrator)
+	interruption, err := Classify(*interruption, h.llm, h.options.classifyOptions(history, tools)...)
+	_, err = h.options.respond(*interruption, err, orchestrator)
 	return err
 }
 
@@ -86,10 +81,7 @@ func (h *InterruptionHandlerWithGeneralPrompt) HandleV1(id int64, orchestrator i
 	if interruption == nil {
 		return nil, fmt.Errorf("interruption not found")
 	}
-	interruption, err := classify(*interruption, h.llm, WithHistory(getHistory(orchestrator.Turns())), WithTools(tools))
-	if err != nil {
-		return nil, err
-	}
+	interruption, err := Classify(*interruption, h.llm, h.options.classifyOptions(getHistory(orchestrator.Turns()), tools)...)
 	// TODO: How do we handle interruption changing in the middle of resolving it?
 	// activeInterruption := findInterruption(id, orchestrator.Turns())
 	// if activeInterruption == nil {
@@ -97,11 +89,115 @@ func (h *InterruptionHandlerWithGeneralPrompt) HandleV1(id int64, orchestrator i
 	// } else if activeInterruption.Resolved {
 	// 	return nil, fmt.Errorf("interruption already resolved")
 	// }
-	return respond(*interruption, orchestrator)
+	return h.options.respond(*interruption, err, orchestrator)
 }
 
 type LLM any
 
+type HandlerOptions struct {
+	// Registry holds the interruption types the handler classifies
+	// interruptions into and responds to
+	Registry *interruptions.Registry
+
+	// Retries is the number of times classification is repeated when the
+	// classifier fails or returns malformed output
+	Retries int
+
+	// ConfidenceThreshold is the minimal classifier confidence needed to
+	// respond to the classified type, LowConfidencePolicy is applied otherwise
+	ConfidenceThreshold float64
+	// LowConfidencePolicy decides how to respond to interruptions classified
+	// with low confidence or not classified at all
+	LowConfidencePolicy LowConfidencePolicy
+}
+
+type HandlerOption func(*HandlerOptions)
+
+const (
+	defaultRetries             = 2
+	defaultConfidenceThreshold = 0.5
+)
+
+func newHandlerOptions(opts ...HandlerOption) HandlerOptions {
+	options := HandlerOptions{
+		Retries:             defaultRetries,
+		ConfidenceThreshold: defaultConfidenceThreshold,
+		LowConfidencePolicy: LowConfidencePolicyNewPrompt,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Registry == nil {
+		options.Registry = NewRegistry()
+	}
+	return options
+}
+
+// WithTypes registers additional interruption types or overrides built-in
+// ones with the same name
+func WithTypes(types ...interruptions.Type) HandlerOption {
+	return func(o *HandlerOptions) {
+		if o.Registry == nil {
+			o.Registry = NewRegistry()
+		}
+		for _, t := range types {
+			o.Registry.Register(t)
+		}
+	}
+}
+
+// WithTypeRegistry sets the registry of interruption types, it replaces the
+// built-in types so they need to be registered explicitly if needed
+func WithTypeRegistry(registry *interruptions.Registry) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Registry = registry
+	}
+}
+
+// WithClassificationRetries sets how many times the classification is
+// repeated if it fails
+func WithClassificationRetries(retries int) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Retries = max(retries, 0)
+	}
+}
+
+// WithLowConfidencePolicy sets the policy applied when the classifier's
+// confidence is under the threshold or classification fails
+func WithLowConfidencePolicy(threshold float64, policy LowConfidencePolicy) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.ConfidenceThreshold = threshold
+		o.LowConfidencePolicy = policy
+	}
+}
+
+func (o HandlerOptions) classifyOptions(history []llms.Turn, tools []llms.Tool) []ClassifyOption {
+	return []ClassifyOption{
+		WithHistory(history),
+		WithTools(tools),
+		WithRegistry(o.Registry),
+		WithRetries(o.Retries),
+	}
+}
+
+// respond responds to the classified interruption, falling back to the low
+// confidence policy if classification failed or isn't confident enough
+func (o HandlerOptions) respond(interruption llms.InterruptionV0, classificationErr error, orchestrator interruptions.OrchestratorV0) (*llms.InterruptionV0, error) {
+	if classificationErr != nil {
+		interruption.DecisionPath = append(interruption.DecisionPath, "classification failed: "+classificationErr.Error())
+		return o.LowConfidencePolicy.apply(interruption, o.Registry, orchestrator)
+	}
+
+	if interruption.Confidence < o.ConfidenceThreshold {
+		interruption.DecisionPath = append(interruption.DecisionPath,
+			fmt.Sprintf("confidence %.2f under threshold %.2f", interruption.Confidence, o.ConfidenceThreshold))
+		return o.LowConfidencePolicy.apply(interruption, o.Registry, orchestrator)
+	}
+
+	interruption.DecisionPath = append(interruption.DecisionPath, "responded as "+interruption.Type)
+	return o.Registry.Respond(interruption, orchestrator)
+}
+
 func findInterruption(id int64, turns emaContext.TurnsV0) *llms.InterruptionV0 {
 	for turn := range turns.RValues {
 		for _, interruption := range turn.Interruptions {
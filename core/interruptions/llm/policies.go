@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/interruptions"
+	"github.com/koscakluka/ema-core/core/llms"
+)
+
+// LowConfidencePolicy describes what to do with an interruption the
+// classifier isn't sure about or failed to classify
+type LowConfidencePolicy string
+
+const (
+	// LowConfidencePolicyNewPrompt responds to the interruption as if it was
+	// a new prompt
+	LowConfidencePolicyNewPrompt LowConfidencePolicy = "new prompt"
+	// LowConfidencePolicyAsk cancels the active turn and has the assistant
+	// ask the user what they meant
+	LowConfidencePolicyAsk LowConfidencePolicy = "ask"
+	// LowConfidencePolicyPause keeps the active turn paused and leaves the
+	// interruption unresolved, the next interruption decides how to continue.
+	// The turn is resumed if no interruption follows within
+	// lowConfidencePauseTimeout.
+	LowConfidencePolicyPause LowConfidencePolicy = "pause"
+)
+
+// lowConfidencePauseTimeout is how long LowConfidencePolicyPause waits for
+// the user to say something else before resuming the turn
+const lowConfidencePauseTimeout = 5 * time.Second
+
+const askPromptFormat = `I said "%s" while you were answering. Briefly ask me whether I want you to continue your previous answer or to address this instead.`
+
+func (p LowConfidencePolicy) apply(interruption llms.InterruptionV0, registry *interruptions.Registry, o interruptions.OrchestratorV0) (*llms.InterruptionV0, error) {
+	interruption.DecisionPath = append(interruption.DecisionPath, "applied low confidence policy: "+string(p))
+
+	switch p {
+	case LowConfidencePolicyNewPrompt:
+		interruption.Type = string(InterruptionTypeNewPrompt)
+		return registry.Respond(interruption, o)
+
+	case LowConfidencePolicyAsk:
+		o.CancelTurn()
+		o.QueuePrompt(fmt.Sprintf(askPromptFormat, interruption.Source))
+		interruption.Resolved = true
+		return &interruption, nil
+
+	case LowConfidencePolicyPause:
+		o.PauseTurn()
+		interruptionCount := countInterruptions(o)
+		time.AfterFunc(lowConfidencePauseTimeout, func() {
+			if countInterruptions(o) == interruptionCount {
+				o.UnpauseTurn()
+			}
+		})
+		interruption.Resolved = false
+		return &interruption, nil
+
+	default:
+		return nil, fmt.Errorf("unknown low confidence policy: %s", p)
+	}
+}
+
+func countInterruptions(o interruptions.OrchestratorV0) int {
+	count := 0
+	for turn := range o.Turns().RValues {
+		count += len(turn.Interruptions)
+	}
+	return count
+}
@@ -2,75 +2,84 @@ package llm
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/koscakluka/ema-core/core/interruptions"
 	"github.com/koscakluka/ema-core/core/llms"
 )
 
-func respond(interruption llms.InterruptionV0, o interruptions.OrchestratorV0) (*llms.InterruptionV0, error) {
-	switch interruptionType(interruption.Type) {
-	case InterruptionTypeContinuation:
-		o.CancelTurn()
-		found := -1
-		count := 0
-		for turn := range o.Turns().RValues {
-			if turn.Role == llms.TurnRoleUser {
-				found = count
-				break
-			}
-			count++
-		}
+var builtInRegistry = NewRegistry()
 
-		if found == -1 {
-			// TODO: Queue prompt since there is nothing to add it to
-			interruption.Resolved = true
-			return &interruption, nil
-		}
+// Respond reacts to the classified interruption using the orchestrator, e.g.
+// cancels the active turn on cancellation or queues a new prompt. Only
+// built-in types are supported, use interruptions.Registry.Respond for custom
+// ones.
+func Respond(interruption llms.InterruptionV0, o interruptions.OrchestratorV0) (*llms.InterruptionV0, error) {
+	return builtInRegistry.Respond(interruption, o)
+}
 
-		for range found {
-			o.Turns().Pop()
+func respondToContinuation(interruption llms.InterruptionV0, o interruptions.OrchestratorV0) (*llms.InterruptionV0, error) {
+	o.CancelTurn()
+	found := -1
+	count := 0
+	for turn := range o.Turns().RValues {
+		if turn.Role == llms.TurnRoleUser {
+			found = count
+			break
 		}
+		count++
+	}
 
-		lastUserTurn := o.Turns().Pop()
-		if lastUserTurn != nil {
-			o.QueuePrompt(lastUserTurn.Content + " " + interruption.Source)
-		} else {
-			o.QueuePrompt(interruption.Source)
-		}
+	if found == -1 {
+		// TODO: Queue prompt since there is nothing to add it to
 		interruption.Resolved = true
 		return &interruption, nil
+	}
 
-	case InterruptionTypeClarification:
-		o.CancelTurn()
-		o.QueuePrompt(interruption.Source)
-		interruption.Resolved = true
-		return &interruption, nil
+	for range found {
+		o.Turns().Pop()
+	}
 
-	case InterruptionTypeCancellation:
-		o.CancelTurn()
-		interruption.Resolved = true
-		return &interruption, nil
+	lastUserTurn := o.Turns().Pop()
+	if lastUserTurn != nil {
+		o.QueuePrompt(lastUserTurn.Content + " " + interruption.Source)
+	} else {
+		o.QueuePrompt(interruption.Source)
+	}
+	interruption.Resolved = true
+	return &interruption, nil
+}
 
-	case InterruptionTypeIgnorable,
-		InterruptionTypeRepetition,
-		InterruptionTypeNoise:
-		interruption.Resolved = true
-		return &interruption, nil
+func respondToClarification(interruption llms.InterruptionV0, o interruptions.OrchestratorV0) (*llms.InterruptionV0, error) {
+	o.CancelTurn()
+	o.QueuePrompt(interruption.Source)
+	interruption.Resolved = true
+	return &interruption, nil
+}
 
-	case InterruptionTypeAction:
-		interruption.Resolved = true
-		if err := o.CallTool(context.TODO(), interruption.Source); err != nil {
-			return nil, err
-		}
-		return &interruption, nil
+func respondToCancellation(interruption llms.InterruptionV0, o interruptions.OrchestratorV0) (*llms.InterruptionV0, error) {
+	o.CancelTurn()
+	interruption.Resolved = true
+	return &interruption, nil
+}
 
-	case InterruptionTypeNewPrompt:
-		o.QueuePrompt(interruption.Source)
-		interruption.Resolved = true
-		return &interruption, nil
+func respondByResuming(interruption llms.InterruptionV0, o interruptions.OrchestratorV0) (*llms.InterruptionV0, error) {
+	o.UnpauseTurn()
+	interruption.Resolved = true
+	return &interruption, nil
+}
 
-	default:
-		return nil, fmt.Errorf("unknown interruption type: %s", interruption.Type)
+func respondToAction(interruption llms.InterruptionV0, o interruptions.OrchestratorV0) (*llms.InterruptionV0, error) {
+	interruption.Resolved = true
+	defer o.UnpauseTurn()
+	if err := o.CallTool(context.TODO(), interruption.Source); err != nil {
+		return nil, err
 	}
+	return &interruption, nil
+}
+
+func respondToNewPrompt(interruption llms.InterruptionV0, o interruptions.OrchestratorV0) (*llms.InterruptionV0, error) {
+	o.CancelTurn()
+	o.QueuePrompt(interruption.Source)
+	interruption.Resolved = true
+	return &interruption, nil
 }
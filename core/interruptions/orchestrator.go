@@ -14,4 +14,11 @@ type OrchestratorV0 interface {
 	QueuePrompt(prompt string)
 
 	CancelTurn()
+
+	// PauseTurn stops the output of the active turn, it can be continued by
+	// calling UnpauseTurn
+	PauseTurn()
+	// UnpauseTurn continues the output of the active turn from the nearest
+	// sentence or silence boundary before the point where it was paused
+	UnpauseTurn()
 }
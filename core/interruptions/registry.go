@@ -0,0 +1,110 @@
+package interruptions
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/koscakluka/ema-core/core/llms"
+)
+
+// Type describes a kind of interruption that can be classified and responded
+// to
+type Type struct {
+	// Name identifies the type, it is what classifiers return and what gets
+	// stored in llms.InterruptionV0.Type
+	Name string
+	// Description explains to the classifier when an interruption is of this
+	// type, examples help
+	Description string
+	// Respond reacts to the interruption, if nil the interruption is just
+	// marked as resolved
+	Respond Responder
+}
+
+// Responder reacts to a classified interruption using the orchestrator and
+// returns the updated interruption
+type Responder func(interruption llms.InterruptionV0, orchestrator OrchestratorV0) (*llms.InterruptionV0, error)
+
+// Registry holds interruption types that handlers can classify interruptions
+// into. Types keep the order in which they were first registered.
+type Registry struct {
+	types []Type
+	mu    sync.RWMutex
+}
+
+func NewRegistry(types ...Type) *Registry {
+	registry := &Registry{}
+	for _, t := range types {
+		registry.Register(t)
+	}
+	return registry
+}
+
+// Register adds the interruption type to the registry, a type with the same
+// name is replaced
+func (r *Registry) Register(t Type) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if idx := slices.IndexFunc(r.types, func(registered Type) bool { return registered.Name == t.Name }); idx != -1 {
+		r.types[idx] = t
+		return
+	}
+	r.types = append(r.types, t)
+}
+
+// Unregister removes the interruption type with the given name, does nothing
+// if it isn't registered
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.types = slices.DeleteFunc(r.types, func(t Type) bool { return t.Name == name })
+}
+
+// Get returns the interruption type with the given name
+func (r *Registry) Get(name string) (Type, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	idx := slices.IndexFunc(r.types, func(t Type) bool { return t.Name == name })
+	if idx == -1 {
+		return Type{}, false
+	}
+	return r.types[idx], true
+}
+
+// Types returns all the registered interruption types
+func (r *Registry) Types() []Type {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Clone(r.types)
+}
+
+// Names returns names of all the registered interruption types
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.types))
+	for _, t := range r.types {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// Respond calls the responder registered for the interruption's type
+func (r *Registry) Respond(interruption llms.InterruptionV0, orchestrator OrchestratorV0) (*llms.InterruptionV0, error) {
+	t, ok := r.Get(interruption.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown interruption type: %s", interruption.Type)
+	}
+
+	if t.Respond == nil {
+		interruption.Resolved = true
+		return &interruption, nil
+	}
+	return t.Respond(interruption, orchestrator)
+}
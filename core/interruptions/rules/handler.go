@@ -0,0 +1,152 @@
+package rules
+
+import (
+	"fmt"
+
+	emaContext "github.com/koscakluka/ema-core/core/context"
+	"github.com/koscakluka/ema-core/core/interruptions"
+	"github.com/koscakluka/ema-core/core/interruptions/llm"
+	"github.com/koscakluka/ema-core/core/llms"
+)
+
+// InterruptionHandler classifies interruptions by matching them against a
+// list of rules, without any LLM calls. Interruptions that no rule matches are
+// passed to the fallback handler if one is set.
+type InterruptionHandler struct {
+	rules    []Rule
+	registry *interruptions.Registry
+	fallback InterruptionHandlerFallback
+
+	noiseConfidenceThreshold float64
+}
+
+// InterruptionHandlerFallback handles interruptions that no rule matched, any
+// of the interruptions/llm handlers can be used
+type InterruptionHandlerFallback interface {
+	HandleV0(prompt string, history []llms.Turn, tools []llms.Tool, orchestrator interruptions.OrchestratorV0) error
+	HandleV1(id int64, orchestrator interruptions.OrchestratorV0, tools []llms.Tool) (*llms.InterruptionV0, error)
+}
+
+func NewInterruptionHandler(opts ...InterruptionHandlerOption) *InterruptionHandler {
+	handler := &InterruptionHandler{}
+	for _, opt := range opts {
+		opt(handler)
+	}
+
+	if handler.rules == nil {
+		handler.rules = DefaultRules()
+	}
+	if handler.registry == nil {
+		handler.registry = llm.NewRegistry()
+	}
+
+	return handler
+}
+
+type InterruptionHandlerOption func(*InterruptionHandler)
+
+// WithRules sets the rules that are checked in order, the first matching rule
+// determines the interruption type. Repeating this option will add more rules,
+// using it replaces the default rules.
+func WithRules(rules ...Rule) InterruptionHandlerOption {
+	return func(h *InterruptionHandler) {
+		h.rules = append(h.rules, rules...)
+	}
+}
+
+// WithTypeRegistry sets the registry used to respond to matched
+// interruptions, rules with types missing from it fail to be handled
+func WithTypeRegistry(registry *interruptions.Registry) InterruptionHandlerOption {
+	return func(h *InterruptionHandler) {
+		h.registry = registry
+	}
+}
+
+// WithNoiseConfidenceThreshold classifies spoken interruptions as noise if
+// their transcription confidence is below the threshold, before any rules are
+// checked
+func WithNoiseConfidenceThreshold(threshold float64) InterruptionHandlerOption {
+	return func(h *InterruptionHandler) {
+		h.noiseConfidenceThreshold = threshold
+	}
+}
+
+// WithFallback sets the handler used when no rule matches the interruption
+func WithFallback(handler InterruptionHandlerFallback) InterruptionHandlerOption {
+	return func(h *InterruptionHandler) {
+		h.fallback = handler
+	}
+}
+
+func (h *InterruptionHandler) HandleV0(prompt string, history []llms.Turn, tools []llms.Tool, orchestrator interruptions.OrchestratorV0) error {
+	interruption := &llms.InterruptionV0{ID: 0, Source: prompt}
+	if interruptionType, ok := h.match(prompt); ok {
+		h.classify(interruption, interruptionType)
+		_, err := h.registry.Respond(*interruption, orchestrator)
+		return err
+	}
+
+	if h.fallback != nil {
+		return h.fallback.HandleV0(prompt, history, tools, orchestrator)
+	}
+	return fmt.Errorf("no rule matched the interruption")
+}
+
+func (h *InterruptionHandler) HandleV1(id int64, orchestrator interruptions.OrchestratorV0, tools []llms.Tool) (*llms.InterruptionV0, error) {
+	interruption := findInterruption(id, orchestrator.Turns())
+	if interruption == nil {
+		return nil, fmt.Errorf("interruption not found")
+	}
+
+	if h.isNoise(*interruption) {
+		interruption.Type = string(llm.InterruptionTypeNoise)
+		interruption.Confidence = 1 - interruption.Transcription.Confidence
+		interruption.DecisionPath = append(interruption.DecisionPath,
+			fmt.Sprintf("transcription confidence %.2f below noise threshold", interruption.Transcription.Confidence))
+		return h.registry.Respond(*interruption, orchestrator)
+	}
+
+	if interruptionType, ok := h.match(interruption.Source); ok {
+		h.classify(interruption, interruptionType)
+		return h.registry.Respond(*interruption, orchestrator)
+	}
+
+	if h.fallback != nil {
+		return h.fallback.HandleV1(id, orchestrator, tools)
+	}
+	return nil, fmt.Errorf("no rule matched the interruption")
+}
+
+// classify sets the type matched by a rule, rules are deterministic so they
+// are fully confident
+func (h *InterruptionHandler) classify(interruption *llms.InterruptionV0, interruptionType string) {
+	interruption.Type = interruptionType
+	interruption.Confidence = 1
+	interruption.DecisionPath = append(interruption.DecisionPath, "matched rule for "+interruptionType)
+}
+
+func (h *InterruptionHandler) isNoise(interruption llms.InterruptionV0) bool {
+	return interruption.Transcription != nil &&
+		interruption.Transcription.Confidence < h.noiseConfidenceThreshold
+}
+
+func (h *InterruptionHandler) match(interruption string) (string, bool) {
+	for _, rule := range h.rules {
+		if rule.matches(interruption) {
+			return rule.Type, true
+		}
+	}
+	return "", false
+}
+
+func findInterruption(id int64, turns emaContext.TurnsV0) *llms.InterruptionV0 {
+	for turn := range turns.RValues {
+		for _, interruption := range turn.Interruptions {
+			if interruption.ID == id {
+				return &interruption
+			}
+		}
+	}
+
+	return nil
+}
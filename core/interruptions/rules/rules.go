@@ -0,0 +1,79 @@
+package rules
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/koscakluka/ema-core/core/interruptions/llm"
+)
+
+// Rule maps an interruption to a type if it matches any of the phrases or the
+// pattern
+type Rule struct {
+	// Type is the interruption type assigned to matched interruptions
+	Type string
+	// Phrases are matched against the whole interruption, ignoring case,
+	// punctuation and repeated whitespace
+	Phrases []string
+	// Pattern is matched against the interruption as it was transcribed, it
+	// matches anywhere in the interruption unless anchored with ^ and $
+	Pattern *regexp.Regexp
+}
+
+// Phrases creates a rule that matches any of the phrases
+func Phrases(interruptionType string, phrases ...string) Rule {
+	normalizedPhrases := make([]string, 0, len(phrases))
+	for _, phrase := range phrases {
+		normalizedPhrases = append(normalizedPhrases, normalize(phrase))
+	}
+
+	return Rule{Type: interruptionType, Phrases: normalizedPhrases}
+}
+
+// Pattern creates a rule that matches the regular expression anywhere in the
+// interruption, unless it is anchored. It panics if the expression cannot be
+// compiled.
+func Pattern(interruptionType string, pattern string) Rule {
+	return Rule{Type: interruptionType, Pattern: regexp.MustCompile(pattern)}
+}
+
+func (r Rule) matches(interruption string) bool {
+	// NOTE: Phrases are normalized again since the field can be set directly
+	normalizedInterruption := normalize(interruption)
+	if slices.ContainsFunc(r.Phrases, func(phrase string) bool {
+		return normalize(phrase) == normalizedInterruption
+	}) {
+		return true
+	}
+
+	return r.Pattern != nil && r.Pattern.MatchString(interruption)
+}
+
+// DefaultRules returns the rules used if none are provided, they cover short
+// commands to stop talking and common backchannels
+func DefaultRules() []Rule {
+	return []Rule{
+		Phrases(string(llm.InterruptionTypeCancellation),
+			"stop", "stop it", "stop talking", "please stop", "stop please",
+			"wait", "wait wait", "hold on", "hang on",
+			"shut up", "be quiet", "quiet", "silence", "enough", "that's enough",
+			"never mind", "nevermind", "cancel", "forget it",
+		),
+		Phrases(string(llm.InterruptionTypeIgnorable),
+			"uh huh", "mhm", "mm hmm", "yeah", "yes", "right", "okay", "ok",
+			"i see", "got it", "sure", "cool", "nice",
+		),
+		Phrases(string(llm.InterruptionTypeNoise),
+			"uh", "um", "hmm", "mm", "ah", "oh", "er",
+		),
+	}
+}
+
+var nonWordCharacters = regexp.MustCompile(`[^\p{L}\p{N}' ]+`)
+
+func normalize(text string) string {
+	text = strings.ToLower(text)
+	text = nonWordCharacters.ReplaceAllString(text, " ")
+	return strings.Join(strings.Fields(text), " ")
+}
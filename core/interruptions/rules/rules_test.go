@@ -0,0 +1,70 @@
+package rules
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/koscakluka/ema-core/core/interruptions/llm"
+)
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		name         string
+		rule         Rule
+		interruption string
+		want         bool
+	}{
+		{"phrase", Phrases("cancellation", "stop"), "stop", true},
+		{"phrase ignores case and punctuation", Phrases("cancellation", "stop"), "Stop!", true},
+		{"phrase ignores repeated whitespace", Phrases("cancellation", "hold on"), "  hold   on ", true},
+		{"phrase matches whole interruption", Phrases("cancellation", "stop"), "don't stop", false},
+		{"literal phrase is normalized", Rule{Type: "cancellation", Phrases: []string{"Stop!"}}, "stop", true},
+		{"literal phrase with whitespace", Rule{Type: "cancellation", Phrases: []string{"Hold  on!"}}, "hold on", true},
+		{"pattern", Pattern("cancellation", `^stop\b`), "stop talking", true},
+		{"unanchored pattern matches anywhere", Pattern("cancellation", `stop`), "don't stop", true},
+		{"anchored pattern", Pattern("cancellation", `^stop$`), "don't stop", false},
+		{"pattern is case sensitive", Rule{Type: "cancellation", Pattern: regexp.MustCompile(`^stop$`)}, "Stop", false},
+		{"empty rule", Rule{Type: "cancellation"}, "stop", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.matches(tt.interruption); got != tt.want {
+				t.Errorf("matches(%q) = %v, want %v", tt.interruption, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultRules(t *testing.T) {
+	tests := []struct {
+		interruption string
+		want         string
+	}{
+		{"Stop!", string(llm.InterruptionTypeCancellation)},
+		{"Wait, wait.", string(llm.InterruptionTypeCancellation)},
+		{"That's enough", string(llm.InterruptionTypeCancellation)},
+		{"Never mind.", string(llm.InterruptionTypeCancellation)},
+		{"Uh-huh", string(llm.InterruptionTypeIgnorable)},
+		{"OK", string(llm.InterruptionTypeIgnorable)},
+		{"Hmm...", string(llm.InterruptionTypeNoise)},
+		{"What's the weather like?", ""},
+		{"Don't stop", ""},
+	}
+
+	rules := DefaultRules()
+	for _, tt := range tests {
+		t.Run(tt.interruption, func(t *testing.T) {
+			got := ""
+			for _, rule := range rules {
+				if rule.matches(tt.interruption) {
+					got = rule.Type
+					break
+				}
+			}
+			if got != tt.want {
+				t.Errorf("DefaultRules matched %q as %q, want %q", tt.interruption, got, tt.want)
+			}
+		})
+	}
+}
@@ -173,6 +173,8 @@ type GPTOSS20BClient struct {
 
 	tools        []llms.Tool
 	systemPrompt string
+
+	usage llms.UsageCounter
 }
 
 func NewGPTOSS20BClient(opts ...ClientOption) (*GPTOSS20BClient, error) {
@@ -197,15 +199,23 @@ func (c *GPTOSS20BClient) PromptWithStream(ctx context.Context, prompt *string,
 }
 
 func (c *GPTOSS20BClient) PromptWithStructure(ctx context.Context, prompt string, outputSchema any, opts ...llms.StructuredPromptOption) error {
-	_, err := PromptJSONSchema(ctx, c.apiKey, string(ModelGPTOSS20B), prompt, c.systemPrompt, outputSchema, opts...)
+	_, usage, err := promptJSONSchema(ctx, c.apiKey, string(ModelGPTOSS20B), prompt, c.systemPrompt, outputSchema, opts...)
+	c.usage.Add(usage)
 	return err
 }
 
+// Usage returns the tokens used by structured prompts so far
+func (c *GPTOSS20BClient) Usage() llms.Usage {
+	return c.usage.Usage()
+}
+
 type GPTOSS120BClient struct {
 	apiKey string
 
 	tools        []llms.Tool
 	systemPrompt string
+
+	usage llms.UsageCounter
 }
 
 func NewGPTOSS120BClient(opts ...ClientOption) (*GPTOSS120BClient, error) {
@@ -230,15 +240,23 @@ func (c *GPTOSS120BClient) PromptWithStream(ctx context.Context, prompt *string,
 }
 
 func (c *GPTOSS120BClient) PromptWithStructure(ctx context.Context, prompt string, outputSchema any, opts ...llms.StructuredPromptOption) error {
-	_, err := PromptJSONSchema(ctx, c.apiKey, string(ModelGPTOSS120B), prompt, c.systemPrompt, outputSchema, opts...)
+	_, usage, err := promptJSONSchema(ctx, c.apiKey, string(ModelGPTOSS120B), prompt, c.systemPrompt, outputSchema, opts...)
+	c.usage.Add(usage)
 	return err
 }
 
+// Usage returns the tokens used by structured prompts so far
+func (c *GPTOSS120BClient) Usage() llms.Usage {
+	return c.usage.Usage()
+}
+
 type Llama4Maverick17BInstructClient struct {
 	apiKey string
 
 	tools        []llms.Tool
 	systemPrompt string
+
+	usage llms.UsageCounter
 }
 
 func NewLlama4Maverick17BInstructClient(opts ...ClientOption) (*Llama4Maverick17BInstructClient, error) {
@@ -263,15 +281,23 @@ func (c *Llama4Maverick17BInstructClient) PromptWithStream(ctx context.Context,
 }
 
 func (c *Llama4Maverick17BInstructClient) PromptWithStructure(ctx context.Context, prompt string, outputSchema any, opts ...llms.StructuredPromptOption) error {
-	_, err := PromptJSONSchema(ctx, c.apiKey, string(ModelLlama4Maverick17BInstruct), prompt, c.systemPrompt, outputSchema, opts...)
+	_, usage, err := promptJSONSchema(ctx, c.apiKey, string(ModelLlama4Maverick17BInstruct), prompt, c.systemPrompt, outputSchema, opts...)
+	c.usage.Add(usage)
 	return err
 }
 
+// Usage returns the tokens used by structured prompts so far
+func (c *Llama4Maverick17BInstructClient) Usage() llms.Usage {
+	return c.usage.Usage()
+}
+
 type Llama4Scout17BInstructClient struct {
 	apiKey string
 
 	tools        []llms.Tool
 	systemPrompt string
+
+	usage llms.UsageCounter
 }
 
 func NewLlama4Scout17BInstructClient(opts ...ClientOption) (*Llama4Scout17BInstructClient, error) {
@@ -296,15 +322,23 @@ func (c *Llama4Scout17BInstructClient) PromptWithStream(ctx context.Context, pro
 }
 
 func (c *Llama4Scout17BInstructClient) PromptWithStructure(ctx context.Context, prompt string, outputSchema any, opts ...llms.StructuredPromptOption) error {
-	_, err := PromptJSONSchema(ctx, c.apiKey, string(ModelLlama4Scout17BInstruct), prompt, c.systemPrompt, outputSchema, opts...)
+	_, usage, err := promptJSONSchema(ctx, c.apiKey, string(ModelLlama4Scout17BInstruct), prompt, c.systemPrompt, outputSchema, opts...)
+	c.usage.Add(usage)
 	return err
 }
 
+// Usage returns the tokens used by structured prompts so far
+func (c *Llama4Scout17BInstructClient) Usage() llms.Usage {
+	return c.usage.Usage()
+}
+
 type KimiK2Instruct0905Client struct {
 	apiKey string
 
 	tools        []llms.Tool
 	systemPrompt string
+
+	usage llms.UsageCounter
 }
 
 func NewKimiK2Instruct0905Client(opts ...ClientOption) (*KimiK2Instruct0905Client, error) {
@@ -329,10 +363,16 @@ func (c *KimiK2Instruct0905Client) PromptWithStream(ctx context.Context, prompt
 }
 
 func (c *KimiK2Instruct0905Client) PromptWithStructure(ctx context.Context, prompt string, outputSchema any, opts ...llms.StructuredPromptOption) error {
-	_, err := PromptJSONSchema(ctx, c.apiKey, string(ModelKimiK2Instruct0905), prompt, c.systemPrompt, outputSchema, opts...)
+	_, usage, err := promptJSONSchema(ctx, c.apiKey, string(ModelKimiK2Instruct0905), prompt, c.systemPrompt, outputSchema, opts...)
+	c.usage.Add(usage)
 	return err
 }
 
+// Usage returns the tokens used by structured prompts so far
+func (c *KimiK2Instruct0905Client) Usage() llms.Usage {
+	return c.usage.Usage()
+}
+
 type Qwen332BClient struct {
 	apiKey string
 
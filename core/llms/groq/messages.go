@@ -44,7 +44,7 @@ func toMessages(instructions string, turns []llms.Turn) []message {
 		case llms.TurnRoleUser:
 			messages = append(messages, message{
 				Role:    messageRoleUser,
-				Content: turn.Content,
+				Content: turn.SpeakerContent(),
 			})
 
 		case llms.TurnRoleAssistant:
@@ -15,7 +15,7 @@ import (
 )
 
 func PromptJSONSchema[T any](
-	_ context.Context,
+	ctx context.Context,
 	apiKey string,
 	model string,
 	prompt string,
@@ -23,6 +23,22 @@ func PromptJSONSchema[T any](
 	outputSchema T,
 	opts ...llms.StructuredPromptOption,
 ) (*T, error) {
+	output, _, err := promptJSONSchema(ctx, apiKey, model, prompt, systemPrompt, outputSchema, opts...)
+	return output, err
+}
+
+// promptJSONSchema is PromptJSONSchema that also returns the tokens used by
+// the request
+func promptJSONSchema[T any](
+	_ context.Context,
+	apiKey string,
+	model string,
+	prompt string,
+	systemPrompt string,
+	outputSchema T,
+	opts ...llms.StructuredPromptOption,
+) (*T, llms.Usage, error) {
+	var usage llms.Usage
 	options := llms.StructuredPromptOptions{
 		BaseOptions: llms.BaseOptions{Instructions: systemPrompt},
 	}
@@ -43,7 +59,13 @@ func PromptJSONSchema[T any](
 		schema         *jsonschema.Schema
 		outputTypeName string
 	)
-	if reflect.TypeOf(outputSchema).Kind() == reflect.Ptr {
+	if schemaProvider, ok := any(outputSchema).(interface{ JSONSchema() *jsonschema.Schema }); ok {
+		// NOTE: Reflector only calls JSONSchema on zero values, so schemas
+		// that depend on the value (e.g. dynamic enums) need to be taken from
+		// the value directly
+		schema = schemaProvider.JSONSchema()
+		outputTypeName = reflect.Indirect(reflect.ValueOf(outputSchema)).Type().Name()
+	} else if reflect.TypeOf(outputSchema).Kind() == reflect.Ptr {
 		schema = reflector.ReflectFromType(reflect.TypeOf(outputSchema).Elem())
 		outputTypeName = reflect.TypeOf(outputSchema).Elem().Name()
 	} else {
@@ -67,12 +89,12 @@ func PromptJSONSchema[T any](
 
 	requestBodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("error marshalling JSON: %w", err)
+		return nil, usage, fmt.Errorf("error marshalling JSON: %w", err)
 	}
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBodyBytes))
 	if err != nil {
-		return nil, fmt.Errorf("error creating HTTP request: %w", err)
+		return nil, usage, fmt.Errorf("error creating HTTP request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -81,11 +103,11 @@ func PromptJSONSchema[T any](
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
+		return nil, usage, fmt.Errorf("error sending request: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("non-OK HTTP status: %s", resp.Status)
+		return nil, usage, fmt.Errorf("non-OK HTTP status: %s", resp.Status)
 	}
 	// response, err := c.ChatCompletion(ctx, request)
 	// if err != nil {
@@ -100,10 +122,19 @@ func PromptJSONSchema[T any](
 	defer resp.Body.Close()
 	respBodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		return nil, usage, fmt.Errorf("error reading response body: %w", err)
 	}
 	var responseBody schemaResponseBody
 	err = json.Unmarshal(respBodyBytes, &responseBody)
+	if responseBody.Usage != nil {
+		usage = llms.Usage{
+			InputTokens:      responseBody.Usage.PromptTokens,
+			PromptTokens:     responseBody.Usage.PromptTokens,
+			OutputTokens:     responseBody.Usage.CompletionTokens,
+			CompletionTokens: responseBody.Usage.CompletionTokens,
+			TotalTokens:      responseBody.Usage.TotalTokens,
+		}
+	}
 
 	content := responseBody.Choices[0].Message.Content
 	split := strings.Split(content, "```")
@@ -112,10 +143,10 @@ func PromptJSONSchema[T any](
 	}
 	err = json.Unmarshal([]byte(content), outputSchema)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling response: %w", err)
+		return nil, usage, fmt.Errorf("error unmarshalling response: %w", err)
 	}
 
-	return &outputSchema, nil
+	return &outputSchema, usage, nil
 }
 
 type schemaRequestBody struct {
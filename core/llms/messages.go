@@ -1,5 +1,7 @@
 package llms
 
+import "github.com/koscakluka/ema-core/core/transcription"
+
 // Message is a single message in a conversation, but actually it represents a
 // response from an LLM. It is an alias for Response for backwards compatibility.
 //
@@ -37,6 +39,12 @@ type Turn struct {
 	Stage         TurnStage
 	Interruptions []InterruptionV0
 
+	// Transcription is the word level transcription of the user's spoken
+	// prompt, nil if the prompt was not spoken
+	Transcription *transcription.Transcription
+	// Speaker is the ID of the user who spoke the prompt, empty if unknown
+	Speaker string
+
 	// ToolCallID is the ID of the tool call that this turn is responding to
 	//
 	// Deprecated: The response is now a ToolCall property, this is only here
@@ -44,11 +52,31 @@ type Turn struct {
 	ToolCallID string
 }
 
+// SpeakerContent returns the turn's content labelled with its speaker, so
+// LLMs can tell apart multiple users. Turns without a speaker are unchanged.
+func (t Turn) SpeakerContent() string {
+	if t.Speaker == "" {
+		return t.Content
+	}
+	return "[Speaker " + t.Speaker + "]: " + t.Content
+}
+
 type InterruptionV0 struct {
 	ID       int64
 	Type     string
 	Source   string
 	Resolved bool
+
+	// Confidence is the classifier's confidence in the Type, from 0 to 1
+	Confidence float64
+	// Rationale is the classifier's short explanation of the Type
+	Rationale string
+	// DecisionPath records the steps taken to handle the interruption, e.g.
+	// failed classification attempts, applied fallbacks and responses
+	DecisionPath []string
+	// Transcription is the word level transcription of the Source, nil if
+	// the interruption was not spoken
+	Transcription *transcription.Transcription
 }
 
 type ToolCall struct {
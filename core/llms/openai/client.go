@@ -82,7 +82,11 @@ func WithModelVersion[T any](modelVersion T) BaseOption[T] {
 	}
 }
 
-type GPT4oClient struct{ baseClient[GPT4oVersion] }
+type GPT4oClient struct {
+	baseClient[GPT4oVersion]
+
+	usage llms.UsageCounter
+}
 
 func NewGPT4oClient(opts ...BaseOption[GPT4oVersion]) (*GPT4oClient, error) {
 	base, err := newBase(ModelGPT4o, defaultGPT4oVersion, opts...)
@@ -94,14 +98,25 @@ func NewGPT4oClient(opts ...BaseOption[GPT4oVersion]) (*GPT4oClient, error) {
 }
 
 func (c *GPT4oClient) Prompt(ctx context.Context, prompt string, opts ...llms.GeneralPromptOption) (*llms.Message, error) {
-	return Prompt(ctx, c.apiKey, buildModelString(c.model, string(c.modelVersion)), prompt, c.systemPrompt, opts...)
+	response, usage, err := promptWithUsage(ctx, c.apiKey, buildModelString(c.model, string(c.modelVersion)), prompt, c.systemPrompt, opts...)
+	c.usage.Add(usage)
+	return response, err
+}
+
+// Usage returns the tokens used by Prompt calls so far
+func (c *GPT4oClient) Usage() llms.Usage {
+	return c.usage.Usage()
 }
 
 func (c *GPT4oClient) PromptWithStream(ctx context.Context, prompt *string, opts ...llms.StreamingPromptOption) llms.Stream {
 	return PromptWithStream(ctx, c.apiKey, buildModelString(c.model, string(c.modelVersion)), prompt, c.systemPrompt, opts...)
 }
 
-type GPT41Client struct{ baseClient[GPT41Version] }
+type GPT41Client struct {
+	baseClient[GPT41Version]
+
+	usage llms.UsageCounter
+}
 
 func NewGPT41Client(opts ...BaseOption[GPT41Version]) (*GPT41Client, error) {
 	base, err := newBase(ModelGPT41, defaultGPT41Version, opts...)
@@ -113,14 +128,25 @@ func NewGPT41Client(opts ...BaseOption[GPT41Version]) (*GPT41Client, error) {
 }
 
 func (c *GPT41Client) Prompt(ctx context.Context, prompt string, opts ...llms.GeneralPromptOption) (*llms.Message, error) {
-	return Prompt(ctx, c.apiKey, buildModelString(c.model, string(c.modelVersion)), prompt, c.systemPrompt, opts...)
+	response, usage, err := promptWithUsage(ctx, c.apiKey, buildModelString(c.model, string(c.modelVersion)), prompt, c.systemPrompt, opts...)
+	c.usage.Add(usage)
+	return response, err
+}
+
+// Usage returns the tokens used by Prompt calls so far
+func (c *GPT41Client) Usage() llms.Usage {
+	return c.usage.Usage()
 }
 
 func (c *GPT41Client) PromptWithStream(ctx context.Context, prompt *string, opts ...llms.StreamingPromptOption) llms.Stream {
 	return PromptWithStream(ctx, c.apiKey, buildModelString(c.model, string(c.modelVersion)), prompt, c.systemPrompt, opts...)
 }
 
-type GPT5NanoClient struct{ baseClient[GPT5NanoVersion] }
+type GPT5NanoClient struct {
+	baseClient[GPT5NanoVersion]
+
+	usage llms.UsageCounter
+}
 
 func NewGPT5NanoClient(opts ...BaseOption[GPT5NanoVersion]) (*GPT5NanoClient, error) {
 	base, err := newBase(ModelGPT5Nano, defaultGPT5NanoVersion, opts...)
@@ -132,7 +158,14 @@ func NewGPT5NanoClient(opts ...BaseOption[GPT5NanoVersion]) (*GPT5NanoClient, er
 }
 
 func (c *GPT5NanoClient) Prompt(ctx context.Context, prompt string, opts ...llms.GeneralPromptOption) (*llms.Message, error) {
-	return Prompt(ctx, c.apiKey, buildModelString(c.model, string(c.modelVersion)), prompt, c.systemPrompt, opts...)
+	response, usage, err := promptWithUsage(ctx, c.apiKey, buildModelString(c.model, string(c.modelVersion)), prompt, c.systemPrompt, opts...)
+	c.usage.Add(usage)
+	return response, err
+}
+
+// Usage returns the tokens used by Prompt calls so far
+func (c *GPT5NanoClient) Usage() llms.Usage {
+	return c.usage.Usage()
 }
 
 func (c *GPT5NanoClient) PromptWithStream(ctx context.Context, prompt *string, opts ...llms.StreamingPromptOption) llms.Stream {
@@ -54,7 +54,7 @@ func toOpenAIMessage(turn llms.Turn) []openAIMessage {
 		return []openAIMessage{{
 			Type:    messageTypeMessage,
 			Role:    messageRoleUser,
-			Content: turn.Content,
+			Content: turn.SpeakerContent(),
 		}}
 
 	case llms.TurnRoleAssistant:
@@ -13,13 +13,27 @@ import (
 )
 
 func Prompt(
-	_ context.Context,
+	ctx context.Context,
 	apiKey string,
 	model string,
 	prompt string,
 	systemPrompt string,
 	opts ...llms.GeneralPromptOption,
 ) (*llms.Message, error) {
+	response, _, err := promptWithUsage(ctx, apiKey, model, prompt, systemPrompt, opts...)
+	return response, err
+}
+
+// promptWithUsage is Prompt that also returns the tokens used by the request
+func promptWithUsage(
+	_ context.Context,
+	apiKey string,
+	model string,
+	prompt string,
+	systemPrompt string,
+	opts ...llms.GeneralPromptOption,
+) (*llms.Message, llms.Usage, error) {
+	var usage llms.Usage
 	options := llms.GeneralPromptOptions{BaseOptions: llms.BaseOptions{Instructions: systemPrompt}}
 	for _, opt := range opts {
 		opt.ApplyToGeneral(&options)
@@ -57,12 +71,12 @@ func Prompt(
 
 	requestBodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("error marshalling JSON: %w", err)
+		return nil, usage, fmt.Errorf("error marshalling JSON: %w", err)
 	}
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBodyBytes))
 	if err != nil {
-		return nil, fmt.Errorf("error creating HTTP request: %w", err)
+		return nil, usage, fmt.Errorf("error creating HTTP request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -72,24 +86,33 @@ func Prompt(
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
+		return nil, usage, fmt.Errorf("error sending request: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
 		// TODO: Retry depending on status, send back a message to the user
 		// to indicate that something is going on
-		return nil, fmt.Errorf("non-OK HTTP status: %s", resp.Status)
+		return nil, usage, fmt.Errorf("non-OK HTTP status: %s", resp.Status)
 		// TODO: OpenAI provides a body with the error message
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		return nil, usage, fmt.Errorf("error reading response body: %w", err)
 	}
 	defer resp.Body.Close()
 
 	var responseBody generalResponseBody
 	if err := json.Unmarshal(bodyBytes, &responseBody); err != nil {
-		return nil, fmt.Errorf("error unmarshalling response body: %w", err)
+		return nil, usage, fmt.Errorf("error unmarshalling response body: %w", err)
+	}
+	if responseBody.Usage != nil {
+		usage = llms.Usage{
+			InputTokens:      responseBody.Usage.InputTokens,
+			PromptTokens:     responseBody.Usage.InputTokens,
+			OutputTokens:     responseBody.Usage.OutputTokens,
+			CompletionTokens: responseBody.Usage.OutputTokens,
+			TotalTokens:      responseBody.Usage.TotalTokens,
+		}
 	}
 
 	response := llms.Message{}
@@ -97,32 +120,32 @@ func Prompt(
 	for _, output := range responseBody.Output {
 		var outputType generalResponseBodyOutputType
 		if err := json.Unmarshal(output, &outputType); err != nil {
-			return nil, fmt.Errorf("error unmarshalling output type: %w", err)
+			return nil, usage, fmt.Errorf("error unmarshalling output type: %w", err)
 		}
 
 		switch outputType.Type {
 		case generalResponseBodyOutputTypeMessage:
 			var outputMessage generalResponseBodyOutputMessage
 			if err := json.Unmarshal(output, &outputMessage); err != nil {
-				return nil, fmt.Errorf("error unmarshalling output message: %w", err)
+				return nil, usage, fmt.Errorf("error unmarshalling output message: %w", err)
 			}
 			response.Role = llms.MessageRoleAssistant
 			for _, content := range outputMessage.Content {
 				var contentType generalResponseBodyOutputMessageType
 				if err := json.Unmarshal(content, &contentType); err != nil {
-					return nil, fmt.Errorf("error unmarshalling output message content: %w", err)
+					return nil, usage, fmt.Errorf("error unmarshalling output message content: %w", err)
 				}
 				switch contentType.Type {
 				case "output_text":
 					var outputText generalResponseBodyOutputMessageContentOutputText
 					if err := json.Unmarshal(content, &outputText); err != nil {
-						return nil, fmt.Errorf("error unmarshalling output message content output text: %w", err)
+						return nil, usage, fmt.Errorf("error unmarshalling output message content output text: %w", err)
 					}
 					response.Content = outputText.Text
 				case "refusal":
 					var outputRefusal generalResponseBodyOutputMessageContentRefusal
 					if err := json.Unmarshal(content, &outputRefusal); err != nil {
-						return nil, fmt.Errorf("error unmarshalling output message content refusal: %w", err)
+						return nil, usage, fmt.Errorf("error unmarshalling output message content refusal: %w", err)
 					}
 					response.Content = outputRefusal.Refusal
 				}
@@ -132,7 +155,7 @@ func Prompt(
 		case generalResponseBodyOutputTypeFunctionCall:
 			var outputFunctionCall generalResponseBodyOutputFunctionCall
 			if err := json.Unmarshal(output, &outputFunctionCall); err != nil {
-				return nil, fmt.Errorf("error unmarshalling output function call: %w", err)
+				return nil, usage, fmt.Errorf("error unmarshalling output function call: %w", err)
 			}
 			response.ToolCalls = append(response.ToolCalls, llms.ToolCall{
 				ID:        outputFunctionCall.CallID,
@@ -147,7 +170,7 @@ func Prompt(
 		}
 	}
 
-	return &response, nil
+	return &response, usage, nil
 }
 
 type requestBody struct {
@@ -165,9 +188,8 @@ type requestBodyReasoning struct {
 }
 
 type generalResponseBody struct {
-	Output []json.RawMessage `json:"output"`
-	// TODO: Find a way to pass usage in the response
-	// Usage  responseBodyUsage `json:"usage"`
+	Output []json.RawMessage  `json:"output"`
+	Usage  *responseBodyUsage `json:"usage"`
 }
 
 type generalResponseBodyOutputType struct {
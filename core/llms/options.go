@@ -176,7 +176,7 @@ func ToMessages(turns []Turn) []Message {
 		case TurnRoleUser:
 			messages = append(messages, Message{
 				Role:    MessageRoleUser,
-				Content: turn.Content,
+				Content: turn.SpeakerContent(),
 			})
 		case TurnRoleAssistant:
 			if len(turn.ToolCalls) > 0 {
@@ -0,0 +1,28 @@
+package llms
+
+import "sync"
+
+// UsageCounter sums up the tokens used by multiple requests, it is safe for
+// concurrent use
+type UsageCounter struct {
+	usage Usage
+	mu    sync.Mutex
+}
+
+// Add adds the tokens of a single request
+func (c *UsageCounter) Add(usage Usage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usage.InputTokens += usage.InputTokens
+	c.usage.PromptTokens += usage.PromptTokens
+	c.usage.OutputTokens += usage.OutputTokens
+	c.usage.CompletionTokens += usage.CompletionTokens
+	c.usage.TotalTokens += usage.TotalTokens
+}
+
+// Usage returns the tokens used so far
+func (c *UsageCounter) Usage() Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usage
+}
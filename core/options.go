@@ -2,8 +2,11 @@ package orchestration
 
 import (
 	"context"
+	"time"
 
 	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/audio/echo"
+	"github.com/koscakluka/ema-core/core/audio/vad"
 	"github.com/koscakluka/ema-core/core/interruptions"
 	"github.com/koscakluka/ema-core/core/llms"
 	"github.com/koscakluka/ema-core/core/speechtotext"
@@ -33,9 +36,94 @@ type SpeechToText interface {
 	SendAudio(audio []byte) error
 }
 
-func WithSpeechToTextClient(client SpeechToText) OrchestratorOption {
+// SpeechToTextFinalizer is implemented by speech to text clients that can
+// transcribe the audio sent so far right away, instead of waiting for the end
+// of speech to be detected. It is called when recording stops.
+type SpeechToTextFinalizer interface {
+	Finalize() error
+}
+
+// WithSpeechToTextClient sets the speech to text client, opts are passed to
+// the client when transcription starts (e.g. language, model or keywords)
+func WithSpeechToTextClient(client SpeechToText, opts ...speechtotext.TranscriptionOption) OrchestratorOption {
 	return func(o *Orchestrator) {
 		o.speechToTextClient = client
+		o.speechToTextOptions = opts
+	}
+}
+
+// WithPrimarySpeakerOnly makes the orchestrator respond only to the primary
+// speaker and enables diarization on the speech to text client. An empty
+// speaker enrolls the first speaker who is heard, speech without a speaker is
+// ignored. Speaker IDs are only valid for a single connection, so the next
+// speaker who is heard is enrolled after the client reconnects.
+func WithPrimarySpeakerOnly(speaker string) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.speakerFilter.enabled = true
+		o.speakerFilter.primary = speaker
+	}
+}
+
+// WithVoiceActivityDetection detects speech locally in the audio sent to the
+// speech to text client and uses it instead of the provider's speech events,
+// which saves a network round trip when the user barges in
+func WithVoiceActivityDetection(opts ...vad.Option) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.voiceActivity.enabled = true
+		o.voiceActivity.options = opts
+	}
+}
+
+// WithVoiceActivityGating only sends audio to the speech to text client while
+// speech is detected locally, so silence isn't uploaded. It enables voice
+// activity detection.
+func WithVoiceActivityGating(opts ...vad.Option) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.voiceActivity.enabled = true
+		o.voiceActivity.gate = true
+		o.voiceActivity.options = opts
+	}
+}
+
+// WithEchoCancellation removes the echo of the played audio from the
+// captured audio with an adaptive filter, the played audio is converted to
+// the input's sample rate if they differ
+func WithEchoCancellation(opts ...echo.Option) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.echoSuppression.cancellation = true
+		o.echoSuppression.cancellerOptions = opts
+	}
+}
+
+// WithEchoTranscriptFilter drops user transcripts that match the recently
+// spoken assistant text while it is playing, threshold is the share of the
+// transcript's words that have to match in order (0.8 if zero)
+func WithEchoTranscriptFilter(threshold float64) OrchestratorOption {
+	return func(o *Orchestrator) {
+		if threshold == 0 {
+			threshold = defaultEchoTranscriptThreshold
+		}
+		o.echoSuppression.transcriptFilter = true
+		o.echoSuppression.transcriptThreshold = threshold
+	}
+}
+
+// WithWakeWord turns on the wake word mode, the audio is captured
+// continuously but the orchestrator only responds to speech after the wake
+// phrase and during the follow-up window after a response
+func WithWakeWord(phrase string, opts ...WakeWordOption) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.wakeWord.configure(phrase, opts...)
+	}
+}
+
+// WithPreRoll keeps the last duration of captured audio while not recording
+// and sends it when recording starts, so push to talk doesn't cut off the
+// first word. The audio is captured continuously.
+func WithPreRoll(duration time.Duration) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.preRoll.enabled = duration > 0
+		o.preRoll.duration = duration
 	}
 }
 
@@ -45,6 +133,13 @@ type TextToSpeech interface {
 	FlushBuffer() error
 }
 
+// TextToSpeechStreamCloser is implemented by text to speech clients whose
+// stream can be closed. It is called when the orchestration fails to start
+// after the stream is opened.
+type TextToSpeechStreamCloser interface {
+	CloseStream(ctx context.Context) error
+}
+
 func WithTextToSpeechClient(client TextToSpeech) OrchestratorOption {
 	return func(o *Orchestrator) {
 		o.textToSpeechClient = client
@@ -93,6 +188,17 @@ func WithAudioOutputV1(client AudioOutputV1) OrchestratorOption {
 	}
 }
 
+// AudioOutputWithPosition is implemented by audio outputs that know how much
+// of the sent audio was actually played, e.g. by counting frames in the device
+// callback. For other outputs the played audio is estimated from the elapsed
+// time, with the output's latency calibrated from marks.
+type AudioOutputWithPosition interface {
+	// PlayedFrames returns the number of frames of the sent audio that were
+	// played since the output was created. Silence played while the output
+	// has no audio and audio dropped by ClearBuffer are not counted.
+	PlayedFrames() int64
+}
+
 func WithTools(tools ...llms.Tool) OrchestratorOption {
 	return func(o *Orchestrator) {
 		o.tools = tools
@@ -138,12 +244,20 @@ func WithConfig(config *Config) OrchestratorOption {
 type OrchestrateOptions struct {
 	onTranscription        func(transcript string)
 	onInterimTranscription func(transcript string)
+
+	onTranscriptionResult        func(transcription speechtotext.Transcription)
+	onInterimTranscriptionResult func(transcription speechtotext.Transcription)
+
 	onSpeakingStateChanged func(isSpeaking bool)
 	onResponse             func(response string)
 	onResponseEnd          func()
 	onCancellation         func()
 	onAudio                func(audio []byte)
 	onAudioEnded           func(transcript string)
+	onWakeWord             func()
+
+	onSpeechToTextConnectionStateChanged func(state speechtotext.ConnectionState)
+	onTextToSpeechConnectionStateChanged func(state texttospeech.ConnectionState)
 }
 
 type OrchestrateOption func(*OrchestrateOptions)
@@ -160,6 +274,22 @@ func WithInterimTranscriptionCallback(callback func(transcript string)) Orchestr
 	}
 }
 
+// WithTranscriptionResultCallback sets the callback called with the word
+// level transcription of the user's speech once they stop speaking
+func WithTranscriptionResultCallback(callback func(transcription speechtotext.Transcription)) OrchestrateOption {
+	return func(o *OrchestrateOptions) {
+		o.onTranscriptionResult = callback
+	}
+}
+
+// WithInterimTranscriptionResultCallback sets the callback called with the
+// word level transcription of the user's speech while they are speaking
+func WithInterimTranscriptionResultCallback(callback func(transcription speechtotext.Transcription)) OrchestrateOption {
+	return func(o *OrchestrateOptions) {
+		o.onInterimTranscriptionResult = callback
+	}
+}
+
 func WithSpeakingStateChangedCallback(callback func(isSpeaking bool)) OrchestrateOption {
 	return func(o *OrchestrateOptions) {
 		o.onSpeakingStateChanged = callback
@@ -184,6 +314,9 @@ func WithCancellationCallback(callback func()) OrchestrateOption {
 	}
 }
 
+// WithAudioCallback sets the callback called with the assistant's audio as
+// the text to speech client produces it. Every chunk is passed once, even if
+// the output is paused and replays it.
 func WithAudioCallback(callback func(audio []byte)) OrchestrateOption {
 	return func(o *OrchestrateOptions) {
 		o.onAudio = callback
@@ -196,6 +329,30 @@ func WithAudioEndedCallback(callback func(transcript string)) OrchestrateOption
 	}
 }
 
+// WithWakeWordCallback sets the callback called when the wake phrase
+// activates the orchestrator
+func WithWakeWordCallback(callback func()) OrchestrateOption {
+	return func(o *OrchestrateOptions) {
+		o.onWakeWord = callback
+	}
+}
+
+// WithSpeechToTextConnectionStateCallback sets the callback called when the
+// speech to text client's connection changes its state
+func WithSpeechToTextConnectionStateCallback(callback func(state speechtotext.ConnectionState)) OrchestrateOption {
+	return func(o *OrchestrateOptions) {
+		o.onSpeechToTextConnectionStateChanged = callback
+	}
+}
+
+// WithTextToSpeechConnectionStateCallback sets the callback called when the
+// text to speech client's connection changes its state
+func WithTextToSpeechConnectionStateCallback(callback func(state texttospeech.ConnectionState)) OrchestrateOption {
+	return func(o *OrchestrateOptions) {
+		o.onTextToSpeechConnectionStateChanged = callback
+	}
+}
+
 type LLM any
 
 type audioOutput interface {
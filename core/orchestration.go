@@ -3,12 +3,15 @@ package orchestration
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"log"
 
 	emaContext "github.com/koscakluka/ema-core/core/context"
 	"github.com/koscakluka/ema-core/core/llms"
+	"github.com/koscakluka/ema-core/core/speechtotext"
 )
 
 type Orchestrator struct {
@@ -17,15 +20,26 @@ type Orchestrator struct {
 
 	turns Turns
 
-	outputTextBuffer  textBuffer
-	outputAudioBuffer audioBuffer
-	transcripts       chan string
+	outputTextBuffer  *textBuffer
+	outputAudioBuffer *audioBuffer
+	transcripts       chan llms.Turn
 	promptEnded       sync.WaitGroup
+	interruptionPause interruptionPause
+	lastTranscription atomic.Pointer[speechtotext.Transcription]
+	speakerFilter     speakerFilter
+	wakeWord          wakeWord
+	preRoll           preRoll
+	voiceActivity     voiceActivity
+	echoSuppression   echoSuppression
+	audioFormats      audioFormats
+	playbackPosition  playbackPosition
+	sessionRecorder   SessionRecorder
 
 	tools []llms.Tool
 
 	llm                    LLM
 	speechToTextClient     SpeechToText
+	speechToTextOptions    []speechtotext.TranscriptionOption
 	textToSpeechClient     TextToSpeech
 	audioInput             AudioInput
 	audioOutput            audioOutput
@@ -41,11 +55,11 @@ func NewOrchestrator(opts ...OrchestratorOption) *Orchestrator {
 	o := &Orchestrator{
 		IsRecording:       false,
 		IsSpeaking:        false,
-		transcripts:       make(chan string, 10), // TODO: Figure out good valiues for this
+		transcripts:       make(chan llms.Turn, 10), // TODO: Figure out good valiues for this
 		config:            &Config{AlwaysRecording: true},
 		turns:             Turns{activeTurnIdx: -1},
-		outputTextBuffer:  *newTextBuffer(),
-		outputAudioBuffer: *newAudioBuffer(),
+		outputTextBuffer:  newTextBuffer(),
+		outputAudioBuffer: newAudioBuffer(),
 	}
 
 	for _, opt := range opts {
@@ -81,21 +95,28 @@ func (o *Orchestrator) Close() {
 	close(o.transcripts)
 }
 
-func (o *Orchestrator) Orchestrate(ctx context.Context, opts ...OrchestrateOption) {
+// Orchestrate starts the orchestration, it returns an error if the speech to
+// text client fails to start transcribing
+func (o *Orchestrator) Orchestrate(ctx context.Context, opts ...OrchestrateOption) error {
 	o.orchestrateOptions = OrchestrateOptions{}
 	for _, opt := range opts {
 		opt(&o.orchestrateOptions)
 	}
 
 	o.initTTS()
-	o.initSST()
+	if err := o.initSST(); err != nil {
+		o.closeTTS()
+		return err
+	}
+	o.startSessionRecording()
 
 	go o.startAssistantLoop()
 	o.initAudioInput()
+	return nil
 }
 
 func (o *Orchestrator) SendPrompt(prompt string) {
-	o.processUserTurn(prompt)
+	o.processUserTurn(prompt, nil)
 }
 
 func (o *Orchestrator) SendAudio(audio []byte) error {
@@ -112,7 +133,7 @@ func (o *Orchestrator) QueuePrompt(prompt string) {
 func (o *Orchestrator) SetSpeaking(isSpeaking bool) {
 	o.IsSpeaking = isSpeaking
 	if o.audioOutput != nil {
-		o.audioOutput.ClearBuffer()
+		o.clearAudioOutput()
 	}
 }
 
@@ -129,17 +150,49 @@ func (o *Orchestrator) SetAlwaysRecording(isAlwaysRecording bool) {
 				log.Printf("Failed to start audio input: %v", err)
 			}
 		}()
-	} else if !o.IsRecording {
+	} else if !o.IsRecording && !o.capturesContinuously() {
 		if err := o.stopCapture(); err != nil {
 			log.Printf("Failed to stop audio input: %v", err)
 		}
 	}
 }
 
+// SetWakeWordMode turns the wake word mode on or off, while it is on the
+// audio is captured continuously but only the speech after the wake phrase
+// is responded to. The wake phrase is set with WithWakeWord.
+func (o *Orchestrator) SetWakeWordMode(enabled bool) {
+	if enabled && !o.wakeWord.configured() {
+		log.Println("Warning: wake word mode enabled without a wake phrase, use WithWakeWord")
+		return
+	}
+
+	o.wakeWord.setEnabled(enabled)
+
+	if enabled {
+		go func() {
+			if err := o.startCapture(); err != nil {
+				log.Printf("Failed to start audio input: %v", err)
+			}
+		}()
+	} else if !o.IsRecording && !o.capturesContinuously() {
+		if err := o.stopCapture(); err != nil {
+			log.Printf("Failed to stop audio input: %v", err)
+		}
+	}
+}
+
+func (o *Orchestrator) IsWakeWordMode() bool {
+	return o.wakeWord.isEnabled()
+}
+
 func (o *Orchestrator) StartRecording() error {
 	o.IsRecording = true
 
-	if o.config.AlwaysRecording {
+	// NOTE: Pushing to talk is as good as saying the wake phrase
+	if o.wakeWord.isEnabled() {
+		o.wakeWord.activate()
+	}
+	if o.capturesContinuously() {
 		return nil
 	}
 
@@ -148,25 +201,43 @@ func (o *Orchestrator) StartRecording() error {
 
 func (o *Orchestrator) StopRecording() error {
 	o.IsRecording = false
-	if o.config.AlwaysRecording {
-		return nil
+	if !o.capturesContinuously() {
+		if err := o.stopCapture(); err != nil {
+			return err
+		}
 	}
 
-	return o.stopCapture()
+	// NOTE: Without this the last words only come back once the speech to
+	// text client detects the end of speech
+	if !o.config.AlwaysRecording {
+		o.finalizeTranscription()
+	}
+	return nil
 }
 
 func (o *Orchestrator) Turns() emaContext.TurnsV0 {
 	return &o.turns
 }
 
+// TurnsSnapshot returns a copy of the turns that is safe to read while the
+// orchestration is running
+func (o *Orchestrator) TurnsSnapshot() []llms.Turn {
+	return o.turns.Snapshot()
+}
+
+// Tools returns the tools available to the LLM
+func (o *Orchestrator) Tools() []llms.Tool {
+	return slices.Clone(o.tools)
+}
+
 func (o *Orchestrator) CallTool(ctx context.Context, prompt string) error {
 	switch o.llm.(type) {
 	case LLMWithStream:
-		_, err := o.processStreaming(ctx, prompt, o.turns.turns, newTextBuffer())
+		_, err := o.processStreaming(ctx, prompt, o.turns.Snapshot(), newTextBuffer())
 		return err
 
 	case LLMWithPrompt:
-		_, err := o.processPromptOld(ctx, prompt, o.turns.turns, newTextBuffer())
+		_, err := o.processPromptOld(ctx, prompt, o.turns.Snapshot(), newTextBuffer())
 		return err
 
 	default:
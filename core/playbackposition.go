@@ -0,0 +1,54 @@
+package orchestration
+
+import (
+	"sync"
+	"time"
+)
+
+// playbackPosition tracks how much of the audio sent to an output that
+// reports its position is still waiting to be played
+type playbackPosition struct {
+	// sentFrames is the output's played frame count once all audio sent so
+	// far is played
+	sentFrames int64
+	mu         sync.Mutex
+}
+
+func (o *Orchestrator) outputSent(frames int) {
+	if _, ok := o.audioOutput.(AudioOutputWithPosition); !ok {
+		return
+	}
+
+	o.playbackPosition.mu.Lock()
+	defer o.playbackPosition.mu.Unlock()
+	o.playbackPosition.sentFrames += int64(frames)
+}
+
+func (o *Orchestrator) outputCleared() {
+	output, ok := o.audioOutput.(AudioOutputWithPosition)
+	if !ok {
+		return
+	}
+
+	o.playbackPosition.mu.Lock()
+	defer o.playbackPosition.mu.Unlock()
+	o.playbackPosition.sentFrames = output.PlayedFrames()
+}
+
+// unplayedOutput returns the duration of the audio the output received but
+// didn't play yet, ok is false if the output can't report its position
+func (o *Orchestrator) unplayedOutput() (unplayed time.Duration, ok bool) {
+	output, ok := o.audioOutput.(AudioOutputWithPosition)
+	if !ok {
+		return 0, false
+	}
+	sampleRate := o.audioOutput.EncodingInfo().SampleRate
+	if sampleRate <= 0 {
+		return 0, false
+	}
+
+	o.playbackPosition.mu.Lock()
+	defer o.playbackPosition.mu.Unlock()
+	frames := max(o.playbackPosition.sentFrames-output.PlayedFrames(), 0)
+	return time.Duration(frames) * time.Second / time.Duration(sampleRate), true
+}
@@ -0,0 +1,78 @@
+package orchestration
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// preRoll keeps the most recent captured audio while not recording, so the
+// speech started just before push to talk isn't cut off
+type preRoll struct {
+	enabled  bool
+	duration time.Duration
+
+	audio []byte
+	mu    sync.Mutex
+}
+
+// add stores the audio, dropping the oldest audio over the duration.
+// maxBytes is rounded down to whole frames so the audio stays aligned.
+func (p *preRoll) add(audio []byte, bytesPerSecond, bytesPerFrame int) {
+	maxBytes := int(p.duration.Seconds() * float64(bytesPerSecond))
+	if bytesPerFrame > 0 {
+		maxBytes -= maxBytes % bytesPerFrame
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.audio = append(p.audio, audio...)
+	if excess := len(p.audio) - maxBytes; excess > 0 {
+		if bytesPerFrame > 0 && excess%bytesPerFrame != 0 {
+			excess += bytesPerFrame - excess%bytesPerFrame
+		}
+		p.audio = p.audio[min(excess, len(p.audio)):]
+	}
+}
+
+// flush returns the stored audio and empties the buffer
+func (p *preRoll) flush() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	audio := p.audio
+	p.audio = nil
+	return audio
+}
+
+// addToPreRoll stores the captured audio in the pre-roll buffer if it is
+// enabled, audio is in the audio input's encoding
+func (o *Orchestrator) addToPreRoll(audio []byte) {
+	if !o.preRoll.enabled {
+		return
+	}
+
+	encodingInfo := defaultEncodingInfo
+	if o.audioInput != nil {
+		encodingInfo = o.audioInput.EncodingInfo()
+	}
+	bytesPerFrame := encodingInfo.BytesPerFrame()
+	o.preRoll.add(audio, encodingInfo.SampleRate*bytesPerFrame, bytesPerFrame)
+}
+
+// capturesContinuously reports whether the audio capture keeps running while
+// not recording
+func (o *Orchestrator) capturesContinuously() bool {
+	return o.config.AlwaysRecording || o.wakeWord.isEnabled() || o.preRoll.enabled
+}
+
+// finalizeTranscription makes the speech to text client transcribe the audio
+// sent so far right away, if it supports it
+func (o *Orchestrator) finalizeTranscription() {
+	finalizer, ok := o.speechToTextClient.(SpeechToTextFinalizer)
+	if !ok {
+		return
+	}
+	if err := finalizer.Finalize(); err != nil {
+		log.Printf("Failed to finalize transcription: %v", err)
+	}
+}
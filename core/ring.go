@@ -0,0 +1,62 @@
+package orchestration
+
+// ring is a fixed capacity circular buffer. Items are addressed by their
+// absolute position, which keeps growing as items are pushed, so positions
+// held by consumers stay valid after the buffer wraps around. It is not safe
+// for concurrent use, the buffers using it hold their own lock.
+type ring[T any] struct {
+	items []T
+	// start is the position of the oldest stored item and end the position
+	// the next pushed item gets
+	start int
+	end   int
+}
+
+func newRing[T any](capacity int) ring[T] {
+	return ring[T]{items: make([]T, max(capacity, 1))}
+}
+
+// Push stores the item, overwriting the oldest one if the ring is full
+func (r *ring[T]) Push(item T) {
+	if r.Full() {
+		r.Discard(r.start + 1)
+	}
+	r.items[r.end%len(r.items)] = item
+	r.end++
+}
+
+// Get returns the item at the position, ok is false if it was discarded or
+// wasn't pushed yet
+func (r *ring[T]) Get(position int) (item T, ok bool) {
+	if position < r.start || position >= r.end {
+		return item, false
+	}
+	return r.items[position%len(r.items)], true
+}
+
+func (r *ring[T]) Start() int {
+	return r.start
+}
+
+func (r *ring[T]) End() int {
+	return r.end
+}
+
+func (r *ring[T]) Full() bool {
+	return r.end-r.start == len(r.items)
+}
+
+// Discard drops the items before the position, the slots are zeroed so the
+// dropped items can be garbage collected
+func (r *ring[T]) Discard(position int) {
+	var zero T
+	for ; r.start < min(position, r.end); r.start++ {
+		r.items[r.start%len(r.items)] = zero
+	}
+}
+
+// DiscardAll drops all stored items, positions keep growing from where they
+// were
+func (r *ring[T]) DiscardAll() {
+	r.Discard(r.end)
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/llms"
+)
+
+type sessionResponse struct {
+	ID        string    `json:"id"`
+	Profile   string    `json:"profile"`
+	CreatedAt time.Time `json:"createdAt"`
+	Muted     bool      `json:"muted"`
+}
+
+func newSessionResponse(s *session) sessionResponse {
+	return sessionResponse{
+		ID:        s.id,
+		Profile:   s.profile,
+		CreatedAt: s.createdAt,
+		Muted:     !s.orchestrator.IsSpeaking,
+	}
+}
+
+type turnResponse struct {
+	Role          string             `json:"role"`
+	Content       string             `json:"content"`
+	Stage         string             `json:"stage,omitempty"`
+	Cancelled     bool               `json:"cancelled,omitempty"`
+	Speaker       string             `json:"speaker,omitempty"`
+	ToolCalls     []toolCallResponse `json:"toolCalls,omitempty"`
+	Interruptions []string           `json:"interruptions,omitempty"`
+}
+
+type toolCallResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Response  string `json:"response,omitempty"`
+}
+
+func newTurnResponse(turn llms.Turn) turnResponse {
+	response := turnResponse{
+		Role:      string(turn.Role),
+		Content:   turn.Content,
+		Stage:     string(turn.Stage),
+		Cancelled: turn.Cancelled,
+		Speaker:   turn.Speaker,
+	}
+	for _, toolCall := range turn.ToolCalls {
+		response.ToolCalls = append(response.ToolCalls, toolCallResponse{
+			ID:        toolCall.ID,
+			Name:      toolCall.Name,
+			Arguments: toolCall.Arguments,
+			Response:  toolCall.Response,
+		})
+	}
+	for _, interruption := range turn.Interruptions {
+		response.Interruptions = append(response.Interruptions, interruption.Source)
+	}
+	return response
+}
+
+type toolResponse struct {
+	Name        string                       `json:"name"`
+	Description string                       `json:"description"`
+	Parameters  map[string]parameterResponse `json:"parameters"`
+}
+
+type parameterResponse struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+func newToolResponse(tool llms.Tool) toolResponse {
+	response := toolResponse{
+		Name:        tool.Function.Name,
+		Description: tool.Function.Description,
+		Parameters:  map[string]parameterResponse{},
+	}
+	for name, parameter := range tool.Function.Parameters {
+		response.Parameters[name] = parameterResponse{Type: parameter.Type, Description: parameter.Description}
+	}
+	return response
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,398 @@
+// Package server exposes EMA sessions to other services over HTTP. Every
+// session has its own orchestrator built from a named profile, e.g. a set of
+// providers.
+//
+// Routes:
+//
+//	GET    /profiles                 names of the profiles
+//	GET    /sessions                 sessions
+//	POST   /sessions                 create a session {"profile": "..."}
+//	GET    /sessions/{id}            session
+//	DELETE /sessions/{id}            close a session
+//	POST   /sessions/{id}/prompts    send a prompt {"prompt": "..."}
+//	POST   /sessions/{id}/cancel     cancel the active turn
+//	POST   /sessions/{id}/mute       mute or unmute speech {"muted": true}
+//	GET    /sessions/{id}/turns      turn history
+//	GET    /sessions/{id}/tools      tool catalog
+//	GET    /sessions/{id}/events     event stream (server-sent events)
+//	GET    /sessions/{id}/ws         event stream and commands (WebSocket)
+//
+// Over the WebSocket the events are sent as JSON messages and the same
+// commands can be sent back as {"type": "prompt", "text": "..."},
+// {"type": "cancel"} and {"type": "mute", "muted": true}.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	orchestration "github.com/koscakluka/ema-core/core"
+)
+
+// OrchestratorFactory creates the orchestrator of a new session. The returned
+// release function is called when the session is closed, it should close the
+// providers' clients and then the orchestrator.
+type OrchestratorFactory func(ctx context.Context) (orchestrator *orchestration.Orchestrator, release func(), err error)
+
+type Server struct {
+	options  ServerOptions
+	upgrader websocket.Upgrader
+	mux      *http.ServeMux
+
+	sessions map[string]*session
+	// pendingSessions are being created, they count against MaxSessions
+	pendingSessions int
+	mu              sync.Mutex
+}
+
+type ServerOptions struct {
+	Profiles map[string]OrchestratorFactory
+	// DefaultProfile is used for sessions created without a profile
+	DefaultProfile string
+	// MaxSessions limits the number of concurrent sessions, zero means no
+	// limit
+	MaxSessions int
+	// CheckOrigin decides whether a WebSocket connection from another
+	// origin is accepted, by default only the same origin is
+	CheckOrigin func(r *http.Request) bool
+}
+
+type ServerOption func(*ServerOptions)
+
+// WithProfile adds a profile sessions can be created from, the first added
+// profile is the default one
+func WithProfile(name string, newOrchestrator OrchestratorFactory) ServerOption {
+	return func(o *ServerOptions) {
+		if o.Profiles == nil {
+			o.Profiles = map[string]OrchestratorFactory{}
+		}
+		o.Profiles[name] = newOrchestrator
+		if o.DefaultProfile == "" {
+			o.DefaultProfile = name
+		}
+	}
+}
+
+// WithDefaultProfile sets the profile used for sessions created without one
+func WithDefaultProfile(name string) ServerOption {
+	return func(o *ServerOptions) {
+		o.DefaultProfile = name
+	}
+}
+
+// WithMaxSessions limits the number of concurrent sessions
+func WithMaxSessions(maxSessions int) ServerOption {
+	return func(o *ServerOptions) {
+		o.MaxSessions = maxSessions
+	}
+}
+
+// WithCheckOrigin sets the function that decides whether a WebSocket
+// connection from another origin is accepted
+func WithCheckOrigin(checkOrigin func(r *http.Request) bool) ServerOption {
+	return func(o *ServerOptions) {
+		o.CheckOrigin = checkOrigin
+	}
+}
+
+func NewServer(opts ...ServerOption) *Server {
+	options := ServerOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	s := &Server{
+		options:  options,
+		upgrader: websocket.Upgrader{CheckOrigin: options.CheckOrigin},
+		mux:      http.NewServeMux(),
+		sessions: map[string]*session{},
+	}
+
+	s.mux.HandleFunc("GET /profiles", s.listProfiles)
+	s.mux.HandleFunc("GET /sessions", s.listSessions)
+	s.mux.HandleFunc("POST /sessions", s.createSession)
+	s.mux.HandleFunc("GET /sessions/{id}", s.withSession(s.getSession))
+	s.mux.HandleFunc("DELETE /sessions/{id}", s.deleteSession)
+	s.mux.HandleFunc("POST /sessions/{id}/prompts", s.withSession(s.sendPrompt))
+	s.mux.HandleFunc("POST /sessions/{id}/cancel", s.withSession(s.cancelTurn))
+	s.mux.HandleFunc("POST /sessions/{id}/mute", s.withSession(s.mute))
+	s.mux.HandleFunc("GET /sessions/{id}/turns", s.withSession(s.listTurns))
+	s.mux.HandleFunc("GET /sessions/{id}/tools", s.withSession(s.listTools))
+	s.mux.HandleFunc("GET /sessions/{id}/events", s.withSession(s.streamEvents))
+	s.mux.HandleFunc("GET /sessions/{id}/ws", s.withSession(s.serveWebSocket))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close closes all sessions
+func (s *Server) Close() {
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = map[string]*session{}
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		session.close()
+	}
+}
+
+func (s *Server) listProfiles(w http.ResponseWriter, _ *http.Request) {
+	profiles := make([]string, 0, len(s.options.Profiles))
+	for name := range s.options.Profiles {
+		profiles = append(profiles, name)
+	}
+	slices.Sort(profiles)
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+func (s *Server) listSessions(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	sessions := make([]sessionResponse, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, newSessionResponse(session))
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(sessions, func(a, b sessionResponse) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (s *Server) createSession(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Profile string `json:"profile"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+	}
+	if request.Profile == "" {
+		request.Profile = s.options.DefaultProfile
+	}
+
+	newOrchestrator, ok := s.options.Profiles[request.Profile]
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown profile %q", request.Profile))
+		return
+	}
+
+	// NOTE: The slot is reserved before the session is created, otherwise
+	// concurrent requests could all pass the limit check
+	s.mu.Lock()
+	if s.options.MaxSessions > 0 && len(s.sessions)+s.pendingSessions >= s.options.MaxSessions {
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, "too many sessions")
+		return
+	}
+	s.pendingSessions++
+	s.mu.Unlock()
+
+	session, err := newSession(request.Profile, newOrchestrator)
+
+	s.mu.Lock()
+	s.pendingSessions--
+	if err == nil {
+		s.sessions[session.id] = session
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Failed to create session: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to create session")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newSessionResponse(session))
+}
+
+// withSession looks up the session of the request's path
+func (s *Server) withSession(handler func(w http.ResponseWriter, r *http.Request, session *session)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		session, ok := s.sessions[r.PathValue("id")]
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "session not found")
+			return
+		}
+
+		handler(w, r, session)
+	}
+}
+
+func (s *Server) getSession(w http.ResponseWriter, _ *http.Request, session *session) {
+	writeJSON(w, http.StatusOK, newSessionResponse(session))
+}
+
+func (s *Server) deleteSession(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	session, ok := s.sessions[r.PathValue("id")]
+	delete(s.sessions, r.PathValue("id"))
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	session.close()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) sendPrompt(w http.ResponseWriter, r *http.Request, session *session) {
+	var request struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if strings.TrimSpace(request.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, "prompt is required")
+		return
+	}
+
+	session.sendPrompt(request.Prompt)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) cancelTurn(w http.ResponseWriter, _ *http.Request, session *session) {
+	session.orchestrator.CancelTurn()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) mute(w http.ResponseWriter, r *http.Request, session *session) {
+	request := struct {
+		Muted bool `json:"muted"`
+	}{Muted: true}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+	}
+
+	session.setMuted(request.Muted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listTurns(w http.ResponseWriter, _ *http.Request, session *session) {
+	turns := []turnResponse{}
+	for _, turn := range session.orchestrator.TurnsSnapshot() {
+		turns = append(turns, newTurnResponse(turn))
+	}
+	writeJSON(w, http.StatusOK, turns)
+}
+
+func (s *Server) listTools(w http.ResponseWriter, _ *http.Request, session *session) {
+	tools := []toolResponse{}
+	for _, tool := range session.orchestrator.Tools() {
+		tools = append(tools, newToolResponse(tool))
+	}
+	writeJSON(w, http.StatusOK, tools)
+}
+
+// sseKeepAlive is how often a comment is sent on an idle event stream, so
+// proxies don't close it
+const sseKeepAlive = 15 * time.Second
+
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, session *session) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	events := session.subscribe()
+	defer session.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Failed to encode event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// command is a message sent by a WebSocket client
+type command struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Muted bool   `json:"muted"`
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request, session *session) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade websocket connection: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	events := session.subscribe()
+	defer session.unsubscribe(events)
+
+	go func() {
+		defer ws.Close()
+		for {
+			var cmd command
+			if err := ws.ReadJSON(&cmd); err != nil {
+				return
+			}
+
+			switch cmd.Type {
+			case "prompt":
+				if strings.TrimSpace(cmd.Text) != "" {
+					session.sendPrompt(cmd.Text)
+				}
+			case "cancel":
+				session.orchestrator.CancelTurn()
+			case "mute":
+				session.setMuted(cmd.Muted)
+			default:
+				log.Printf("Warning: unknown websocket command %q", cmd.Type)
+			}
+		}
+	}()
+
+	for event := range events {
+		if err := ws.WriteJSON(event); err != nil {
+			return
+		}
+	}
+}
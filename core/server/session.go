@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	orchestration "github.com/koscakluka/ema-core/core"
+)
+
+// EventType is the type of an event streamed to the session's subscribers
+type EventType string
+
+const (
+	EventTypeTranscript        EventType = "transcript"
+	EventTypeInterimTranscript EventType = "interim_transcript"
+	EventTypeResponse          EventType = "response"
+	EventTypeResponseEnd       EventType = "response_end"
+	EventTypeCancelled         EventType = "cancelled"
+	// EventTypeSessionClosed is the last event of a deleted session
+	EventTypeSessionClosed EventType = "session_closed"
+)
+
+type Event struct {
+	Type EventType `json:"type"`
+	Text string    `json:"text,omitempty"`
+}
+
+// subscriberBuffer is the number of events kept for a subscriber that can't
+// keep up, later events are dropped
+const subscriberBuffer = 256
+
+// session is a conversation with its own orchestrator
+type session struct {
+	id           string
+	profile      string
+	createdAt    time.Time
+	orchestrator *orchestration.Orchestrator
+	release      func()
+
+	cancel      context.CancelFunc
+	subscribers map[chan Event]struct{}
+	closed      bool
+	mu          sync.Mutex
+}
+
+func newSession(profileName string, newOrchestrator OrchestratorFactory) (*session, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	orchestrator, release, err := newOrchestrator(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s := &session{
+		id:           newSessionID(),
+		profile:      profileName,
+		createdAt:    time.Now(),
+		orchestrator: orchestrator,
+		release:      release,
+		cancel:       cancel,
+		subscribers:  map[chan Event]struct{}{},
+	}
+	if err := orchestrator.Orchestrate(ctx,
+		orchestration.WithTranscriptionCallback(func(transcript string) {
+			s.publish(Event{Type: EventTypeTranscript, Text: transcript})
+		}),
+		orchestration.WithInterimTranscriptionCallback(func(transcript string) {
+			s.publish(Event{Type: EventTypeInterimTranscript, Text: transcript})
+		}),
+		orchestration.WithResponseCallback(func(response string) {
+			s.publish(Event{Type: EventTypeResponse, Text: response})
+		}),
+		orchestration.WithResponseEndCallback(func() {
+			s.publish(Event{Type: EventTypeResponseEnd})
+		}),
+		orchestration.WithCancellationCallback(func() {
+			s.publish(Event{Type: EventTypeCancelled})
+		}),
+	); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSessionID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// subscribe returns a channel the session's events are sent to, it is closed
+// when the session is closed or unsubscribe is called
+func (s *session) subscribe() chan Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make(chan Event, subscriberBuffer)
+	if s.closed {
+		close(events)
+		return events
+	}
+	s.subscribers[events] = struct{}{}
+	return events
+}
+
+func (s *session) unsubscribe(events chan Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscribers[events]; ok {
+		delete(s.subscribers, events)
+		close(events)
+	}
+}
+
+func (s *session) publish(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for events := range s.subscribers {
+		select {
+		case events <- event:
+		default:
+			log.Printf("Warning: dropping %s event of session %s, subscriber is too slow", event.Type, s.id)
+		}
+	}
+}
+
+func (s *session) sendPrompt(prompt string) {
+	go s.orchestrator.SendPrompt(prompt)
+}
+
+func (s *session) setMuted(muted bool) {
+	s.orchestrator.SetSpeaking(!muted)
+}
+
+// close stops the session's orchestration and releases its resources, the
+// subscribers get a final session closed event
+func (s *session) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	s.cancel()
+	if s.release != nil {
+		s.release()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for events := range s.subscribers {
+		select {
+		case events <- Event{Type: EventTypeSessionClosed}:
+		default:
+		}
+		close(events)
+	}
+	s.subscribers = nil
+}
@@ -0,0 +1,181 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/llms"
+)
+
+// NOTE: The interruption classifier is called by the interruption handler,
+// which the orchestrator can't see into, so its requests are recorded by
+// wrapping the classifier's LLM before passing it to the handler
+
+// StructuredClassifier is an interruption classifier LLM with structured
+// output, like the ones interruption handlers with structured prompts use
+type StructuredClassifier interface {
+	PromptWithStructure(ctx context.Context, prompt string, outputSchema any, opts ...llms.StructuredPromptOption) error
+}
+
+// GeneralClassifier is an interruption classifier LLM with plain text output,
+// like the ones interruption handlers with general prompts use
+type GeneralClassifier interface {
+	Prompt(ctx context.Context, prompt string, opts ...llms.GeneralPromptOption) (*llms.Message, error)
+}
+
+// RecordingStructuredClassifier records the requests to the classifier and
+// its responses as classifier events
+type RecordingStructuredClassifier struct {
+	classifier StructuredClassifier
+	recorder   *Recorder
+}
+
+func NewRecordingStructuredClassifier(recorder *Recorder, classifier StructuredClassifier) *RecordingStructuredClassifier {
+	return &RecordingStructuredClassifier{classifier: classifier, recorder: recorder}
+}
+
+func (c *RecordingStructuredClassifier) PromptWithStructure(ctx context.Context, prompt string, outputSchema any, opts ...llms.StructuredPromptOption) error {
+	c.recorder.RecordEvent(EventTypeClassifierRequest, ClassifierRequestData{Prompt: prompt})
+	err := c.classifier.PromptWithStructure(ctx, prompt, outputSchema, opts...)
+
+	data := ClassifierResponseData{}
+	if err != nil {
+		data.Error = err.Error()
+	} else if response, marshalErr := json.Marshal(outputSchema); marshalErr != nil {
+		data.Error = fmt.Sprintf("failed to encode classifier response: %v", marshalErr)
+	} else {
+		data.Response = string(response)
+	}
+	c.recorder.RecordEvent(EventTypeClassifierResponse, data)
+	return err
+}
+
+// RecordingGeneralClassifier records the requests to the classifier and its
+// responses as classifier events
+type RecordingGeneralClassifier struct {
+	classifier GeneralClassifier
+	recorder   *Recorder
+}
+
+func NewRecordingGeneralClassifier(recorder *Recorder, classifier GeneralClassifier) *RecordingGeneralClassifier {
+	return &RecordingGeneralClassifier{classifier: classifier, recorder: recorder}
+}
+
+func (c *RecordingGeneralClassifier) Prompt(ctx context.Context, prompt string, opts ...llms.GeneralPromptOption) (*llms.Message, error) {
+	c.recorder.RecordEvent(EventTypeClassifierRequest, ClassifierRequestData{Prompt: prompt})
+	response, err := c.classifier.Prompt(ctx, prompt, opts...)
+
+	data := ClassifierResponseData{}
+	if err != nil {
+		data.Error = err.Error()
+	} else if response != nil {
+		data.Response = response.Content
+	}
+	c.recorder.RecordEvent(EventTypeClassifierResponse, data)
+	return response, err
+}
+
+// ReplayStructuredClassifier replays the classifier responses recorded with
+// RecordingStructuredClassifier in order, one for each request, after their
+// recorded latency
+type ReplayStructuredClassifier struct {
+	responses *replayClassifierResponses
+}
+
+func NewReplayStructuredClassifier(session *Session) *ReplayStructuredClassifier {
+	return &ReplayStructuredClassifier{responses: newReplayClassifierResponses(session)}
+}
+
+func (c *ReplayStructuredClassifier) PromptWithStructure(ctx context.Context, prompt string, outputSchema any, opts ...llms.StructuredPromptOption) error {
+	response, err := c.responses.take(ctx)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(response), outputSchema); err != nil {
+		return fmt.Errorf("failed to decode recorded classifier response: %w", err)
+	}
+	return nil
+}
+
+// ReplayGeneralClassifier replays the classifier responses recorded with
+// RecordingGeneralClassifier in order, one for each request, after their
+// recorded latency
+type ReplayGeneralClassifier struct {
+	responses *replayClassifierResponses
+}
+
+func NewReplayGeneralClassifier(session *Session) *ReplayGeneralClassifier {
+	return &ReplayGeneralClassifier{responses: newReplayClassifierResponses(session)}
+}
+
+func (c *ReplayGeneralClassifier) Prompt(ctx context.Context, prompt string, opts ...llms.GeneralPromptOption) (*llms.Message, error) {
+	response, err := c.responses.take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &llms.Message{Content: response}, nil
+}
+
+// replayClassifierResponse is a recorded classifier response with the time it
+// took after the request
+type replayClassifierResponse struct {
+	latency time.Duration
+	data    ClassifierResponseData
+}
+
+type replayClassifierResponses struct {
+	responses []replayClassifierResponse
+	next      int
+	mu        sync.Mutex
+}
+
+func newReplayClassifierResponses(session *Session) *replayClassifierResponses {
+	responses := &replayClassifierResponses{}
+	var requestOffset time.Duration
+	for _, event := range session.Events {
+		switch event.Type {
+		case EventTypeClassifierRequest:
+			requestOffset = event.Offset
+		case EventTypeClassifierResponse:
+			var data ClassifierResponseData
+			if err := event.Decode(&data); err != nil {
+				log.Printf("Failed to decode %s event: %v", event.Type, err)
+				data.Error = "failed to decode recorded classifier response"
+			}
+			responses.responses = append(responses.responses, replayClassifierResponse{
+				latency: event.Offset - requestOffset,
+				data:    data,
+			})
+		}
+	}
+	return responses
+}
+
+// take waits for the latency of the next recorded response and returns it,
+// recorded errors are returned as errors
+func (r *replayClassifierResponses) take(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	if r.next >= len(r.responses) {
+		r.mu.Unlock()
+		return "", errors.New("no recorded classifier responses left to replay")
+	}
+	response := r.responses[r.next]
+	r.next++
+	r.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(response.latency):
+	}
+
+	if response.data.Error != "" {
+		return "", errors.New(response.data.Error)
+	}
+	return response.data.Response, nil
+}
@@ -0,0 +1,107 @@
+// Package session records conversations for debugging them after the fact
+// and replays them to reproduce timing bugs.
+//
+// A recorded session is a directory with audio.wav, a stereo WAV with the
+// captured microphone audio on the left and the assistant's audio on the
+// right channel, and events.jsonl, one JSON encoded Event per line. Both are
+// on the same monotonic timeline that starts when the recording starts.
+package session
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/llms"
+	"github.com/koscakluka/ema-core/core/speechtotext"
+)
+
+const (
+	audioFileName  = "audio.wav"
+	eventsFileName = "events.jsonl"
+)
+
+// EventType is the kind of a recorded event
+type EventType string
+
+const (
+	EventTypeSpeechStarted      EventType = "speech_started"
+	EventTypeSpeechEnded        EventType = "speech_ended"
+	EventTypeInterimTranscript  EventType = "interim_transcript"
+	EventTypeTranscript         EventType = "transcript"
+	EventTypeLLMRequest         EventType = "llm_request"
+	EventTypeLLMChunk           EventType = "llm_chunk"
+	EventTypeLLMToolCall        EventType = "llm_tool_call"
+	EventTypeToolCall           EventType = "tool_call"
+	EventTypeClassifierRequest  EventType = "classifier_request"
+	EventTypeClassifierResponse EventType = "classifier_response"
+	EventTypeInterruption       EventType = "interruption"
+	EventTypeTurnStage          EventType = "turn_stage"
+	EventTypeOutputCleared      EventType = "output_cleared"
+)
+
+// Event is a single recorded event
+type Event struct {
+	// Offset is the time since the recording started
+	Offset time.Duration   `json:"offset"`
+	Type   EventType       `json:"type"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// TranscriptData is the data of transcript and interim transcript events
+type TranscriptData struct {
+	Transcript    string                      `json:"transcript"`
+	Transcription *speechtotext.Transcription `json:"transcription,omitempty"`
+}
+
+// LLMRequestData is the data of LLM request events
+type LLMRequestData struct {
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// LLMChunkData is the data of LLM chunk events
+type LLMChunkData struct {
+	Content string `json:"content"`
+}
+
+// LLMToolCallData is the data of LLM tool call events, the tool calls the
+// LLM requested
+type LLMToolCallData struct {
+	ToolCall llms.ToolCall `json:"tool_call"`
+}
+
+// ToolCallData is the data of tool call events, the executed tool calls
+type ToolCallData struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Response  string `json:"response"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ClassifierRequestData is the data of interruption classifier request
+// events
+type ClassifierRequestData struct {
+	Prompt string `json:"prompt"`
+}
+
+// ClassifierResponseData is the data of interruption classifier response
+// events, Response is the classifier's JSON output
+type ClassifierResponseData struct {
+	Response string `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// InterruptionData is the data of interruption events
+type InterruptionData struct {
+	Interruption llms.InterruptionV0 `json:"interruption"`
+}
+
+// TurnStageData is the data of turn stage events
+type TurnStageData struct {
+	Role  llms.TurnRole  `json:"role"`
+	Stage llms.TurnStage `json:"stage"`
+}
+
+// Decode decodes the event's data into v
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Data, v)
+}
@@ -0,0 +1,299 @@
+package session
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/audio/convert"
+	"github.com/koscakluka/ema-core/core/audio/wav"
+)
+
+const (
+	defaultSampleRate = 16000
+	// gapThreshold is how far behind the wall clock captured audio can be
+	// before it is considered a gap in capture, e.g. while recording was
+	// stopped, and realigned
+	gapThreshold = 100 * time.Millisecond
+	// flushDelay is how long audio is kept in memory before it is written,
+	// so late captured chunks still end up in their place
+	flushDelay = 500 * time.Millisecond
+)
+
+// Recorder records the audio of both directions and the events of a
+// conversation into a session directory. It is safe for concurrent use.
+type Recorder struct {
+	sampleRate int
+	origin     time.Time
+
+	audioFile  *os.File
+	audio      *wav.Writer
+	eventsFile *os.File
+	events     *json.Encoder
+
+	toInput  *convert.Converter
+	toOutput *convert.Converter
+	// input is the captured audio on the left channel, output is the
+	// assistant's audio on the right channel
+	input  track
+	output track
+	// written is the number of frames written to the file
+	written int64
+
+	closed bool
+	mu     sync.Mutex
+}
+
+type RecorderOptions struct {
+	// SampleRate is the sample rate of the recorded audio, both directions
+	// are resampled to it
+	SampleRate int
+}
+
+type RecorderOption func(*RecorderOptions)
+
+// WithSampleRate sets the sample rate of the recorded audio, 16kHz by
+// default
+func WithSampleRate(sampleRate int) RecorderOption {
+	return func(o *RecorderOptions) {
+		o.SampleRate = sampleRate
+	}
+}
+
+// NewRecorder creates the session directory and starts the session's
+// timeline
+func NewRecorder(dir string, opts ...RecorderOption) (*Recorder, error) {
+	options := RecorderOptions{SampleRate: defaultSampleRate}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	audioFile, err := os.Create(filepath.Join(dir, audioFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audio file: %w", err)
+	}
+	audioWriter, err := wav.NewWriter(audioFile, audio.EncodingInfo{
+		SampleRate: options.SampleRate,
+		Encoding:   audio.EncodingLinear16,
+		Channels:   2,
+	})
+	if err != nil {
+		audioFile.Close()
+		return nil, err
+	}
+	eventsFile, err := os.Create(filepath.Join(dir, eventsFileName))
+	if err != nil {
+		audioFile.Close()
+		return nil, fmt.Errorf("failed to create events file: %w", err)
+	}
+
+	return &Recorder{
+		sampleRate: options.SampleRate,
+		origin:     time.Now(),
+		audioFile:  audioFile,
+		audio:      audioWriter,
+		eventsFile: eventsFile,
+		events:     json.NewEncoder(eventsFile),
+	}, nil
+}
+
+// Start sets the encodings of the captured and the assistant's audio, audio
+// recorded before it is dropped
+func (r *Recorder) Start(input, output audio.EncodingInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	recorded := audio.EncodingInfo{SampleRate: r.sampleRate, Encoding: audio.EncodingLinear16, Channels: 1}
+	var err error
+	if r.toInput, err = convert.NewConverter(input, recorded); err != nil {
+		return fmt.Errorf("failed to convert input audio: %w", err)
+	}
+	if r.toOutput, err = convert.NewConverter(output, recorded); err != nil {
+		return fmt.Errorf("failed to convert output audio: %w", err)
+	}
+	return nil
+}
+
+// RecordInputAudio records audio captured by the microphone
+func (r *Recorder) RecordInputAudio(chunk []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.toInput == nil {
+		return
+	}
+
+	samples := toSamples(r.toInput.Convert(chunk))
+	now := r.frameAt(time.Now())
+	at := r.input.end()
+	if behind := now - int64(len(samples)) - at; behind > r.frames(gapThreshold) {
+		at += behind
+	}
+	r.input.add(at, samples)
+	r.flush(now - r.frames(flushDelay))
+}
+
+// RecordOutputAudio records the assistant's audio, it is placed after the
+// audio recorded before it since the output plays it in order
+func (r *Recorder) RecordOutputAudio(chunk []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.toOutput == nil {
+		return
+	}
+
+	now := r.frameAt(time.Now())
+	r.output.add(now, toSamples(r.toOutput.Convert(chunk)))
+	r.flush(now - r.frames(flushDelay))
+}
+
+// ClearOutputAudio drops the assistant's audio that wasn't played yet, it
+// should be called whenever the output's buffer is cleared
+func (r *Recorder) ClearOutputAudio() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.output.truncate(r.frameAt(time.Now()))
+	if r.toOutput != nil {
+		r.toOutput.Reset()
+	}
+}
+
+// RecordEvent records the event with its data encoded as JSON, nil data is
+// left out
+func (r *Recorder) RecordEvent(eventType EventType, data any) {
+	event := Event{Offset: time.Since(r.origin), Type: eventType}
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("Failed to encode %s event: %v", eventType, err)
+			return
+		}
+		event.Data = encoded
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	if err := r.events.Encode(event); err != nil {
+		log.Printf("Failed to record %s event: %v", eventType, err)
+	}
+}
+
+// Close writes the remaining audio and closes the session's files
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	r.flush(max(r.input.end(), r.output.end()))
+	var errs []error
+	if err := r.audio.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, file := range []*os.File{r.audioFile, r.eventsFile} {
+		if err := file.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close session: %v", errs)
+	}
+	return nil
+}
+
+// flush writes the frames up to the index to the file, r.mu has to be held
+// when calling it
+func (r *Recorder) flush(to int64) {
+	if to <= r.written {
+		return
+	}
+
+	frames := make([]byte, 0, (to-r.written)*4)
+	for i := r.written; i < to; i++ {
+		frames = binary.LittleEndian.AppendUint16(frames, uint16(r.input.at(i)))
+		frames = binary.LittleEndian.AppendUint16(frames, uint16(r.output.at(i)))
+	}
+	if _, err := r.audio.Write(frames); err != nil {
+		log.Printf("Failed to write session audio: %v", err)
+	}
+	r.written = to
+	r.input.trim(to)
+	r.output.trim(to)
+}
+
+func (r *Recorder) frameAt(t time.Time) int64 {
+	return r.frames(t.Sub(r.origin))
+}
+
+func (r *Recorder) frames(duration time.Duration) int64 {
+	return int64(duration.Seconds() * float64(r.sampleRate))
+}
+
+// track is the audio of a single channel, samples[0] is at frame start
+type track struct {
+	start   int64
+	samples []int16
+}
+
+func (t *track) end() int64 {
+	return t.start + int64(len(t.samples))
+}
+
+// add places the samples at the frame, or right after the track's end if it
+// is past the frame, filling the gap before it with silence
+func (t *track) add(at int64, samples []int16) {
+	if len(t.samples) == 0 {
+		t.start = max(at, t.start)
+	}
+	for end := t.end(); end < at; end++ {
+		t.samples = append(t.samples, 0)
+	}
+	t.samples = append(t.samples, samples...)
+}
+
+func (t *track) at(index int64) int16 {
+	i := index - t.start
+	if i < 0 || i >= int64(len(t.samples)) {
+		return 0
+	}
+	return t.samples[i]
+}
+
+// truncate drops the samples from the index on
+func (t *track) truncate(index int64) {
+	if keep := index - t.start; keep < int64(len(t.samples)) {
+		t.samples = t.samples[:max(keep, 0)]
+	}
+}
+
+// trim drops the samples before the index
+func (t *track) trim(index int64) {
+	drop := min(max(index-t.start, 0), int64(len(t.samples)))
+	t.samples = t.samples[drop:]
+	t.start += drop
+	if len(t.samples) == 0 {
+		t.start = max(t.start, index)
+	}
+}
+
+func toSamples(chunk []byte) []int16 {
+	samples := make([]int16, len(chunk)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(chunk[i*2:]))
+	}
+	return samples
+}
@@ -0,0 +1,344 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/audio/wav"
+	"github.com/koscakluka/ema-core/core/llms"
+	"github.com/koscakluka/ema-core/core/speechtotext"
+)
+
+// replayChunkDuration is the duration of the chunks the recorded input audio
+// is replayed in
+const replayChunkDuration = 20 * time.Millisecond
+
+// Session is a recorded session loaded for replaying
+type Session struct {
+	Events []Event
+	// InputAudio is the captured audio, linear16 mono
+	InputAudio   []byte
+	EncodingInfo audio.EncodingInfo
+}
+
+// Load loads the session recorded into the directory
+func Load(dir string) (*Session, error) {
+	session := &Session{}
+
+	eventsFile, err := os.Open(filepath.Join(dir, eventsFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open events file: %w", err)
+	}
+	defer eventsFile.Close()
+	scanner := bufio.NewScanner(eventsFile)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode event: %w", err)
+		}
+		session.Events = append(session.Events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read events file: %w", err)
+	}
+
+	audioFile, err := os.Open(filepath.Join(dir, audioFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer audioFile.Close()
+	header, err := wav.ReadHeader(audioFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio file: %w", err)
+	}
+	if header.EncodingInfo.Encoding != audio.EncodingLinear16 || header.EncodingInfo.ChannelCount() != 2 {
+		return nil, errors.New("session audio is not stereo linear16")
+	}
+	frames, err := io.ReadAll(audioFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio file: %w", err)
+	}
+	session.InputAudio = make([]byte, 0, len(frames)/2)
+	for i := 0; i+4 <= len(frames); i += 4 {
+		session.InputAudio = append(session.InputAudio, frames[i:i+2]...)
+	}
+	session.EncodingInfo = audio.EncodingInfo{
+		SampleRate: header.EncodingInfo.SampleRate,
+		Encoding:   audio.EncodingLinear16,
+		Channels:   1,
+	}
+
+	return session, nil
+}
+
+// ReplayInput streams the recorded microphone audio in real time, it
+// implements both AudioInput and AudioInputFine
+type ReplayInput struct {
+	session *Session
+
+	position int
+	cancel   context.CancelFunc
+	mu       sync.Mutex
+}
+
+func NewReplayInput(session *Session) *ReplayInput {
+	return &ReplayInput{session: session}
+}
+
+func (i *ReplayInput) EncodingInfo() audio.EncodingInfo {
+	return i.session.EncodingInfo
+}
+
+// Stream streams the recorded audio until it ends or the context is
+// cancelled
+func (i *ReplayInput) Stream(ctx context.Context, onAudio func(audio []byte)) error {
+	i.stream(ctx, onAudio)
+	return nil
+}
+
+// StartCapture streams the recorded audio in the background, continuing
+// where the previous capture stopped
+func (i *ReplayInput) StartCapture(ctx context.Context, onAudio func(audio []byte)) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cancel != nil {
+		return nil
+	}
+
+	ctx, i.cancel = context.WithCancel(ctx)
+	go i.stream(ctx, onAudio)
+	return nil
+}
+
+func (i *ReplayInput) StopCapture() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cancel != nil {
+		i.cancel()
+		i.cancel = nil
+	}
+	return nil
+}
+
+func (i *ReplayInput) Close() {
+	_ = i.StopCapture()
+}
+
+func (i *ReplayInput) stream(ctx context.Context, onAudio func(audio []byte)) {
+	chunkSize := int(replayChunkDuration.Seconds()*float64(i.session.EncodingInfo.SampleRate)) * 2
+	ticker := time.NewTicker(replayChunkDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		i.mu.Lock()
+		if i.position >= len(i.session.InputAudio) {
+			i.mu.Unlock()
+			return
+		}
+		chunk := i.session.InputAudio[i.position:min(i.position+chunkSize, len(i.session.InputAudio))]
+		i.position += len(chunk)
+		i.mu.Unlock()
+
+		onAudio(chunk)
+	}
+}
+
+// ReplaySpeechToText replays the recorded speech events and transcripts at
+// their recorded offsets from the start of transcription, the audio sent to
+// it is ignored
+type ReplaySpeechToText struct {
+	session *Session
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+}
+
+func NewReplaySpeechToText(session *Session) *ReplaySpeechToText {
+	return &ReplaySpeechToText{session: session}
+}
+
+func (s *ReplaySpeechToText) Transcribe(ctx context.Context, opts ...speechtotext.TranscriptionOption) error {
+	options := speechtotext.TranscriptionOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	go s.replay(ctx, options)
+	return nil
+}
+
+func (s *ReplaySpeechToText) SendAudio(audio []byte) error {
+	return nil
+}
+
+func (s *ReplaySpeechToText) StopStream() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	return nil
+}
+
+func (s *ReplaySpeechToText) replay(ctx context.Context, options speechtotext.TranscriptionOptions) {
+	start := time.Now()
+	for _, event := range s.session.Events {
+		switch event.Type {
+		case EventTypeSpeechStarted, EventTypeSpeechEnded, EventTypeInterimTranscript, EventTypeTranscript:
+		default:
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(start.Add(event.Offset))):
+		}
+
+		var data TranscriptData
+		if len(event.Data) > 0 {
+			if err := event.Decode(&data); err != nil {
+				log.Printf("Failed to decode %s event: %v", event.Type, err)
+				continue
+			}
+		}
+
+		switch event.Type {
+		case EventTypeSpeechStarted:
+			if options.SpeechStartedCallback != nil {
+				options.SpeechStartedCallback()
+			}
+		case EventTypeSpeechEnded:
+			if options.SpeechEndedCallback != nil {
+				options.SpeechEndedCallback()
+			}
+		case EventTypeInterimTranscript:
+			if options.InterimTranscriptionCallback != nil {
+				options.InterimTranscriptionCallback(data.Transcript)
+			}
+			if options.InterimTranscriptionResultCallback != nil && data.Transcription != nil {
+				options.InterimTranscriptionResultCallback(*data.Transcription)
+			}
+		case EventTypeTranscript:
+			if options.TranscriptionResultCallback != nil && data.Transcription != nil {
+				options.TranscriptionResultCallback(*data.Transcription)
+			}
+			if options.TranscriptionCallback != nil {
+				options.TranscriptionCallback(data.Transcript)
+			}
+		}
+	}
+}
+
+// ReplayLLM replays the recorded LLM responses in order, one for each
+// request, with the recorded timing of their chunks
+type ReplayLLM struct {
+	responses [][]Event
+	next      int
+	mu        sync.Mutex
+}
+
+func NewReplayLLM(session *Session) *ReplayLLM {
+	llm := &ReplayLLM{}
+	var requestOffset time.Duration
+	for _, event := range session.Events {
+		switch event.Type {
+		case EventTypeLLMRequest:
+			llm.responses = append(llm.responses, nil)
+			requestOffset = event.Offset
+		case EventTypeLLMChunk, EventTypeLLMToolCall:
+			if len(llm.responses) == 0 {
+				continue
+			}
+			event.Offset -= requestOffset
+			llm.responses[len(llm.responses)-1] = append(llm.responses[len(llm.responses)-1], event)
+		}
+	}
+	return llm
+}
+
+func (l *ReplayLLM) PromptWithStream(ctx context.Context, prompt *string, opts ...llms.StreamingPromptOption) llms.Stream {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.next >= len(l.responses) {
+		log.Println("Warning: no recorded LLM responses left to replay")
+		return replayStream{ctx: ctx}
+	}
+	response := l.responses[l.next]
+	l.next++
+	return replayStream{ctx: ctx, events: response}
+}
+
+type replayStream struct {
+	ctx    context.Context
+	events []Event
+}
+
+func (s replayStream) Chunks(yield func(llms.StreamChunk, error) bool) {
+	start := time.Now()
+	for _, event := range s.events {
+		select {
+		case <-s.ctx.Done():
+			yield(nil, s.ctx.Err())
+			return
+		case <-time.After(time.Until(start.Add(event.Offset))):
+		}
+
+		var chunk llms.StreamChunk
+		switch event.Type {
+		case EventTypeLLMChunk:
+			var data LLMChunkData
+			if err := event.Decode(&data); err != nil {
+				log.Printf("Failed to decode %s event: %v", event.Type, err)
+				continue
+			}
+			chunk = replayContentChunk{content: data.Content}
+		case EventTypeLLMToolCall:
+			var data LLMToolCallData
+			if err := event.Decode(&data); err != nil {
+				log.Printf("Failed to decode %s event: %v", event.Type, err)
+				continue
+			}
+			chunk = replayToolCallChunk{toolCall: data.ToolCall}
+		}
+		if !yield(chunk, nil) {
+			return
+		}
+	}
+}
+
+type replayContentChunk struct{ content string }
+
+func (c replayContentChunk) FinishReason() *string { return nil }
+func (c replayContentChunk) Content() string       { return c.content }
+
+type replayToolCallChunk struct{ toolCall llms.ToolCall }
+
+func (c replayToolCallChunk) FinishReason() *string   { return nil }
+func (c replayToolCallChunk) ToolCall() llms.ToolCall { return c.toolCall }
@@ -0,0 +1,70 @@
+package orchestration
+
+import (
+	"log"
+
+	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/llms"
+	"github.com/koscakluka/ema-core/core/session"
+)
+
+// SessionRecorder records the audio and events of a conversation for
+// debugging it after the fact, session.Recorder implements it
+type SessionRecorder interface {
+	// Start is called with the encodings of the captured and the assistant's
+	// audio once the orchestration starts
+	Start(input, output audio.EncodingInfo) error
+	RecordInputAudio(audio []byte)
+	RecordOutputAudio(audio []byte)
+	ClearOutputAudio()
+	RecordEvent(eventType session.EventType, data any)
+}
+
+// WithSessionRecorder records the captured audio, the assistant's audio,
+// transcripts, LLM chunks, tool calls, interruptions and turn stage changes.
+// The interruption classifier's requests are recorded only if its LLM is
+// wrapped with session.NewRecordingStructuredClassifier or
+// session.NewRecordingGeneralClassifier.
+func WithSessionRecorder(recorder SessionRecorder) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.sessionRecorder = recorder
+	}
+}
+
+func (o *Orchestrator) startSessionRecording() {
+	if o.sessionRecorder == nil {
+		return
+	}
+
+	input := defaultEncodingInfo
+	if o.audioInput != nil {
+		input = o.audioInput.EncodingInfo()
+	}
+	output := defaultEncodingInfo
+	if o.audioOutput != nil {
+		output = o.audioFormats.textToSpeech
+	}
+	if err := o.sessionRecorder.Start(input, output); err != nil {
+		log.Printf("Failed to start session recording: %v", err)
+	}
+
+	o.turns.onStageChanged = func(turn llms.Turn) {
+		o.recordEvent(session.EventTypeTurnStage, session.TurnStageData{Role: turn.Role, Stage: turn.Stage})
+	}
+}
+
+func (o *Orchestrator) recordEvent(eventType session.EventType, data any) {
+	if o.sessionRecorder != nil {
+		o.sessionRecorder.RecordEvent(eventType, data)
+	}
+}
+
+func (o *Orchestrator) recordInterruption(id int64) {
+	if o.sessionRecorder == nil {
+		return
+	}
+
+	if interruption := o.turns.findInterruption(id); interruption != nil {
+		o.recordEvent(session.EventTypeInterruption, session.InterruptionData{Interruption: *interruption})
+	}
+}
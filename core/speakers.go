@@ -0,0 +1,73 @@
+package orchestration
+
+import (
+	"sync"
+
+	"github.com/koscakluka/ema-core/core/speechtotext"
+)
+
+// speakerFilter keeps track of the primary speaker when the orchestrator
+// should respond only to them
+type speakerFilter struct {
+	enabled      bool
+	primary      string
+	reconnecting bool
+	mu           sync.Mutex
+}
+
+// accepts reports whether the speaker's speech should be responded to, the
+// first known speaker is enrolled if there is no primary speaker yet.
+// Unknown speakers are rejected since they can't be told apart from the
+// primary speaker.
+func (f *speakerFilter) accepts(speaker string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.enabled {
+		return true
+	}
+	if speaker == "" {
+		return false
+	}
+	if f.primary == "" {
+		f.primary = speaker
+	}
+	return f.primary == speaker
+}
+
+// updateConnectionState forgets the primary speaker once the speech to text
+// client reconnects, since providers number the speakers per connection and
+// the next speaker who is heard is enrolled instead
+func (f *speakerFilter) updateConnectionState(state speechtotext.ConnectionState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch state {
+	case speechtotext.ConnectionStateReconnecting:
+		f.reconnecting = true
+	case speechtotext.ConnectionStateConnected:
+		if f.reconnecting {
+			f.primary = ""
+		}
+		f.reconnecting = false
+	}
+}
+
+// EnrollPrimarySpeaker sets the speaker the orchestrator responds to when it
+// was created with WithPrimarySpeakerOnly, an empty speaker enrolls the next
+// speaker who is heard
+func (o *Orchestrator) EnrollPrimarySpeaker(speaker string) {
+	o.speakerFilter.mu.Lock()
+	defer o.speakerFilter.mu.Unlock()
+
+	o.speakerFilter.primary = speaker
+}
+
+// PrimarySpeaker returns the ID of the enrolled primary speaker, empty if
+// none is enrolled yet
+func (o *Orchestrator) PrimarySpeaker() string {
+	o.speakerFilter.mu.Lock()
+	defer o.speakerFilter.mu.Unlock()
+
+	return o.speakerFilter.primary
+}
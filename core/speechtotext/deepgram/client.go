@@ -6,20 +6,55 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/speechtotext"
 )
 
 type TranscriptionClient struct {
+	apiKey string
+
 	lastMsgTs time.Time
 
-	accumulatedTranscript string
-	unendedSegment        bool
+	accumulatedTranscript    string
+	accumulatedTranscription speechtotext.Transcription
+	unendedSegment           bool
 
 	conn   *websocket.Conn
 	connMu sync.Mutex
+
+	connOptions      connectionOptions
+	reconnecting     bool
+	closed           bool
+	pendingAudio     [][]byte
+	pendingAudioSize int
+}
+
+func NewClient(ctx context.Context, opts ...ClientOption) *TranscriptionClient {
+	client := &TranscriptionClient{}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
+}
+
+type ClientOption func(*TranscriptionClient)
+
+// WithAPIKey sets the API key, by default it is read from DEEPGRAM_API_KEY
+func WithAPIKey(apiKey string) ClientOption {
+	return func(c *TranscriptionClient) {
+		c.apiKey = apiKey
+	}
 }
 
-func NewClient(ctx context.Context) *TranscriptionClient {
-	return &TranscriptionClient{}
+// SupportsEncoding reports whether Deepgram can transcribe raw audio in the
+// encoding
+func (s *TranscriptionClient) SupportsEncoding(encodingInfo audio.EncodingInfo) bool {
+	switch encodingInfo.Encoding {
+	case audio.EncodingLinear16, audio.EncodingMulaw, audio.EncodingAlaw:
+		return encodingInfo.ChannelCount() == 1 && encodingInfo.SampleRate > 0
+	default:
+		return false
+	}
 }
 
 func (s *TranscriptionClient) Close() error {
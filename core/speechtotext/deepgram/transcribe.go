@@ -1,6 +1,7 @@
 package deepgram
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -15,13 +16,26 @@ import (
 	api "github.com/deepgram/deepgram-go-sdk/pkg/api/listen/v1/websocket/interfaces"
 	"github.com/gorilla/websocket"
 	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/audio/convert"
 	"github.com/koscakluka/ema-core/core/speechtotext"
+	"github.com/koscakluka/ema-core/internal/reconnect"
 	"github.com/koscakluka/ema-core/internal/utils"
 )
 
 const (
-	defaultEncoding   = "linear16"
-	defaultSampleRate = 48000
+	defaultEncoding     = audio.EncodingLinear16
+	defaultSampleRate   = 48000
+	defaultModel        = "nova-3"
+	defaultLanguage     = "en-US"
+	defaultEndpointing  = 300 * time.Millisecond
+	defaultUtteranceEnd = 1000 * time.Millisecond
+
+	// maxPendingAudio is the amount of audio kept while reconnecting, older
+	// audio is dropped
+	maxPendingAudio = 10 * time.Second
+	// callbackQueueSize is the number of callbacks that can wait for the
+	// previous ones before reading the messages blocks
+	callbackQueueSize = 64
 )
 
 func (s *TranscriptionClient) Transcribe(ctx context.Context, opts ...speechtotext.TranscriptionOption) error {
@@ -35,55 +49,144 @@ func (s *TranscriptionClient) Transcribe(ctx context.Context, opts ...speechtote
 		opt(options)
 	}
 
-	conn, err := connectWebsocket(connectionOptions{
-		sampleRate: options.EncodingInfo.SampleRate,
-		encoding:   options.EncodingInfo.Encoding,
+	connOptions := connectionOptions{
+		apiKey:       s.apiKey,
+		encodingInfo: options.EncodingInfo,
 
 		detectSpeechStart: options.SpeechStartedCallback != nil,
 		enhanceSpeechEndingDetection: options.TranscriptionCallback != nil ||
+			options.TranscriptionResultCallback != nil ||
 			options.SpeechEndedCallback != nil,
-		interimResults: options.InterimTranscriptionCallback != nil,
-	})
+		interimResults: options.InterimTranscriptionCallback != nil ||
+			options.InterimTranscriptionResultCallback != nil,
+
+		language:        options.Language,
+		model:           options.Model,
+		endpointing:     options.Endpointing,
+		utteranceEnd:    options.UtteranceEnd,
+		keywords:        options.Keywords,
+		profanityFilter: options.ProfanityFilter,
+		punctuation:     options.Punctuation,
+		numerals:        options.Numerals,
+		alternatives:    options.Alternatives,
+		diarize:         options.Diarization,
+	}
+	conn, err := connectWebsocket(connOptions)
 	if err != nil {
 		return fmt.Errorf("failed to open websocket: %w", err)
 	}
 
+	s.connMu.Lock()
 	s.conn = conn
+	s.connOptions = connOptions
+	s.closed = false
+	s.connMu.Unlock()
+	notifyConnectionState(*options, speechtotext.ConnectionStateConnected)
+
 	go s.readAndProcessMessages(ctx, conn, *options)
 
 	return nil
 }
 
 type connectionOptions struct {
-	sampleRate int
-	encoding   string
+	apiKey       string
+	encodingInfo audio.EncodingInfo
 
 	detectSpeechStart            bool
 	enhanceSpeechEndingDetection bool
 	interimResults               bool
+
+	language        string
+	model           string
+	endpointing     *time.Duration
+	utteranceEnd    time.Duration
+	keywords        []speechtotext.Keyword
+	profanityFilter bool
+	punctuation     *bool
+	numerals        bool
+	alternatives    int
+	diarize         bool
 }
 
 func connectWebsocket(options connectionOptions) (*websocket.Conn, error) {
-	apiKey, ok := os.LookupEnv("DEEPGRAM_API_KEY")
-	if !ok {
-		return nil, fmt.Errorf("deepgram api key not found")
+	apiKey := options.apiKey
+	if apiKey == "" {
+		var ok bool
+		if apiKey, ok = os.LookupEnv("DEEPGRAM_API_KEY"); !ok {
+			return nil, fmt.Errorf("deepgram api key not found")
+		}
 	}
 
 	listenUrl, _ := url.Parse("wss://api.deepgram.com/v1/listen")
 	queryParams := listenUrl.Query()
-	queryParams.Set("encoding", options.encoding)
-	queryParams.Set("sample_rate", strconv.Itoa(options.sampleRate))
-	queryParams.Set("channels", "1")
-	queryParams.Set("model", "nova-3")
-	queryParams.Set("language", "en-US")
-	queryParams.Set("smart_format", "true")
+	queryParams.Set("encoding", string(options.encodingInfo.Encoding))
+	queryParams.Set("sample_rate", strconv.Itoa(options.encodingInfo.SampleRate))
+	queryParams.Set("channels", strconv.Itoa(options.encodingInfo.ChannelCount()))
+
+	model := options.model
+	if model == "" {
+		model = defaultModel
+	}
+	queryParams.Set("model", model)
+
+	language := options.language
+	if language == "" {
+		language = defaultLanguage
+	}
+	queryParams.Set("language", language)
+
+	// NOTE: Smart formatting includes punctuation so it is only used if
+	// punctuation is not explicitly disabled
+	if options.punctuation == nil || *options.punctuation {
+		queryParams.Set("smart_format", "true")
+	}
+	if options.punctuation != nil {
+		queryParams.Set("punctuate", strconv.FormatBool(*options.punctuation))
+	}
+	if options.numerals {
+		queryParams.Set("numerals", "true")
+	}
+	if options.alternatives > 1 {
+		queryParams.Set("alternatives", strconv.Itoa(options.alternatives))
+	}
+	if options.diarize {
+		queryParams.Set("diarize", "true")
+	}
+	if options.profanityFilter {
+		queryParams.Set("profanity_filter", "true")
+	}
+	for _, keyword := range options.keywords {
+		// NOTE: Nova 3 only supports keyterm prompting without intensities,
+		// older models use keywords with optional intensity
+		if strings.HasPrefix(model, "nova-3") {
+			queryParams.Add("keyterm", keyword.Term)
+		} else if keyword.Boost != 0 {
+			queryParams.Add("keywords", keyword.Term+":"+strconv.FormatFloat(keyword.Boost, 'f', -1, 64))
+		} else {
+			queryParams.Add("keywords", keyword.Term)
+		}
+	}
+
 	if options.enhanceSpeechEndingDetection {
-		queryParams.Set("utterance_end_ms", "1000")
+		utteranceEnd := options.utteranceEnd
+		if utteranceEnd == 0 {
+			utteranceEnd = defaultUtteranceEnd
+		}
+		queryParams.Set("utterance_end_ms", strconv.FormatInt(utteranceEnd.Milliseconds(), 10))
 		queryParams.Set("interim_results", "true")
 	} else if options.interimResults {
 		queryParams.Set("interim_results", "true")
 	}
-	queryParams.Set("endpointing", "300")
+
+	endpointing := defaultEndpointing
+	if options.endpointing != nil {
+		endpointing = *options.endpointing
+	}
+	if endpointing > 0 {
+		queryParams.Set("endpointing", strconv.FormatInt(endpointing.Milliseconds(), 10))
+	} else {
+		queryParams.Set("endpointing", "false")
+	}
 	if options.detectSpeechStart || options.enhanceSpeechEndingDetection {
 		queryParams.Set("vad_events", "true")
 	}
@@ -102,6 +205,9 @@ func (s *TranscriptionClient) sendKeepAlive() {
 	s.connMu.Lock()
 	defer s.connMu.Unlock()
 
+	if s.conn == nil {
+		return
+	}
 	if err := s.conn.WriteJSON(
 		struct {
 			Type string `json:"type"`
@@ -112,21 +218,64 @@ func (s *TranscriptionClient) sendKeepAlive() {
 	}
 }
 
+// Finalize makes Deepgram transcribe the audio sent so far right away, e.g.
+// when the user stopped recording, instead of waiting for the end of speech
+func (s *TranscriptionClient) Finalize() error {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	if err := s.conn.WriteJSON(struct {
+		Type string `json:"type"`
+	}{Type: string(api.TypeFinalizeResponse)}); err != nil {
+		return fmt.Errorf("failed to finalize deepgram transcription: %w", err)
+	}
+	return nil
+}
+
 func (s *TranscriptionClient) SendAudio(audio []byte) error {
 	s.connMu.Lock()
 	defer s.connMu.Unlock()
 
 	s.lastMsgTs = time.Now()
+	if s.conn == nil {
+		if s.reconnecting {
+			s.bufferAudio(audio)
+			return nil
+		}
+		return fmt.Errorf("connection closed")
+	}
 	if err := s.conn.WriteMessage(websocket.BinaryMessage, audio); err != nil {
 		return fmt.Errorf("failed to write to deepgram client: %w", err)
 	}
 	return nil
 }
 
+// bufferAudio keeps the audio to be sent once the connection is
+// reestablished, connMu has to be held when calling it
+func (s *TranscriptionClient) bufferAudio(audio []byte) {
+	encodingInfo := s.connOptions.encodingInfo
+	maxSize := int(maxPendingAudio.Seconds()) * encodingInfo.SampleRate * encodingInfo.BytesPerFrame()
+	s.pendingAudio = append(s.pendingAudio, append([]byte{}, audio...))
+	s.pendingAudioSize += len(audio)
+	for s.pendingAudioSize > maxSize && len(s.pendingAudio) > 0 {
+		s.pendingAudioSize -= len(s.pendingAudio[0])
+		s.pendingAudio = s.pendingAudio[1:]
+	}
+}
+
 func (s *TranscriptionClient) sendSilence(audio []byte) error {
 	s.connMu.Lock()
 	defer s.connMu.Unlock()
 
+	if s.conn == nil {
+		// NOTE: Silence is not needed while reconnecting since the new
+		// connection starts without a timeout
+		return nil
+	}
+
 	if err := s.conn.WriteMessage(websocket.BinaryMessage, audio); err != nil {
 		return fmt.Errorf("failed to write to deepgram client: %w", err)
 	}
@@ -137,6 +286,10 @@ func (s *TranscriptionClient) StopStream() error {
 	s.connMu.Lock()
 	defer s.connMu.Unlock()
 
+	s.closed = true
+	s.reconnecting = false
+	s.pendingAudio = nil
+	s.pendingAudioSize = 0
 	if s.conn != nil {
 		if err := s.conn.WriteJSON(struct {
 			Type string `json:"type"`
@@ -151,26 +304,103 @@ func (s *TranscriptionClient) readAndProcessMessages(ctx context.Context, conn *
 	silenceCtx, silenceCancel := context.WithCancel(ctx)
 	defer silenceCancel()
 
-	go s.generateSilence(silenceCtx)
+	go s.generateSilence(silenceCtx, options.EncodingInfo)
+
+	// NOTE: Messages are processed one at a time here so the segments are
+	// accumulated in order, while the callbacks are called in the same order
+	// on their own goroutine since handling a transcript can block, e.g.
+	// while an interruption is being classified
+	callbacks := make(chan func(), callbackQueueSize)
+	defer close(callbacks)
+	go func() {
+		for callback := range callbacks {
+			callback()
+		}
+	}()
 
 	for {
 		msgType, msg, err := conn.ReadMessage()
 		if err != nil {
-			if err.Error() != "websocket: close 1000 (normal)" {
-				log.Println("Failed to read deepgram websocket message", "error")
-			}
+			conn.Close()
 
+			s.connMu.Lock()
 			s.conn = nil
-			conn.Close()
-			return
+			s.reconnecting = !s.closed && !websocket.IsCloseError(err, websocket.CloseNormalClosure)
+			reconnect := s.reconnecting
+			s.connMu.Unlock()
+			if !reconnect {
+				return
+			}
+
+			log.Printf("Failed to read deepgram websocket message: %v", err)
+			if conn, err = s.reconnect(ctx, options); err != nil {
+				log.Printf("Failed to reconnect to deepgram: %v", err)
+				return
+			}
+			continue
 		}
 		if msgType != websocket.BinaryMessage {
-			go s.processMessage(ctx, msg, options)
+			s.processMessage(ctx, msg, options, callbacks)
+		}
+	}
+}
+
+// reconnect tries to reopen the connection with exponential backoff and
+// replays the audio sent while it was down
+func (s *TranscriptionClient) reconnect(ctx context.Context, options speechtotext.TranscriptionOptions) (*websocket.Conn, error) {
+	notifyConnectionState(options, speechtotext.ConnectionStateReconnecting)
+
+	conn, err := reconnect.Retry(ctx, "deepgram", func() (*websocket.Conn, error) {
+		return connectWebsocket(s.connOptions)
+	})
+	if err != nil {
+		s.stopReconnecting()
+		if ctx.Err() == nil {
+			notifyConnectionState(options, speechtotext.ConnectionStateFailed)
 		}
+		return nil, err
 	}
+
+	s.connMu.Lock()
+	if s.closed {
+		s.connMu.Unlock()
+		conn.Close()
+		return nil, fmt.Errorf("stream closed while reconnecting")
+	}
+	for _, audio := range s.pendingAudio {
+		if err := conn.WriteMessage(websocket.BinaryMessage, audio); err != nil {
+			// NOTE: The failed write will also fail the next read which
+			// will start reconnecting again
+			log.Printf("Failed to replay audio to deepgram: %v", err)
+			break
+		}
+	}
+	s.pendingAudio = nil
+	s.pendingAudioSize = 0
+	s.conn = conn
+	s.reconnecting = false
+	s.connMu.Unlock()
+
+	notifyConnectionState(options, speechtotext.ConnectionStateConnected)
+	return conn, nil
+}
+
+func (s *TranscriptionClient) stopReconnecting() {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	s.reconnecting = false
+	s.pendingAudio = nil
+	s.pendingAudioSize = 0
 }
 
-func (s *TranscriptionClient) processMessage(_ context.Context, msg []byte, options speechtotext.TranscriptionOptions) {
+func notifyConnectionState(options speechtotext.TranscriptionOptions, state speechtotext.ConnectionState) {
+	if options.ConnectionStateCallback != nil {
+		options.ConnectionStateCallback(state)
+	}
+}
+
+func (s *TranscriptionClient) processMessage(_ context.Context, msg []byte, options speechtotext.TranscriptionOptions, callbacks chan<- func()) {
 	var parsedMsg struct {
 		Type string `json:"type"`
 	}
@@ -194,13 +424,16 @@ func (s *TranscriptionClient) processMessage(_ context.Context, msg []byte, opti
 					if options.TranscriptionCallback != nil {
 						s.accumulatedTranscript += " " + transcript
 					}
+					if options.TranscriptionResultCallback != nil {
+						s.accumulatedTranscription.Append(toTranscription(msgResp))
+					}
 					if options.PartialTranscriptionCallback != nil {
-						options.PartialTranscriptionCallback(transcript)
+						callbacks <- func() { options.PartialTranscriptionCallback(transcript) }
 					}
 				}
 			}
-			if msgResp.SpeechFinal {
-				s.onSpeechEnded(options)
+			if msgResp.SpeechFinal || msgResp.FromFinalize {
+				s.onSpeechEnded(options, callbacks)
 			}
 		}
 		if !msgResp.IsFinal &&
@@ -209,13 +442,21 @@ func (s *TranscriptionClient) processMessage(_ context.Context, msg []byte, opti
 				transcript := strings.TrimSpace(msgResp.Channel.Alternatives[0].Transcript)
 				if len(transcript) > 0 {
 					if options.PartialInterimTranscriptionCallback != nil {
-						options.PartialInterimTranscriptionCallback(transcript)
+						callbacks <- func() { options.PartialInterimTranscriptionCallback(transcript) }
 					} else if options.InterimTranscriptionCallback != nil {
-						options.InterimTranscriptionCallback(s.accumulatedTranscript + " " + transcript)
+						interim := s.accumulatedTranscript + " " + transcript
+						callbacks <- func() { options.InterimTranscriptionCallback(interim) }
 					}
 				}
 			}
 		}
+		if !msgResp.IsFinal && options.InterimTranscriptionResultCallback != nil {
+			interim := s.accumulatedTranscription.Clone()
+			interim.Append(toTranscription(msgResp))
+			if len(interim.Transcript) > 0 {
+				callbacks <- func() { options.InterimTranscriptionResultCallback(interim) }
+			}
+		}
 
 	case api.TypeUtteranceEndResponse:
 		var msgResp api.UtteranceEndResponse
@@ -225,7 +466,7 @@ func (s *TranscriptionClient) processMessage(_ context.Context, msg []byte, opti
 		}
 
 		if s.unendedSegment {
-			s.onSpeechEnded(options)
+			s.onSpeechEnded(options, callbacks)
 		}
 	case api.TypeSpeechStartedResponse:
 		var msgResp api.SpeechStartedResponse
@@ -236,27 +477,86 @@ func (s *TranscriptionClient) processMessage(_ context.Context, msg []byte, opti
 
 		s.unendedSegment = true
 		if options.SpeechStartedCallback != nil {
-			options.SpeechStartedCallback()
+			callbacks <- options.SpeechStartedCallback
 		}
 	}
 
 }
 
-func (s *TranscriptionClient) onSpeechEnded(options speechtotext.TranscriptionOptions) {
+func (s *TranscriptionClient) onSpeechEnded(options speechtotext.TranscriptionOptions, callbacks chan<- func()) {
 	s.unendedSegment = false
+	if options.TranscriptionResultCallback != nil {
+		transcription := s.accumulatedTranscription
+		s.accumulatedTranscription = speechtotext.Transcription{}
+		if len(transcription.Transcript) > 0 {
+			callbacks <- func() { options.TranscriptionResultCallback(transcription) }
+		}
+	}
 	if options.TranscriptionCallback != nil {
 		fullTranscript := strings.TrimSpace(s.accumulatedTranscript)
 		s.accumulatedTranscript = ""
 		if len(fullTranscript) > 0 {
-			options.TranscriptionCallback(fullTranscript)
+			callbacks <- func() { options.TranscriptionCallback(fullTranscript) }
 		}
 	}
 	if options.SpeechEndedCallback != nil {
-		options.SpeechEndedCallback()
+		callbacks <- options.SpeechEndedCallback
+	}
+}
+
+func toTranscription(msg api.MessageResponse) speechtotext.Transcription {
+	transcription := speechtotext.Transcription{
+		Start: toDuration(msg.Start),
+		End:   toDuration(msg.Start + msg.Duration),
 	}
+	for i, alternative := range msg.Channel.Alternatives {
+		words := make([]speechtotext.Word, 0, len(alternative.Words))
+		for _, word := range alternative.Words {
+			punctuatedWord := word.PunctuatedWord
+			if punctuatedWord == "" {
+				punctuatedWord = word.Word
+			}
+			speaker := ""
+			if word.Speaker != nil {
+				speaker = strconv.Itoa(*word.Speaker)
+			}
+			words = append(words, speechtotext.Word{
+				Word:           word.Word,
+				PunctuatedWord: punctuatedWord,
+				Confidence:     word.Confidence,
+				Start:          toDuration(word.Start),
+				End:            toDuration(word.End),
+				Speaker:        speaker,
+			})
+		}
+
+		if i == 0 {
+			transcription.Transcript = strings.TrimSpace(alternative.Transcript)
+			transcription.Confidence = alternative.Confidence
+			transcription.Words = words
+			transcription.Speaker = speechtotext.DominantSpeaker(words)
+			if len(alternative.Languages) > 0 {
+				transcription.Language = alternative.Languages[0]
+			} else if len(alternative.Words) > 0 {
+				transcription.Language = alternative.Words[0].Language
+			}
+			continue
+		}
+		transcription.Alternatives = append(transcription.Alternatives, speechtotext.Alternative{
+			Transcript: strings.TrimSpace(alternative.Transcript),
+			Confidence: alternative.Confidence,
+			Words:      words,
+		})
+	}
+
+	return transcription
 }
 
-func (s *TranscriptionClient) generateSilence(ctx context.Context) {
+func toDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func (s *TranscriptionClient) generateSilence(ctx context.Context, encodingInfo audio.EncodingInfo) {
 	type silenceGeneratorState string
 	const (
 		silenceGeneratorStateWaiting   silenceGeneratorState = "waiting"
@@ -266,11 +566,10 @@ func (s *TranscriptionClient) generateSilence(ctx context.Context) {
 
 	ticker := time.NewTicker(50 * time.Millisecond)
 
-	silenceValue := byte(0)
-	chunk := make([]byte, 50*defaultSampleRate/1000)
-	for i := range chunk {
-		chunk[i] = silenceValue
-	}
+	// NOTE: Silent samples are not zero for every encoding, mu-law and A-law
+	// have their own byte patterns
+	silence := convert.Silence(encodingInfo.Encoding)
+	chunk := bytes.Repeat(silence, 50*encodingInfo.SampleRate/1000*encodingInfo.ChannelCount())
 
 	var state = silenceGeneratorStateWaiting
 	var firstSilenceTime *time.Time
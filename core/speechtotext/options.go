@@ -1,6 +1,10 @@
 package speechtotext
 
-import "github.com/koscakluka/ema-core/core/audio"
+import (
+	"time"
+
+	"github.com/koscakluka/ema-core/core/audio"
+)
 
 type TranscriptionOptions struct {
 	PartialInterimTranscriptionCallback func(transcript string)
@@ -8,10 +12,55 @@ type TranscriptionOptions struct {
 	PartialTranscriptionCallback        func(transcript string)
 	TranscriptionCallback               func(transcript string)
 
+	// InterimTranscriptionResultCallback and TranscriptionResultCallback are
+	// called with the same transcripts as their string counterparts, but with
+	// word level details. Providers call TranscriptionResultCallback before
+	// TranscriptionCallback.
+	InterimTranscriptionResultCallback func(transcription Transcription)
+	TranscriptionResultCallback        func(transcription Transcription)
+
 	SpeechStartedCallback func()
 	SpeechEndedCallback   func()
 
+	ConnectionStateCallback func(state ConnectionState)
+
 	EncodingInfo audio.EncodingInfo
+
+	// Language is a BCP-47 language tag (e.g. "en-US", "de"), providers use
+	// their default if empty
+	Language string
+	// Model is the provider specific transcription model, providers use their
+	// default if empty
+	Model string
+	// Endpointing is the silence after which speech is considered finished, a
+	// zero duration disables endpointing, providers use their default if nil
+	Endpointing *time.Duration
+	// UtteranceEnd is the gap between words after which the utterance is
+	// considered finished, providers use their default if zero
+	UtteranceEnd time.Duration
+	// Keywords are terms the transcription should be biased towards, e.g.
+	// names or domain specific vocabulary
+	Keywords []Keyword
+	// ProfanityFilter masks profanities in transcripts
+	ProfanityFilter bool
+	// Punctuation adds punctuation and capitalization to transcripts,
+	// providers use their default if nil
+	Punctuation *bool
+	// Numerals converts spoken numbers into digits
+	Numerals bool
+	// Alternatives is the maximum number of transcripts returned for the same
+	// audio, including the most likely one
+	Alternatives int
+	// Diarization labels words with the ID of the speaker who said them
+	Diarization bool
+}
+
+// Keyword is a term to boost in the transcription
+type Keyword struct {
+	Term string
+	// Boost is the intensity of the boost, providers that don't support
+	// intensity ignore it, providers use their default if zero
+	Boost float64
 }
 
 type TranscriptionOption func(*TranscriptionOptions)
@@ -62,3 +111,94 @@ func WithEncodingInfo(encodingInfo audio.EncodingInfo) TranscriptionOption {
 		o.EncodingInfo = encodingInfo
 	}
 }
+
+// WithLanguage sets the language of the transcribed speech as a BCP-47 tag
+func WithLanguage(language string) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.Language = language
+	}
+}
+
+// WithModel sets the provider specific transcription model
+func WithModel(model string) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.Model = model
+	}
+}
+
+// WithEndpointing sets the silence after which speech is considered finished,
+// zero disables endpointing
+func WithEndpointing(silence time.Duration) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.Endpointing = &silence
+	}
+}
+
+// WithUtteranceEnd sets the gap between words after which the utterance is
+// considered finished
+func WithUtteranceEnd(gap time.Duration) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.UtteranceEnd = gap
+	}
+}
+
+// WithKeywords adds terms the transcription should be biased towards.
+// Repeating this option will add more keywords.
+func WithKeywords(keywords ...Keyword) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.Keywords = append(o.Keywords, keywords...)
+	}
+}
+
+// WithProfanityFilter sets whether profanities are masked in transcripts
+func WithProfanityFilter(filter bool) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.ProfanityFilter = filter
+	}
+}
+
+// WithPunctuation sets whether transcripts are punctuated and capitalized
+func WithPunctuation(punctuation bool) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.Punctuation = &punctuation
+	}
+}
+
+// WithNumerals sets whether spoken numbers are converted into digits
+func WithNumerals(numerals bool) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.Numerals = numerals
+	}
+}
+
+// WithTranscriptionResultCallback sets the callback called with the full
+// transcription of the utterance once speech ends
+func WithTranscriptionResultCallback(callback func(transcription Transcription)) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.TranscriptionResultCallback = callback
+	}
+}
+
+// WithInterimTranscriptionResultCallback sets the callback called with the
+// transcription of the utterance so far while speech is ongoing
+func WithInterimTranscriptionResultCallback(callback func(transcription Transcription)) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.InterimTranscriptionResultCallback = callback
+	}
+}
+
+// WithAlternatives sets the maximum number of transcripts returned for the
+// same audio, including the most likely one
+func WithAlternatives(alternatives int) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.Alternatives = alternatives
+	}
+}
+
+// WithDiarization sets whether words are labelled with the ID of the speaker
+// who said them
+func WithDiarization(diarization bool) TranscriptionOption {
+	return func(o *TranscriptionOptions) {
+		o.Diarization = diarization
+	}
+}
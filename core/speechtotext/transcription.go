@@ -0,0 +1,21 @@
+package speechtotext
+
+import "github.com/koscakluka/ema-core/core/transcription"
+
+// NOTE: The transcription types live in their own package so LLMs can use
+// them without depending on speech to text
+
+// Transcription is a transcription result with word level details
+type Transcription = transcription.Transcription
+
+// Alternative is a less likely transcript of the same audio
+type Alternative = transcription.Alternative
+
+// Word is a single transcribed word
+type Word = transcription.Word
+
+// DominantSpeaker returns the ID of the speaker who said most of the words,
+// ties go to the one who spoke first
+func DominantSpeaker(words []Word) string {
+	return transcription.DominantSpeaker(words)
+}
@@ -0,0 +1,91 @@
+package whisper
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultServerURL       = "http://127.0.0.1:8080"
+	defaultSilenceDuration = 800 * time.Millisecond
+	defaultInterimInterval = 1 * time.Second
+	defaultEnergyThreshold = 500
+	defaultRequestTimeout  = 30 * time.Second
+)
+
+// TranscriptionClient transcribes speech with a local whisper.cpp server. The
+// streamed audio is split into utterances on silence, utterances are
+// transcribed while they are ongoing for interim results and once more when
+// they end for the final transcript.
+type TranscriptionClient struct {
+	serverURL       string
+	httpClient      *http.Client
+	silenceDuration time.Duration
+	interimInterval time.Duration
+	energyThreshold float64
+
+	audio  chan []byte
+	cancel context.CancelFunc
+	mu     sync.Mutex
+}
+
+func NewClient(ctx context.Context, opts ...ClientOption) *TranscriptionClient {
+	client := &TranscriptionClient{
+		serverURL:       defaultServerURL,
+		httpClient:      &http.Client{Timeout: defaultRequestTimeout},
+		silenceDuration: defaultSilenceDuration,
+		interimInterval: defaultInterimInterval,
+		energyThreshold: defaultEnergyThreshold,
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	return client
+}
+
+type ClientOption func(*TranscriptionClient)
+
+// WithServerURL sets the URL of the whisper.cpp server, defaults to
+// http://127.0.0.1:8080
+func WithServerURL(url string) ClientOption {
+	return func(c *TranscriptionClient) {
+		c.serverURL = url
+	}
+}
+
+// WithHTTPClient sets the client used for requests to the server
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *TranscriptionClient) {
+		c.httpClient = client
+	}
+}
+
+// WithSilenceDuration sets how long the silence after speech has to be for the
+// utterance to end
+func WithSilenceDuration(duration time.Duration) ClientOption {
+	return func(c *TranscriptionClient) {
+		c.silenceDuration = duration
+	}
+}
+
+// WithInterimInterval sets how often the ongoing utterance is transcribed for
+// interim results, zero disables interim results
+func WithInterimInterval(interval time.Duration) ClientOption {
+	return func(c *TranscriptionClient) {
+		c.interimInterval = interval
+	}
+}
+
+// WithEnergyThreshold sets the minimum RMS amplitude of speech
+func WithEnergyThreshold(threshold float64) ClientOption {
+	return func(c *TranscriptionClient) {
+		c.energyThreshold = threshold
+	}
+}
+
+func (c *TranscriptionClient) Close() error {
+	return c.StopStream()
+}
@@ -0,0 +1,176 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/audio/convert"
+	"github.com/koscakluka/ema-core/core/audio/wav"
+	"github.com/koscakluka/ema-core/core/speechtotext"
+)
+
+const (
+	// whisperSampleRate is the only sample rate whisper.cpp accepts without
+	// converting audio on the server
+	whisperSampleRate = 16000
+	// maxNoSpeechProbability is the probability above which a segment is
+	// considered a hallucination on silence or noise
+	maxNoSpeechProbability = 0.6
+)
+
+type inferenceResponse struct {
+	Language string `json:"language"`
+	Text     string `json:"text"`
+	Segments []struct {
+		Text         string  `json:"text"`
+		Start        float64 `json:"start"`
+		End          float64 `json:"end"`
+		AvgLogprob   float64 `json:"avg_logprob"`
+		NoSpeechProb float64 `json:"no_speech_prob"`
+		Words        []struct {
+			Word        string  `json:"word"`
+			Start       float64 `json:"start"`
+			End         float64 `json:"end"`
+			Probability float64 `json:"probability"`
+		} `json:"words"`
+	} `json:"segments"`
+}
+
+// infer transcribes the utterance with the whisper.cpp server's inference
+// endpoint
+func (c *TranscriptionClient) infer(ctx context.Context, u utterance, options speechtotext.TranscriptionOptions) (speechtotext.Transcription, error) {
+	whisperEncodingInfo := audio.EncodingInfo{SampleRate: whisperSampleRate, Encoding: audio.EncodingLinear16}
+	converter, err := convert.NewConverter(options.EncodingInfo, whisperEncodingInfo)
+	if err != nil {
+		return speechtotext.Transcription{}, fmt.Errorf("failed to create audio converter: %w", err)
+	}
+	converted := converter.Convert(u.audio)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return speechtotext.Transcription{}, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if err := wav.WriteHeader(file, wav.Header{EncodingInfo: whisperEncodingInfo, DataSize: len(converted)}); err != nil {
+		return speechtotext.Transcription{}, fmt.Errorf("failed to write wav header: %w", err)
+	}
+	if _, err := file.Write(converted); err != nil {
+		return speechtotext.Transcription{}, fmt.Errorf("failed to write wav: %w", err)
+	}
+	fields := map[string]string{
+		"response_format": "verbose_json",
+		"temperature":     "0.0",
+	}
+	if options.Language != "" {
+		// NOTE: Whisper only knows languages, not regions
+		fields["language"] = strings.ToLower(strings.SplitN(options.Language, "-", 2)[0])
+	}
+	if len(options.Keywords) > 0 {
+		// NOTE: Whisper has no keyword boosting, but the initial prompt biases
+		// it towards the vocabulary used in it
+		terms := make([]string, 0, len(options.Keywords))
+		for _, keyword := range options.Keywords {
+			terms = append(terms, keyword.Term)
+		}
+		fields["prompt"] = strings.Join(terms, ", ")
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return speechtotext.Transcription{}, fmt.Errorf("failed to write form field %s: %w", name, err)
+		}
+	}
+	if err := form.Close(); err != nil {
+		return speechtotext.Transcription{}, fmt.Errorf("failed to close form: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.serverURL, "/")+"/inference", &body)
+	if err != nil {
+		return speechtotext.Transcription{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return speechtotext.Transcription{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return speechtotext.Transcription{}, fmt.Errorf("whisper server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var inference inferenceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&inference); err != nil {
+		return speechtotext.Transcription{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return toTranscription(inference, u.start), nil
+}
+
+func toTranscription(inference inferenceResponse, offset time.Duration) speechtotext.Transcription {
+	toDuration := func(seconds float64) time.Duration {
+		return offset + time.Duration(seconds*float64(time.Second))
+	}
+
+	transcription := speechtotext.Transcription{Language: inference.Language}
+	if len(inference.Segments) == 0 {
+		transcription.Transcript = strings.TrimSpace(inference.Text)
+		transcription.Confidence = 1
+		return transcription
+	}
+
+	texts := []string{}
+	started := false
+	var confidenceSum float64
+	var confidenceCount int
+	for _, segment := range inference.Segments {
+		if segment.NoSpeechProb > maxNoSpeechProbability {
+			continue
+		}
+		if text := strings.TrimSpace(segment.Text); text != "" {
+			texts = append(texts, text)
+		}
+		if !started {
+			transcription.Start = toDuration(segment.Start)
+			started = true
+		}
+		transcription.End = toDuration(segment.End)
+
+		if len(segment.Words) == 0 {
+			confidenceSum += math.Exp(segment.AvgLogprob)
+			confidenceCount++
+		}
+		for _, word := range segment.Words {
+			text := strings.TrimSpace(word.Word)
+			if text == "" {
+				continue
+			}
+			transcription.Words = append(transcription.Words, speechtotext.Word{
+				Word:           strings.ToLower(strings.Trim(text, ".,!?;:\"")),
+				PunctuatedWord: text,
+				Confidence:     word.Probability,
+				Start:          toDuration(word.Start),
+				End:            toDuration(word.End),
+			})
+			confidenceSum += word.Probability
+			confidenceCount++
+		}
+	}
+	transcription.Transcript = strings.Join(texts, " ")
+	if confidenceCount > 0 {
+		transcription.Confidence = confidenceSum / float64(confidenceCount)
+	}
+
+	return transcription
+}
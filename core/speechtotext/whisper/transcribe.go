@@ -0,0 +1,295 @@
+package whisper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/audio"
+	"github.com/koscakluka/ema-core/core/audio/vad"
+	"github.com/koscakluka/ema-core/core/speechtotext"
+)
+
+const (
+	defaultEncoding   = audio.EncodingLinear16
+	defaultSampleRate = 48000
+
+	// minSpeechDuration is how long voice has to be detected for speech to
+	// start, shorter sounds are considered noise
+	minSpeechDuration = 100 * time.Millisecond
+	// preRollDuration is the audio kept before speech starts so the
+	// beginning of the first word isn't cut off
+	preRollDuration = 300 * time.Millisecond
+	// maxInterimWindow is the most recent part of the utterance transcribed
+	// for interim results, whisper works on 30 second windows
+	maxInterimWindow = 30 * time.Second
+)
+
+// utterance is a segment of speech between silences
+type utterance struct {
+	id    int64
+	audio []byte
+	// start is the offset of the utterance from the start of the stream
+	start time.Duration
+}
+
+func (c *TranscriptionClient) Transcribe(ctx context.Context, opts ...speechtotext.TranscriptionOption) error {
+	options := speechtotext.TranscriptionOptions{
+		EncodingInfo: audio.EncodingInfo{
+			SampleRate: defaultSampleRate,
+			Encoding:   defaultEncoding,
+		},
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if !c.SupportsEncoding(options.EncodingInfo) {
+		return fmt.Errorf("unsupported encoding %q with %d channels, only mono %s is supported", options.EncodingInfo.Encoding, options.EncodingInfo.ChannelCount(), defaultEncoding)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	audioChunks := make(chan []byte, 100) // TODO: Figure out good values for this
+
+	c.mu.Lock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.cancel = cancel
+	c.audio = audioChunks
+	c.mu.Unlock()
+
+	finals := make(chan utterance, 10)
+	stream := &stream{client: c, options: options}
+	go stream.transcribeFinals(ctx, finals)
+	go stream.segment(ctx, audioChunks, finals)
+
+	if options.ConnectionStateCallback != nil {
+		options.ConnectionStateCallback(speechtotext.ConnectionStateConnected)
+	}
+
+	return nil
+}
+
+// SupportsEncoding reports whether the client can transcribe the encoding,
+// only linear16 mono audio is supported
+func (c *TranscriptionClient) SupportsEncoding(encodingInfo audio.EncodingInfo) bool {
+	return encodingInfo.Encoding == defaultEncoding &&
+		encodingInfo.ChannelCount() == 1 &&
+		encodingInfo.SampleRate > 0
+}
+
+func (c *TranscriptionClient) SendAudio(audio []byte) error {
+	c.mu.Lock()
+	audioChunks := c.audio
+	c.mu.Unlock()
+
+	if audioChunks == nil {
+		return fmt.Errorf("stream not open")
+	}
+
+	select {
+	case audioChunks <- append([]byte{}, audio...):
+		return nil
+	default:
+		return fmt.Errorf("audio buffer full, whisper is not keeping up")
+	}
+}
+
+// Finalize ends the current utterance and transcribes it right away, e.g.
+// when the user stopped recording, instead of waiting for the silence
+func (c *TranscriptionClient) Finalize() error {
+	c.mu.Lock()
+	audioChunks := c.audio
+	c.mu.Unlock()
+
+	if audioChunks == nil {
+		return fmt.Errorf("stream not open")
+	}
+
+	// NOTE: A nil chunk marks the end of the utterance, it goes through the
+	// same channel so the audio sent before it is part of the utterance
+	select {
+	case audioChunks <- nil:
+		return nil
+	default:
+		return fmt.Errorf("audio buffer full, whisper is not keeping up")
+	}
+}
+
+func (c *TranscriptionClient) StopStream() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+	c.audio = nil
+	return nil
+}
+
+// stream is the state of a single Transcribe call
+type stream struct {
+	client  *TranscriptionClient
+	options speechtotext.TranscriptionOptions
+
+	// finalizedID is the ID of the last utterance that ended, interim results
+	// of it or earlier utterances are dropped
+	finalizedID     atomic.Int64
+	interimInFlight atomic.Bool
+}
+
+// segment splits the audio into utterances on silence, reporting speech start
+// and requesting interim transcriptions while the utterance is ongoing
+func (s *stream) segment(ctx context.Context, audioChunks <-chan []byte, finals chan<- utterance) {
+	defer close(finals)
+
+	bytesPerSecond := s.options.EncodingInfo.SampleRate * 2
+	toDuration := func(bytes int) time.Duration {
+		return time.Duration(bytes) * time.Second / time.Duration(bytesPerSecond)
+	}
+	toBytes := func(duration time.Duration) int {
+		return int(duration.Seconds()*float64(bytesPerSecond)) &^ 1
+	}
+
+	detector := vad.NewDetector(s.options.EncodingInfo.SampleRate,
+		vad.WithEnergyThreshold(s.client.energyThreshold),
+		vad.WithMinSpeechDuration(minSpeechDuration),
+		vad.WithMinSilenceDuration(s.client.silenceDuration),
+	)
+
+	var (
+		current        *utterance
+		preRoll        []byte
+		streamPosition time.Duration
+		sinceInterim   time.Duration
+		nextID         int64
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case chunk := <-audioChunks:
+			if chunk == nil {
+				if current != nil {
+					s.finalizedID.Store(current.id)
+					finals <- *current
+					current = nil
+				}
+				detector.Reset()
+				continue
+			}
+
+			chunkDuration := toDuration(len(chunk))
+			streamPosition += chunkDuration
+			event := detector.Process(chunk)
+
+			if current == nil {
+				// NOTE: Pre-roll also has to hold the audio it took to detect
+				// speech
+				preRoll = append(preRoll, chunk...)
+				if excess := len(preRoll) - toBytes(preRollDuration+minSpeechDuration); excess > 0 {
+					preRoll = preRoll[excess:]
+				}
+				if event != vad.EventSpeechStarted {
+					continue
+				}
+
+				nextID++
+				current = &utterance{
+					id:    nextID,
+					audio: preRoll,
+					start: streamPosition - toDuration(len(preRoll)),
+				}
+				preRoll, sinceInterim = nil, 0
+				if s.options.SpeechStartedCallback != nil {
+					s.options.SpeechStartedCallback()
+				}
+				continue
+			}
+
+			current.audio = append(current.audio, chunk...)
+			if event == vad.EventSpeechEnded {
+				s.finalizedID.Store(current.id)
+				finals <- *current
+				current = nil
+				continue
+			}
+
+			sinceInterim += chunkDuration
+			if s.client.interimInterval > 0 && sinceInterim >= s.client.interimInterval && s.wantsInterims() {
+				sinceInterim = 0
+				window := current.audio
+				windowStart := current.start
+				if excess := len(window) - toBytes(maxInterimWindow); excess > 0 {
+					window = window[excess:]
+					windowStart += toDuration(excess)
+				}
+				s.transcribeInterim(ctx, utterance{id: current.id, audio: window, start: windowStart})
+			}
+		}
+	}
+}
+
+func (s *stream) wantsInterims() bool {
+	return s.options.InterimTranscriptionCallback != nil ||
+		s.options.PartialInterimTranscriptionCallback != nil ||
+		s.options.InterimTranscriptionResultCallback != nil
+}
+
+// transcribeInterim transcribes the ongoing utterance in the background,
+// skipping it if the previous interim transcription is still running
+func (s *stream) transcribeInterim(ctx context.Context, u utterance) {
+	if !s.interimInFlight.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer s.interimInFlight.Store(false)
+
+		transcription, err := s.client.infer(ctx, u, s.options)
+		if err != nil {
+			log.Printf("Failed to transcribe interim whisper audio: %v", err)
+			return
+		}
+		if s.finalizedID.Load() >= u.id || transcription.Transcript == "" {
+			return
+		}
+
+		if s.options.PartialInterimTranscriptionCallback != nil {
+			s.options.PartialInterimTranscriptionCallback(transcription.Transcript)
+		} else if s.options.InterimTranscriptionCallback != nil {
+			s.options.InterimTranscriptionCallback(transcription.Transcript)
+		}
+		if s.options.InterimTranscriptionResultCallback != nil {
+			s.options.InterimTranscriptionResultCallback(transcription)
+		}
+	}()
+}
+
+// transcribeFinals transcribes ended utterances in order
+func (s *stream) transcribeFinals(ctx context.Context, finals <-chan utterance) {
+	for u := range finals {
+		transcription, err := s.client.infer(ctx, u, s.options)
+		if err != nil {
+			log.Printf("Failed to transcribe whisper audio: %v", err)
+		}
+
+		if transcription.Transcript != "" {
+			if s.options.TranscriptionResultCallback != nil {
+				s.options.TranscriptionResultCallback(transcription)
+			}
+			if s.options.PartialTranscriptionCallback != nil {
+				s.options.PartialTranscriptionCallback(transcription.Transcript)
+			}
+			if s.options.TranscriptionCallback != nil {
+				s.options.TranscriptionCallback(transcription.Transcript)
+			}
+		}
+		if s.options.SpeechEndedCallback != nil {
+			s.options.SpeechEndedCallback()
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package texttospeech
+
+// ConnectionState is the state of the connection to the provider
+type ConnectionState string
+
+const (
+	ConnectionStateConnected    ConnectionState = "connected"
+	ConnectionStateReconnecting ConnectionState = "reconnecting"
+	// ConnectionStateFailed means reconnecting was given up on, the stream
+	// needs to be reopened
+	ConnectionStateFailed ConnectionState = "failed"
+)
+
+// WithConnectionStateCallback sets the callback called when the connection
+// to the provider changes its state
+func WithConnectionStateCallback(callback func(state ConnectionState)) TextToSpeechOption {
+	return func(o *TextToSpeechOptions) {
+		o.ConnectionStateCallback = callback
+	}
+}
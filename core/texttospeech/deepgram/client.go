@@ -7,18 +7,26 @@ import (
 	"sync"
 
 	"github.com/gorilla/websocket"
+	"github.com/koscakluka/ema-core/core/audio"
 )
 
 type TextToSpeechClient struct {
 	wsConn           *websocket.Conn
 	transcriptBuffer []string
 
-	voice deepgramVoice
-	mu    sync.Mutex
+	apiKey       string
+	voice        deepgramVoice
+	encodingInfo audio.EncodingInfo
+	reconnecting bool
+	closed       bool
+	mu           sync.Mutex
 }
 
-func NewTextToSpeechClient(ctx context.Context, voice deepgramVoice) (*TextToSpeechClient, error) {
+func NewTextToSpeechClient(ctx context.Context, voice deepgramVoice, opts ...TextToSpeechOption) (*TextToSpeechClient, error) {
 	client := &TextToSpeechClient{voice: defaultVoice}
+	for _, opt := range opts {
+		opt(client)
+	}
 
 	if !slices.Contains(GetAvailableVoices(), voice) {
 		return nil, fmt.Errorf("invalid voice")
@@ -29,6 +37,31 @@ func NewTextToSpeechClient(ctx context.Context, voice deepgramVoice) (*TextToSpe
 	return client, nil
 }
 
+type TextToSpeechOption func(*TextToSpeechClient)
+
+// WithAPIKey sets the API key, by default it is read from DEEPGRAM_API_KEY
+func WithAPIKey(apiKey string) TextToSpeechOption {
+	return func(c *TextToSpeechClient) {
+		c.apiKey = apiKey
+	}
+}
+
+// SupportsEncoding reports whether Deepgram can stream speech in the
+// encoding, it only streams mono audio at a few sample rates
+func (c *TextToSpeechClient) SupportsEncoding(encodingInfo audio.EncodingInfo) bool {
+	if encodingInfo.ChannelCount() != 1 {
+		return false
+	}
+	switch encodingInfo.Encoding {
+	case audio.EncodingLinear16:
+		return slices.Contains([]int{8000, 16000, 24000, 32000, 48000}, encodingInfo.SampleRate)
+	case audio.EncodingMulaw, audio.EncodingAlaw:
+		return slices.Contains([]int{8000, 16000}, encodingInfo.SampleRate)
+	default:
+		return false
+	}
+}
+
 func (c *TextToSpeechClient) Close(ctx context.Context) {
 	c.CloseStream(ctx)
 }
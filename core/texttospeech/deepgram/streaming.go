@@ -13,11 +13,12 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/koscakluka/ema-core/core/audio"
 	"github.com/koscakluka/ema-core/core/texttospeech"
+	"github.com/koscakluka/ema-core/internal/reconnect"
 )
 
 const (
 	defaultSampleRate = 48000
-	defaultEncoding   = "linear16"
+	defaultEncoding   = audio.EncodingLinear16
 )
 
 func (c *TextToSpeechClient) OpenStream(ctx context.Context, opts ...texttospeech.TextToSpeechOption) error {
@@ -31,26 +32,33 @@ func (c *TextToSpeechClient) OpenStream(ctx context.Context, opts ...texttospeec
 		opt(&options)
 	}
 
-	conn, err := connectWebsocket(c.voice, options.EncodingInfo)
+	conn, err := connectWebsocket(c.apiKey, c.voice, options.EncodingInfo)
 	if err != nil {
 		return fmt.Errorf("failed to open websocket: %w", err)
 	}
 
+	c.mu.Lock()
 	c.wsConn = conn
+	c.encodingInfo = options.EncodingInfo
+	c.closed = false
+	c.mu.Unlock()
+	notifyConnectionState(options, texttospeech.ConnectionStateConnected)
 
 	go c.readAndProcessMessages(ctx, conn, options)
 
 	return nil
 }
 
-func connectWebsocket(voice deepgramVoice, encodingInfo audio.EncodingInfo) (*websocket.Conn, error) {
-	apiKey, ok := os.LookupEnv("DEEPGRAM_API_KEY")
-	if !ok {
-		return nil, fmt.Errorf("deepgram api key not found")
+func connectWebsocket(apiKey string, voice deepgramVoice, encodingInfo audio.EncodingInfo) (*websocket.Conn, error) {
+	if apiKey == "" {
+		var ok bool
+		if apiKey, ok = os.LookupEnv("DEEPGRAM_API_KEY"); !ok {
+			return nil, fmt.Errorf("deepgram api key not found")
+		}
 	}
 
 	urlValues := url.Values{}
-	urlValues.Set("encoding", encodingInfo.Encoding)
+	urlValues.Set("encoding", string(encodingInfo.Encoding))
 	urlValues.Set("sample_rate", strconv.Itoa(encodingInfo.SampleRate))
 	urlValues.Set("model", string(voice))
 	urlValues.Set("container", "none")
@@ -70,12 +78,13 @@ func connectWebsocket(voice deepgramVoice, encodingInfo audio.EncodingInfo) (*we
 }
 
 func (c *TextToSpeechClient) SendText(text string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if len(c.transcriptBuffer) == 0 {
 		c.transcriptBuffer = append(c.transcriptBuffer, "")
 	}
 
-	// TODO: Instead of (or in addition to) a mutex, we could implement a buffer
-	// to prevent writing to the websocket at the same time
 	if len(c.transcriptBuffer) == 1 {
 		if err := c.speak(text); err != nil {
 			return err
@@ -86,13 +95,16 @@ func (c *TextToSpeechClient) SendText(text string) error {
 	return nil
 }
 
+// speak sends the text to deepgram, c.mu must be held
 func (c *TextToSpeechClient) speak(text string) error {
 	if c.wsConn == nil {
+		if c.reconnecting {
+			// NOTE: The text is in the transcript buffer and will be sent
+			// once the connection is reestablished
+			return nil
+		}
 		return fmt.Errorf("connection closed")
 	}
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
 	if err := c.wsConn.WriteJSON(struct {
 		Type string `json:"type"`
 		Text string `json:"text"`
@@ -107,6 +119,9 @@ func (c *TextToSpeechClient) speak(text string) error {
 }
 
 func (c *TextToSpeechClient) FlushBuffer() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if len(c.transcriptBuffer) == 1 {
 		if err := c.flush(); err != nil {
 			return err
@@ -120,13 +135,14 @@ func (c *TextToSpeechClient) FlushBuffer() error {
 	return nil
 }
 
+// flush asks deepgram to speak the text sent so far, c.mu must be held
 func (c *TextToSpeechClient) flush() error {
 	if c.wsConn == nil {
+		if c.reconnecting {
+			return nil
+		}
 		return fmt.Errorf("connection closed")
 	}
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
 	if err := c.wsConn.WriteJSON(struct {
 		Type string `json:"type"`
 	}{
@@ -138,7 +154,14 @@ func (c *TextToSpeechClient) flush() error {
 }
 
 func (c *TextToSpeechClient) ClearBuffer() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.wsConn == nil {
+		if c.reconnecting {
+			c.transcriptBuffer = []string{}
+			return nil
+		}
 		return fmt.Errorf("connection closed")
 	}
 	if err := c.wsConn.WriteJSON(struct {
@@ -154,9 +177,12 @@ func (c *TextToSpeechClient) ClearBuffer() error {
 }
 
 func (c *TextToSpeechClient) CloseStream(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.reconnecting = false
 	if c.wsConn != nil {
-		c.mu.Lock()
-		defer c.mu.Unlock()
 		if err := c.wsConn.WriteJSON(struct {
 			Type string `json:"type"`
 		}{
@@ -170,18 +196,27 @@ func (c *TextToSpeechClient) CloseStream(ctx context.Context) error {
 	return nil
 }
 
-func (c *TextToSpeechClient) readAndProcessMessages(_ context.Context, conn *websocket.Conn, options texttospeech.TextToSpeechOptions) {
+func (c *TextToSpeechClient) readAndProcessMessages(ctx context.Context, conn *websocket.Conn, options texttospeech.TextToSpeechOptions) {
 	for {
 		msgType, msg, err := conn.ReadMessage()
 		if err != nil {
-			if err.Error() != "websocket: close 1000 (normal)" {
-				log.Printf("Websocket read error: %v", err)
-			}
+			conn.Close()
 
-			c.wsConn.Close()
+			c.mu.Lock()
 			c.wsConn = nil
+			c.reconnecting = !c.closed && !websocket.IsCloseError(err, websocket.CloseNormalClosure)
+			reconnect := c.reconnecting
+			c.mu.Unlock()
+			if !reconnect {
+				return
+			}
 
-			return
+			log.Printf("Websocket read error: %v", err)
+			if conn, err = c.reconnect(ctx, options); err != nil {
+				log.Printf("Failed to reconnect to deepgram: %v", err)
+				return
+			}
+			continue
 		}
 
 		switch msgType {
@@ -201,25 +236,109 @@ func (c *TextToSpeechClient) readAndProcessMessages(_ context.Context, conn *web
 
 			switch parsedMsg.Type {
 			case "Flushed":
-				if len(c.transcriptBuffer) > 0 {
-					if options.AudioEnded != nil {
-						options.AudioEnded(c.transcriptBuffer[0])
-					}
+				c.mu.Lock()
+				var spoken string
+				ended := len(c.transcriptBuffer) > 0
+				if ended {
+					spoken = c.transcriptBuffer[0]
 					c.transcriptBuffer = c.transcriptBuffer[1:]
 				}
-				if len(c.transcriptBuffer) > 0 {
-					if err := c.speak(c.transcriptBuffer[0]); err != nil {
-						log.Printf("Failed to speak deepgram text: %v", err)
-						continue
-					}
+				c.mu.Unlock()
+				// NOTE: The callback is called without holding the lock so
+				// it can send more text
+				if ended && options.AudioEnded != nil {
+					options.AudioEnded(spoken)
 				}
-				if len(c.transcriptBuffer) > 1 {
-					if err := c.flush(); err != nil {
-						log.Printf("Failed to flush deepgram buffer: %v", err)
-						continue
-					}
+				if err := c.speakNext(); err != nil {
+					log.Printf("Failed to speak deepgram text: %v", err)
 				}
 			}
 		}
 	}
 }
+
+// reconnect tries to reopen the connection with exponential backoff and
+// resends the text that was not yet fully spoken
+func (c *TextToSpeechClient) reconnect(ctx context.Context, options texttospeech.TextToSpeechOptions) (*websocket.Conn, error) {
+	notifyConnectionState(options, texttospeech.ConnectionStateReconnecting)
+
+	conn, err := reconnect.Retry(ctx, "deepgram", func() (*websocket.Conn, error) {
+		return connectWebsocket(c.apiKey, c.voice, c.encodingInfo)
+	})
+	if err != nil {
+		c.stopReconnecting()
+		if ctx.Err() == nil {
+			notifyConnectionState(options, texttospeech.ConnectionStateFailed)
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		conn.Close()
+		return nil, fmt.Errorf("stream closed while reconnecting")
+	}
+	// NOTE: The text being spoken when the connection dropped is resent
+	// whole, so some of its audio might be repeated. It is sent before
+	// releasing the lock so new text can't get ahead of it.
+	if len(c.transcriptBuffer) > 0 && c.transcriptBuffer[0] != "" {
+		if err := conn.WriteJSON(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{
+			Type: "Speak",
+			Text: c.transcriptBuffer[0],
+		}); err != nil {
+			log.Printf("Failed to resend deepgram text: %v", err)
+		}
+	}
+	if len(c.transcriptBuffer) > 1 {
+		if err := conn.WriteJSON(struct {
+			Type string `json:"type"`
+		}{
+			Type: "Flush",
+		}); err != nil {
+			log.Printf("Failed to flush deepgram buffer: %v", err)
+		}
+	}
+	c.wsConn = conn
+	c.reconnecting = false
+	c.mu.Unlock()
+
+	notifyConnectionState(options, texttospeech.ConnectionStateConnected)
+
+	return conn, nil
+}
+
+// speakNext sends the next buffered text to deepgram and flushes it if more
+// text is waiting after it
+func (c *TextToSpeechClient) speakNext() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.transcriptBuffer) > 0 {
+		if err := c.speak(c.transcriptBuffer[0]); err != nil {
+			return err
+		}
+	}
+	if len(c.transcriptBuffer) > 1 {
+		if err := c.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *TextToSpeechClient) stopReconnecting() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reconnecting = false
+}
+
+func notifyConnectionState(options texttospeech.TextToSpeechOptions, state texttospeech.ConnectionState) {
+	if options.ConnectionStateCallback != nil {
+		options.ConnectionStateCallback(state)
+	}
+}
@@ -6,6 +6,8 @@ type TextToSpeechOptions struct {
 	AudioCallback func(audio []byte)
 	AudioEnded    func(transcript string)
 
+	ConnectionStateCallback func(state ConnectionState)
+
 	EncodingInfo audio.EncodingInfo
 }
 
@@ -0,0 +1,153 @@
+// Package transcription holds the word level transcription types shared by
+// speech to text clients and the turns passed to LLMs
+package transcription
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Transcription is a transcription result with word level details
+type Transcription struct {
+	Transcript string
+	// Confidence is the provider's confidence in the transcript, from 0 to 1
+	Confidence float64
+	Words      []Word
+
+	// Start and End are offsets from the start of the audio stream
+	Start time.Duration
+	End   time.Duration
+
+	// Alternatives are other possible transcripts ordered from the most
+	// likely, they don't include the transcript itself
+	Alternatives []Alternative
+	// Language is the detected language, empty if the provider doesn't detect
+	// it
+	Language string
+	// Speaker is the ID of the speaker who said most of the words, empty
+	// without diarization
+	Speaker string
+}
+
+// Alternative is a less likely transcript of the same audio
+type Alternative struct {
+	Transcript string
+	Confidence float64
+	Words      []Word
+}
+
+// Word is a single transcribed word
+type Word struct {
+	Word string
+	// PunctuatedWord is the word with punctuation and capitalization, same as
+	// Word if the provider doesn't punctuate
+	PunctuatedWord string
+	Confidence     float64
+
+	// Start and End are offsets from the start of the audio stream
+	Start time.Duration
+	End   time.Duration
+
+	// Speaker is the ID of the speaker, empty without diarization
+	Speaker string
+}
+
+// Speakers returns the IDs of all speakers in the transcription in the order
+// they first spoke
+func (t Transcription) Speakers() []string {
+	speakers := []string{}
+	for _, word := range t.Words {
+		if word.Speaker != "" && !slices.Contains(speakers, word.Speaker) {
+			speakers = append(speakers, word.Speaker)
+		}
+	}
+	return speakers
+}
+
+// Clone returns a copy of the transcription that doesn't share its words or
+// alternatives
+func (t Transcription) Clone() Transcription {
+	t.Words = slices.Clone(t.Words)
+	alternatives := slices.Clone(t.Alternatives)
+	for i := range alternatives {
+		alternatives[i].Words = slices.Clone(alternatives[i].Words)
+	}
+	t.Alternatives = alternatives
+	return t
+}
+
+// Append joins a following segment of the same utterance to the
+// transcription. Confidences are averaged by the number of words and
+// alternatives are joined by their rank, using the best transcript for
+// segments with fewer alternatives.
+func (t *Transcription) Append(segment Transcription) {
+	if strings.TrimSpace(segment.Transcript) == "" {
+		return
+	}
+	if strings.TrimSpace(t.Transcript) == "" {
+		*t = segment
+		return
+	}
+
+	alternatives := make([]Alternative, max(len(t.Alternatives), len(segment.Alternatives)))
+	for i := range alternatives {
+		current := Alternative{Transcript: t.Transcript, Confidence: t.Confidence, Words: t.Words}
+		if i < len(t.Alternatives) {
+			current = t.Alternatives[i]
+		}
+		next := Alternative{Transcript: segment.Transcript, Confidence: segment.Confidence, Words: segment.Words}
+		if i < len(segment.Alternatives) {
+			next = segment.Alternatives[i]
+		}
+		alternatives[i] = joinAlternatives(current, next)
+	}
+	if len(alternatives) > 0 {
+		t.Alternatives = alternatives
+	}
+
+	best := joinAlternatives(
+		Alternative{Transcript: t.Transcript, Confidence: t.Confidence, Words: t.Words},
+		Alternative{Transcript: segment.Transcript, Confidence: segment.Confidence, Words: segment.Words},
+	)
+	t.Transcript = best.Transcript
+	t.Confidence = best.Confidence
+	t.Words = best.Words
+
+	if t.Start > segment.Start {
+		t.Start = segment.Start
+	}
+	if t.End < segment.End {
+		t.End = segment.End
+	}
+	if t.Language == "" {
+		t.Language = segment.Language
+	}
+	t.Speaker = DominantSpeaker(t.Words)
+}
+
+// DominantSpeaker returns the ID of the speaker who said most of the words,
+// ties go to the one who spoke first
+func DominantSpeaker(words []Word) string {
+	counts := map[string]int{}
+	dominant := ""
+	for _, word := range words {
+		if word.Speaker == "" {
+			continue
+		}
+		counts[word.Speaker]++
+		if dominant == "" || counts[word.Speaker] > counts[dominant] {
+			dominant = word.Speaker
+		}
+	}
+	return dominant
+}
+
+func joinAlternatives(a, b Alternative) Alternative {
+	aWeight, bWeight := max(len(a.Words), 1), max(len(b.Words), 1)
+	return Alternative{
+		Transcript: strings.TrimSpace(a.Transcript + " " + b.Transcript),
+		Confidence: (a.Confidence*float64(aWeight) + b.Confidence*float64(bWeight)) / float64(aWeight+bWeight),
+		Words:      append(append([]Word{}, a.Words...), b.Words...),
+	}
+}
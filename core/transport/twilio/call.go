@@ -0,0 +1,309 @@
+package twilio
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	orchestration "github.com/koscakluka/ema-core/core"
+	"github.com/koscakluka/ema-core/core/audio"
+)
+
+// Call is a phone call's media stream. It is the orchestrator's audio input
+// (AudioInput and AudioInputFine) and output (AudioOutputV1).
+type Call struct {
+	ws           *websocket.Conn
+	start        StartPayload
+	encodingInfo audio.EncodingInfo
+
+	onAudio func(audio []byte)
+	// captureStarted is set once capture was started, the caller's audio
+	// is kept in pendingAudio until then
+	captureStarted bool
+	pendingAudio   [][]byte
+	pendingSize    int
+	onDTMF         func(digit string)
+	marks          []callMark
+	nextMarkID     int
+
+	closed  bool
+	done    chan struct{}
+	mu      sync.Mutex
+	writeMu sync.Mutex
+}
+
+// maxPendingAudio is the amount of the caller's audio kept until capture
+// starts, older audio is dropped
+const maxPendingAudio = 2 * time.Second
+
+type callMark struct {
+	// id is sent to Twilio as the mark's name, the orchestrator's mark names
+	// are sentences that can repeat
+	id       string
+	name     string
+	callback func(string)
+}
+
+func newCall(ws *websocket.Conn, start *StartPayload) (*Call, error) {
+	format := start.MediaFormat
+	if format.Encoding != MediaEncodingMulaw {
+		return nil, fmt.Errorf("unsupported media encoding %q", format.Encoding)
+	}
+
+	return &Call{
+		ws:    ws,
+		start: *start,
+		encodingInfo: audio.EncodingInfo{
+			SampleRate: format.SampleRate,
+			Encoding:   audio.EncodingMulaw,
+			Channels:   max(format.Channels, 1),
+		},
+		done: make(chan struct{}),
+	}, nil
+}
+
+// Orchestrate starts the orchestration and waits until the call ends or ctx
+// is done. It returns the error if the orchestration fails to start.
+func (c *Call) Orchestrate(ctx context.Context, orchestrator *orchestration.Orchestrator, opts ...orchestration.OrchestrateOption) error {
+	if err := orchestrator.Orchestrate(ctx, opts...); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-c.done:
+	}
+	return nil
+}
+
+// CallSID identifies the call in Twilio
+func (c *Call) CallSID() string {
+	return c.start.CallSID
+}
+
+func (c *Call) StreamSID() string {
+	return c.start.StreamSID
+}
+
+// CustomParameters are the parameters set on the stream in the TwiML
+func (c *Call) CustomParameters() map[string]string {
+	return c.start.CustomParameters
+}
+
+// OnDTMF sets the callback called when the caller presses a key
+func (c *Call) OnDTMF(callback func(digit string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onDTMF = callback
+}
+
+// Done is closed when the call ends
+func (c *Call) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Call) EncodingInfo() audio.EncodingInfo {
+	return c.encodingInfo
+}
+
+// Stream passes the caller's audio to onAudio until ctx is done or the call
+// ends
+func (c *Call) Stream(ctx context.Context, onAudio func(audio []byte)) error {
+	if err := c.StartCapture(ctx, onAudio); err != nil {
+		return err
+	}
+	defer c.StopCapture()
+
+	select {
+	case <-ctx.Done():
+	case <-c.done:
+	}
+	return nil
+}
+
+func (c *Call) StartCapture(_ context.Context, onAudio func(audio []byte)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return fmt.Errorf("call ended")
+	}
+
+	c.onAudio = onAudio
+	c.captureStarted = true
+	return nil
+}
+
+func (c *Call) StopCapture() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onAudio = nil
+	return nil
+}
+
+func (c *Call) SendAudio(audio []byte) error {
+	return c.write(Event{
+		Event:     EventTypeMedia,
+		StreamSID: c.start.StreamSID,
+		Media:     &MediaPayload{Payload: base64.StdEncoding.EncodeToString(audio)},
+	})
+}
+
+// ClearBuffer tells Twilio to drop the audio it didn't play yet, marks
+// waiting for it are dropped as well
+func (c *Call) ClearBuffer() {
+	c.mu.Lock()
+	c.marks = nil
+	c.mu.Unlock()
+
+	if err := c.write(Event{Event: EventTypeClear, StreamSID: c.start.StreamSID}); err != nil {
+		log.Printf("Failed to clear call audio: %v", err)
+	}
+}
+
+// Mark calls the callback once Twilio played all audio sent before it
+func (c *Call) Mark(name string, callback func(string)) error {
+	c.mu.Lock()
+	c.nextMarkID++
+	mark := callMark{id: strconv.Itoa(c.nextMarkID), name: name, callback: callback}
+	c.marks = append(c.marks, mark)
+	c.mu.Unlock()
+
+	return c.write(Event{
+		Event:     EventTypeMark,
+		StreamSID: c.start.StreamSID,
+		Mark:      &MarkPayload{Name: mark.id},
+	})
+}
+
+// Close closes the media stream, which ends the call unless the TwiML
+// continues after it. It is safe to call multiple times.
+func (c *Call) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	c.onAudio = nil
+	_ = c.ws.Close()
+}
+
+// readEvents handles Twilio's events until the stream stops
+func (c *Call) readEvents() {
+	defer close(c.done)
+	defer c.Close()
+
+	for {
+		var event Event
+		if err := c.ws.ReadJSON(&event); err != nil {
+			c.mu.Lock()
+			closed := c.closed
+			c.mu.Unlock()
+			if !closed && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("Failed to read media stream event: %v", err)
+			}
+			return
+		}
+
+		switch event.Event {
+		case EventTypeMedia:
+			if event.Media == nil {
+				continue
+			}
+			audio, err := base64.StdEncoding.DecodeString(event.Media.Payload)
+			if err != nil {
+				log.Printf("Failed to decode call audio: %v", err)
+				continue
+			}
+			c.passAudio(audio)
+
+		case EventTypeMark:
+			if event.Mark != nil {
+				c.markPlayed(event.Mark.Name)
+			}
+
+		case EventTypeDTMF:
+			c.mu.Lock()
+			onDTMF := c.onDTMF
+			c.mu.Unlock()
+			if onDTMF != nil && event.DTMF != nil {
+				onDTMF(event.DTMF.Digit)
+			}
+
+		case EventTypeStop:
+			return
+		}
+	}
+}
+
+// passAudio passes the caller's audio to the capture. Audio that arrives
+// before capture starts, e.g. while the orchestration is starting, is kept
+// and passed before the first audio after it.
+func (c *Call) passAudio(audio []byte) {
+	c.mu.Lock()
+	onAudio := c.onAudio
+	if !c.captureStarted {
+		c.pendingAudio = append(c.pendingAudio, audio)
+		c.pendingSize += len(audio)
+		maxSize := int(maxPendingAudio.Seconds() * float64(c.encodingInfo.SampleRate*c.encodingInfo.BytesPerFrame()))
+		for c.pendingSize > maxSize && len(c.pendingAudio) > 0 {
+			c.pendingSize -= len(c.pendingAudio[0])
+			c.pendingAudio = c.pendingAudio[1:]
+		}
+		c.mu.Unlock()
+		return
+	}
+	pending := c.pendingAudio
+	c.pendingAudio = nil
+	c.pendingSize = 0
+	c.mu.Unlock()
+
+	if onAudio == nil {
+		return
+	}
+	for _, audio := range pending {
+		onAudio(audio)
+	}
+	onAudio(audio)
+}
+
+// markPlayed calls the callbacks of the mark and the marks before it, Twilio
+// plays the audio in order. Marks Twilio sends back after a clear were
+// already dropped and are ignored.
+func (c *Call) markPlayed(id string) {
+	c.mu.Lock()
+	played := 0
+	for i, mark := range c.marks {
+		if mark.id == id {
+			played = i + 1
+			break
+		}
+	}
+	marks := c.marks[:played]
+	c.marks = c.marks[played:]
+	c.mu.Unlock()
+
+	for _, mark := range marks {
+		if mark.callback != nil {
+			mark.callback(mark.name)
+		}
+	}
+}
+
+func (c *Call) write(event Event) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.ws.WriteJSON(event); err != nil {
+		return fmt.Errorf("failed to send %s event: %w", event.Event, err)
+	}
+	return nil
+}
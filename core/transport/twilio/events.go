@@ -0,0 +1,82 @@
+package twilio
+
+// EventType is the type of a Twilio Media Streams message
+type EventType string
+
+const (
+	// Sent by Twilio
+
+	EventTypeConnected EventType = "connected"
+	EventTypeStart     EventType = "start"
+	EventTypeStop      EventType = "stop"
+	EventTypeDTMF      EventType = "dtmf"
+
+	// Sent by both, media carries the caller's audio from Twilio and the
+	// played audio to it. Marks sent to Twilio are sent back once the audio
+	// before them was played or cleared.
+
+	EventTypeMedia EventType = "media"
+	EventTypeMark  EventType = "mark"
+
+	// Sent to Twilio
+
+	// EventTypeClear drops the audio Twilio didn't play yet
+	EventTypeClear EventType = "clear"
+)
+
+// Event is a Twilio Media Streams message, only the payload of its type is
+// set
+type Event struct {
+	Event          EventType `json:"event"`
+	SequenceNumber string    `json:"sequenceNumber,omitempty"`
+	StreamSID      string    `json:"streamSid,omitempty"`
+
+	Protocol string `json:"protocol,omitempty"`
+	Version  string `json:"version,omitempty"`
+
+	Start *StartPayload `json:"start,omitempty"`
+	Media *MediaPayload `json:"media,omitempty"`
+	Mark  *MarkPayload  `json:"mark,omitempty"`
+	Stop  *StopPayload  `json:"stop,omitempty"`
+	DTMF  *DTMFPayload  `json:"dtmf,omitempty"`
+}
+
+type StartPayload struct {
+	AccountSID       string            `json:"accountSid"`
+	StreamSID        string            `json:"streamSid"`
+	CallSID          string            `json:"callSid"`
+	Tracks           []string          `json:"tracks"`
+	MediaFormat      MediaFormat       `json:"mediaFormat"`
+	CustomParameters map[string]string `json:"customParameters,omitempty"`
+}
+
+type MediaFormat struct {
+	Encoding   string `json:"encoding"`
+	SampleRate int    `json:"sampleRate"`
+	Channels   int    `json:"channels"`
+}
+
+// MediaEncodingMulaw is the only encoding Twilio streams in
+const MediaEncodingMulaw = "audio/x-mulaw"
+
+type MediaPayload struct {
+	Track     string `json:"track,omitempty"`
+	Chunk     string `json:"chunk,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	// Payload is the base64 encoded audio
+	Payload string `json:"payload"`
+}
+
+type MarkPayload struct {
+	Name string `json:"name"`
+}
+
+type StopPayload struct {
+	AccountSID string `json:"accountSid"`
+	CallSID    string `json:"callSid"`
+}
+
+type DTMFPayload struct {
+	Track string `json:"track"`
+	Digit string `json:"digit"`
+}
@@ -0,0 +1,219 @@
+// Package twilio makes EMA reachable by phone through Twilio Media Streams.
+// The server answers Twilio's voice webhook with TwiML that connects the call
+// to its media stream endpoint, which speaks the Media Streams protocol.
+//
+// Every call gets its own orchestrator. The caller's audio (mu-law at 8 kHz)
+// is the orchestrator's audio input and the assistant's speech is sent back
+// in the same encoding, marks and clearing the buffer map directly to
+// Twilio's mark and clear messages.
+//
+// Requests are validated with the X-Twilio-Signature header if the account's
+// auth token is set, the URLs are rebuilt from the requests so proxies in
+// front of the server have to keep the host and set X-Forwarded-Proto.
+package twilio
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SessionHandler runs the conversation of a single call. It should create an
+// orchestrator that uses the call as its audio input and output, run it with
+// call.Orchestrate and clean up once that returns.
+type SessionHandler func(ctx context.Context, call *Call) error
+
+// Server serves the TwiML webhook at "twiml" and the media stream at "media"
+type Server struct {
+	handler  SessionHandler
+	options  ServerOptions
+	upgrader websocket.Upgrader
+	mux      *http.ServeMux
+}
+
+type ServerOptions struct {
+	// StreamURL is the public WebSocket URL of the media stream endpoint.
+	// By default it is built from the webhook request's host, it has to be
+	// set if the server is mounted under a prefix.
+	StreamURL string
+	// StartTimeout is how long Twilio has to start the stream after
+	// connecting
+	StartTimeout time.Duration
+	// AuthToken is the Twilio account's auth token requests are validated
+	// with, requests aren't validated if it is empty
+	AuthToken string
+}
+
+type ServerOption func(*ServerOptions)
+
+// WithStreamURL sets the public WebSocket URL of the media stream endpoint
+// that is returned in the TwiML
+func WithStreamURL(url string) ServerOption {
+	return func(o *ServerOptions) {
+		o.StreamURL = url
+	}
+}
+
+// WithStartTimeout sets how long Twilio has to start the stream after
+// connecting
+func WithStartTimeout(timeout time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.StartTimeout = timeout
+	}
+}
+
+// WithAuthToken sets the Twilio account's auth token requests are validated
+// with, TWILIO_AUTH_TOKEN environment variable is used by default
+func WithAuthToken(authToken string) ServerOption {
+	return func(o *ServerOptions) {
+		o.AuthToken = authToken
+	}
+}
+
+const (
+	envVarAuthTokenName = "TWILIO_AUTH_TOKEN"
+
+	defaultStartTimeout = 10 * time.Second
+)
+
+func NewServer(handler SessionHandler, opts ...ServerOption) *Server {
+	options := ServerOptions{
+		StartTimeout: defaultStartTimeout,
+		AuthToken:    os.Getenv(envVarAuthTokenName),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.AuthToken == "" {
+		log.Println("Warning: Twilio auth token is not set, requests are not validated")
+	}
+
+	s := &Server{
+		handler: handler,
+		options: options,
+		upgrader: websocket.Upgrader{
+			// NOTE: Twilio doesn't send an Origin header, the requests are
+			// validated with their signature instead
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		mux: http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/twiml", s.serveTwiML)
+	s.mux.HandleFunc("GET /media", s.serveMediaStream)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type twiml struct {
+	XMLName xml.Name `xml:"Response"`
+	Stream  struct {
+		URL string `xml:"url,attr"`
+	} `xml:"Connect>Stream"`
+}
+
+// serveTwiML answers the voice webhook by connecting the call to the media
+// stream
+func (s *Server) serveTwiML(w http.ResponseWriter, r *http.Request) {
+	if !s.validSignature(r, requestURL(r, "http", "https")) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	response := twiml{}
+	response.Stream.URL = s.options.StreamURL
+	if response.Stream.URL == "" {
+		response.Stream.URL = fmt.Sprintf("wss://%s/media", r.Host)
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return
+	}
+	if err := xml.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to write TwiML: %v", err)
+	}
+}
+
+func (s *Server) serveMediaStream(w http.ResponseWriter, r *http.Request) {
+	// NOTE: Twilio signs the stream URL from the TwiML
+	streamURL := s.options.StreamURL
+	if streamURL == "" {
+		streamURL = requestURL(r, "ws", "wss")
+	}
+	if !s.validSignature(r, streamURL) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade media stream connection: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	start, err := readStart(ws, s.options.StartTimeout)
+	if err != nil {
+		log.Printf("Failed to start media stream: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	call, err := newCall(ws, start)
+	if err != nil {
+		log.Printf("Failed to start media stream: %v", err)
+		return
+	}
+	go func() {
+		call.readEvents()
+		cancel()
+	}()
+
+	if err := s.handler(ctx, call); err != nil {
+		log.Printf("Failed to run call session: %v", err)
+	}
+	call.Close()
+}
+
+// readStart waits for the start event, skipping the connected event before
+// it
+func readStart(ws *websocket.Conn, timeout time.Duration) (*StartPayload, error) {
+	if err := ws.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	defer ws.SetReadDeadline(time.Time{})
+
+	for {
+		var event Event
+		if err := ws.ReadJSON(&event); err != nil {
+			return nil, fmt.Errorf("failed to read start event: %w", err)
+		}
+
+		switch event.Event {
+		case EventTypeConnected:
+			continue
+		case EventTypeStart:
+			if event.Start == nil {
+				return nil, fmt.Errorf("start event without payload")
+			}
+			if event.Start.StreamSID == "" {
+				event.Start.StreamSID = event.StreamSID
+			}
+			return event.Start, nil
+		default:
+			return nil, fmt.Errorf("unexpected %s event before start", event.Event)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package twilio_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	orchestration "github.com/koscakluka/ema-core/core"
+	"github.com/koscakluka/ema-core/core/llms"
+	"github.com/koscakluka/ema-core/core/speechtotext"
+	"github.com/koscakluka/ema-core/core/texttospeech"
+	"github.com/koscakluka/ema-core/core/transport/twilio"
+	"github.com/koscakluka/ema-core/core/transport/twilio/twiliotest"
+)
+
+const authToken = "test-auth-token"
+
+// fakeSpeechToText transcribes "hello" once it received enough audio
+type fakeSpeechToText struct {
+	options  speechtotext.TranscriptionOptions
+	received int
+	mu       sync.Mutex
+}
+
+func (s *fakeSpeechToText) Transcribe(ctx context.Context, opts ...speechtotext.TranscriptionOption) error {
+	for _, opt := range opts {
+		opt(&s.options)
+	}
+	return nil
+}
+
+func (s *fakeSpeechToText) SendAudio(audio []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := s.received
+	s.received += len(audio)
+	if before < 1600 && s.received >= 1600 {
+		go s.options.TranscriptionCallback("hello")
+	}
+	return nil
+}
+
+type fakeLLM struct{}
+
+func (fakeLLM) PromptWithStream(ctx context.Context, prompt *string, opts ...llms.StreamingPromptOption) llms.Stream {
+	return fakeStream{}
+}
+
+type fakeStream struct{}
+
+func (fakeStream) Chunks(yield func(llms.StreamChunk, error) bool) {
+	yield(contentChunk("Hi there."), nil)
+}
+
+type contentChunk string
+
+func (c contentChunk) FinishReason() *string { return nil }
+func (c contentChunk) Content() string       { return string(c) }
+
+// fakeTextToSpeech speaks every flushed text as 200ms of silence
+type fakeTextToSpeech struct {
+	options texttospeech.TextToSpeechOptions
+	text    strings.Builder
+	mu      sync.Mutex
+}
+
+func (t *fakeTextToSpeech) OpenStream(ctx context.Context, opts ...texttospeech.TextToSpeechOption) error {
+	for _, opt := range opts {
+		opt(&t.options)
+	}
+	return nil
+}
+
+func (t *fakeTextToSpeech) SendText(text string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.text.WriteString(text)
+	return nil
+}
+
+func (t *fakeTextToSpeech) FlushBuffer() error {
+	t.mu.Lock()
+	text := t.text.String()
+	t.text.Reset()
+	t.mu.Unlock()
+
+	if text == "" {
+		return nil
+	}
+	encodingInfo := t.options.EncodingInfo
+	silence := make([]byte, encodingInfo.SampleRate/5*encodingInfo.BytesPerFrame())
+	if encodingInfo.Encoding == "mulaw" {
+		silence = bytes.Repeat([]byte{0xff}, len(silence))
+	}
+	t.options.AudioCallback(silence)
+	t.options.AudioEnded(text)
+	return nil
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := twilio.NewServer(func(ctx context.Context, call *twilio.Call) error {
+		orchestrator := orchestration.NewOrchestrator(
+			orchestration.WithStreamingLLM(fakeLLM{}),
+			orchestration.WithSpeechToTextClient(&fakeSpeechToText{}),
+			orchestration.WithTextToSpeechClient(&fakeTextToSpeech{}),
+			orchestration.WithAudioInput(call),
+			orchestration.WithAudioOutputV1(call),
+		)
+		defer orchestrator.Close()
+		return call.Orchestrate(ctx, orchestrator)
+	}, twilio.WithAuthToken(authToken))
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return httpServer
+}
+
+func TestCall(t *testing.T) {
+	httpServer := newTestServer(t)
+	streamURL := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/media"
+
+	received := make(chan int, 100)
+	client, err := twiliotest.Dial(context.Background(), streamURL,
+		twiliotest.WithAuthToken(authToken),
+		twiliotest.WithInstantPlayback(),
+		twiliotest.WithAudioCallback(func(audio []byte) { received <- len(audio) }),
+	)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer client.Hangup()
+
+	// NOTE: Twilio streams 20ms chunks of audio for the whole call, the
+	// audio is sent until the assistant answers so it doesn't depend on when
+	// the orchestrator starts capturing
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case size := <-received:
+			if size == 0 {
+				t.Error("received empty audio")
+			}
+			return
+		case <-ticker.C:
+			if err := client.SendAudio(bytes.Repeat([]byte{0xff}, 160)); err != nil {
+				t.Fatalf("SendAudio() error = %v", err)
+			}
+		case <-timeout:
+			t.Fatal("no audio received from the assistant")
+		}
+	}
+}
+
+func TestMediaStreamRejectsUnsignedConnections(t *testing.T) {
+	httpServer := newTestServer(t)
+	streamURL := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/media"
+
+	if _, err := twiliotest.Dial(context.Background(), streamURL); err == nil {
+		t.Fatal("Dial() without signature succeeded")
+	}
+	if _, err := twiliotest.Dial(context.Background(), streamURL, twiliotest.WithAuthToken("wrong")); err == nil {
+		t.Fatal("Dial() with a wrong signature succeeded")
+	}
+}
+
+func TestTwiMLSignature(t *testing.T) {
+	httpServer := newTestServer(t)
+	params := url.Values{"CallSid": {"CAtest"}, "From": {"+15550100"}}
+
+	tests := []struct {
+		name      string
+		signature string
+		want      int
+	}{
+		{"valid", twilio.Signature(authToken, httpServer.URL+"/twiml", params), http.StatusOK},
+		{"missing", "", http.StatusForbidden},
+		{"wrong token", twilio.Signature("wrong", httpServer.URL+"/twiml", params), http.StatusForbidden},
+		{"wrong url", twilio.Signature(authToken, httpServer.URL+"/other", params), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodPost, httpServer.URL+"/twiml", strings.NewReader(params.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			request.Header.Set(twilio.SignatureHeader, tt.signature)
+
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if response.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", response.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignature(t *testing.T) {
+	// NOTE: Example from Twilio's webhook security documentation
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	got := twilio.Signature("12345", "https://mycompany.com/myapp.php?foo=1&bar=2", params)
+	if want := "0/KCTR6DLpKmkAf8muzZqo1nDgQ="; got != want {
+		t.Errorf("Signature() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package twilio
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// SignatureHeader is the header Twilio signs its requests with
+const SignatureHeader = "X-Twilio-Signature"
+
+// Signature computes the signature Twilio sends with a request to the URL
+// with the POST parameters, signed with the account's auth token
+func Signature(authToken, requestURL string, params url.Values) string {
+	var data strings.Builder
+	data.WriteString(requestURL)
+	for _, key := range slices.Sorted(func(yield func(string) bool) {
+		for key := range params {
+			if !yield(key) {
+				return
+			}
+		}
+	}) {
+		values := slices.Clone(params[key])
+		slices.Sort(values)
+		for _, value := range values {
+			data.WriteString(key)
+			data.WriteString(value)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(data.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validSignature checks the request's signature against the URL Twilio
+// requested, every request is valid if the auth token isn't set
+func (s *Server) validSignature(r *http.Request, requestURL string) bool {
+	if s.options.AuthToken == "" {
+		return true
+	}
+
+	var params url.Values
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			return false
+		}
+		params = r.PostForm
+	}
+
+	expected := Signature(s.options.AuthToken, requestURL, params)
+	return hmac.Equal([]byte(expected), []byte(r.Header.Get(SignatureHeader)))
+}
+
+// requestURL rebuilds the URL Twilio requested, the secure scheme is used if
+// the request came over TLS directly or through a proxy
+func requestURL(r *http.Request, scheme, secureScheme string) string {
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = secureScheme
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
@@ -0,0 +1,333 @@
+// Package twiliotest provides a fake Twilio Media Streams client, so the
+// phone bridge can be tested offline. It plays the part of Twilio: it starts
+// a stream, sends the caller's audio and "plays" the received audio in real
+// time, sending marks back once the audio before them was played.
+package twiliotest
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/koscakluka/ema-core/core/transport/twilio"
+)
+
+// sampleRate is the sample rate of Twilio's mu-law audio
+const sampleRate = 8000
+
+type Client struct {
+	ws      *websocket.Conn
+	options Options
+
+	sequenceNumber int
+	chunk          int
+	sentSamples    int
+
+	// playbackEnd is when the received audio finishes playing
+	playbackEnd time.Time
+	markTimers  map[*time.Timer]string
+
+	done    chan struct{}
+	mu      sync.Mutex
+	writeMu sync.Mutex
+}
+
+type Options struct {
+	CallSID          string
+	StreamSID        string
+	CustomParameters map[string]string
+	// AuthToken signs the connection the way Twilio does, the server
+	// rejects unsigned connections if it has the auth token set
+	AuthToken string
+	// InstantPlayback sends marks back as soon as they are received instead
+	// of when the audio before them would have finished playing
+	InstantPlayback bool
+	// OnAudio is called with the received mu-law audio
+	OnAudio func(audio []byte)
+	// OnMark is called when a mark is received
+	OnMark func(name string)
+	// OnClear is called when the server clears the audio
+	OnClear func()
+}
+
+type Option func(*Options)
+
+func WithCallSID(callSID string) Option {
+	return func(o *Options) {
+		o.CallSID = callSID
+	}
+}
+
+func WithCustomParameters(parameters map[string]string) Option {
+	return func(o *Options) {
+		o.CustomParameters = parameters
+	}
+}
+
+// WithAuthToken signs the connection with the auth token
+func WithAuthToken(authToken string) Option {
+	return func(o *Options) {
+		o.AuthToken = authToken
+	}
+}
+
+// WithInstantPlayback sends marks back as soon as they are received
+func WithInstantPlayback() Option {
+	return func(o *Options) {
+		o.InstantPlayback = true
+	}
+}
+
+// WithAudioCallback sets the callback called with the received mu-law audio
+func WithAudioCallback(callback func(audio []byte)) Option {
+	return func(o *Options) {
+		o.OnAudio = callback
+	}
+}
+
+// WithMarkCallback sets the callback called when a mark is received
+func WithMarkCallback(callback func(name string)) Option {
+	return func(o *Options) {
+		o.OnMark = callback
+	}
+}
+
+// WithClearCallback sets the callback called when the server clears the
+// audio
+func WithClearCallback(callback func()) Option {
+	return func(o *Options) {
+		o.OnClear = callback
+	}
+}
+
+// Dial connects to the media stream endpoint and starts the stream the way
+// Twilio does
+func Dial(ctx context.Context, url string, opts ...Option) (*Client, error) {
+	options := Options{
+		CallSID:   "CAtest",
+		StreamSID: "MZtest",
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	header := http.Header{}
+	if options.AuthToken != "" {
+		header.Set(twilio.SignatureHeader, twilio.Signature(options.AuthToken, url, nil))
+	}
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to media stream: %w", err)
+	}
+
+	c := &Client{
+		ws:         ws,
+		options:    options,
+		markTimers: map[*time.Timer]string{},
+		done:       make(chan struct{}),
+	}
+
+	if err := c.write(twilio.Event{Event: twilio.EventTypeConnected, Protocol: "Call", Version: "1.0.0"}); err != nil {
+		ws.Close()
+		return nil, err
+	}
+	if err := c.write(twilio.Event{
+		Event:     twilio.EventTypeStart,
+		StreamSID: options.StreamSID,
+		Start: &twilio.StartPayload{
+			AccountSID: "ACtest",
+			StreamSID:  options.StreamSID,
+			CallSID:    options.CallSID,
+			Tracks:     []string{"inbound"},
+			MediaFormat: twilio.MediaFormat{
+				Encoding:   twilio.MediaEncodingMulaw,
+				SampleRate: sampleRate,
+				Channels:   1,
+			},
+			CustomParameters: options.CustomParameters,
+		},
+	}); err != nil {
+		ws.Close()
+		return nil, err
+	}
+
+	go c.readEvents()
+	return c, nil
+}
+
+// SendAudio sends the caller's mu-law audio, it should be sent in real time
+// like Twilio does, usually in 20ms chunks
+func (c *Client) SendAudio(audio []byte) error {
+	c.mu.Lock()
+	c.chunk++
+	chunk := c.chunk
+	timestamp := c.sentSamples * 1000 / sampleRate
+	c.sentSamples += len(audio)
+	c.mu.Unlock()
+
+	return c.write(twilio.Event{
+		Event:     twilio.EventTypeMedia,
+		StreamSID: c.options.StreamSID,
+		Media: &twilio.MediaPayload{
+			Track:     "inbound",
+			Chunk:     strconv.Itoa(chunk),
+			Timestamp: strconv.Itoa(timestamp),
+			Payload:   base64.StdEncoding.EncodeToString(audio),
+		},
+	})
+}
+
+// SendDTMF sends a key press of the caller
+func (c *Client) SendDTMF(digit string) error {
+	return c.write(twilio.Event{
+		Event:     twilio.EventTypeDTMF,
+		StreamSID: c.options.StreamSID,
+		DTMF:      &twilio.DTMFPayload{Track: "inbound_track", Digit: digit},
+	})
+}
+
+// Hangup stops the stream and closes the connection
+func (c *Client) Hangup() error {
+	err := c.write(twilio.Event{
+		Event:     twilio.EventTypeStop,
+		StreamSID: c.options.StreamSID,
+		Stop:      &twilio.StopPayload{AccountSID: "ACtest", CallSID: c.options.CallSID},
+	})
+	c.ws.Close()
+	<-c.done
+	return err
+}
+
+// Done is closed when the connection closes
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) readEvents() {
+	defer close(c.done)
+	defer c.stopMarkTimers()
+
+	for {
+		var event twilio.Event
+		if err := c.ws.ReadJSON(&event); err != nil {
+			return
+		}
+
+		switch event.Event {
+		case twilio.EventTypeMedia:
+			if event.Media == nil {
+				continue
+			}
+			audio, err := base64.StdEncoding.DecodeString(event.Media.Payload)
+			if err != nil {
+				continue
+			}
+			c.play(audio)
+
+		case twilio.EventTypeMark:
+			if event.Mark != nil {
+				c.mark(event.Mark.Name)
+			}
+
+		case twilio.EventTypeClear:
+			c.clear()
+		}
+	}
+}
+
+func (c *Client) play(audio []byte) {
+	c.mu.Lock()
+	duration := time.Duration(len(audio)) * time.Second / sampleRate
+	c.playbackEnd = later(c.playbackEnd, time.Now()).Add(duration)
+	c.mu.Unlock()
+
+	if c.options.OnAudio != nil {
+		c.options.OnAudio(audio)
+	}
+}
+
+// mark sends the mark back once the audio received before it was played
+func (c *Client) mark(name string) {
+	if c.options.OnMark != nil {
+		c.options.OnMark(name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.options.InstantPlayback {
+		go c.sendMark(name)
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(c.playbackEnd), func() {
+		c.mu.Lock()
+		_, pending := c.markTimers[timer]
+		delete(c.markTimers, timer)
+		c.mu.Unlock()
+		if pending {
+			c.sendMark(name)
+		}
+	})
+	c.markTimers[timer] = name
+}
+
+// clear drops the unplayed audio, like Twilio the pending marks are sent back
+// right away
+func (c *Client) clear() {
+	if c.options.OnClear != nil {
+		c.options.OnClear()
+	}
+
+	names := c.stopMarkTimers()
+	for _, name := range names {
+		c.sendMark(name)
+	}
+}
+
+func (c *Client) stopMarkTimers() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.markTimers))
+	for timer, name := range c.markTimers {
+		timer.Stop()
+		names = append(names, name)
+	}
+	c.markTimers = map[*time.Timer]string{}
+	c.playbackEnd = time.Time{}
+	return names
+}
+
+func (c *Client) sendMark(name string) {
+	_ = c.write(twilio.Event{
+		Event:     twilio.EventTypeMark,
+		StreamSID: c.options.StreamSID,
+		Mark:      &twilio.MarkPayload{Name: name},
+	})
+}
+
+func (c *Client) write(event twilio.Event) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.sequenceNumber++
+	event.SequenceNumber = strconv.Itoa(c.sequenceNumber)
+	if err := c.ws.WriteJSON(event); err != nil {
+		return fmt.Errorf("failed to send %s event: %w", event.Event, err)
+	}
+	return nil
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
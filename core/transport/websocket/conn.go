@@ -0,0 +1,307 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	orchestration "github.com/koscakluka/ema-core/core"
+	"github.com/koscakluka/ema-core/core/audio"
+)
+
+// Conn is a browser connection. It is the orchestrator's audio input
+// (AudioInput and AudioInputFine) and output (AudioOutputV1), marks are
+// acknowledged by the browser once the audio before them was played.
+type Conn struct {
+	ws           *websocket.Conn
+	encodingInfo audio.EncodingInfo
+
+	orchestrator *orchestration.Orchestrator
+	onAudio      func(audio []byte)
+	muted        bool
+	marks        []connMark
+	nextMarkID   int
+
+	closed  bool
+	done    chan struct{}
+	mu      sync.Mutex
+	writeMu sync.Mutex
+}
+
+type connMark struct {
+	id       int
+	name     string
+	callback func(string)
+}
+
+func newConn(ws *websocket.Conn, sampleRate int) *Conn {
+	return &Conn{
+		ws:           ws,
+		encodingInfo: audio.EncodingInfo{SampleRate: sampleRate, Encoding: audio.EncodingLinear16, Channels: 1},
+		done:         make(chan struct{}),
+	}
+}
+
+// Orchestrate starts the orchestration with callbacks that pass transcripts
+// and responses to the browser and handles the browser's controls until the
+// connection closes or ctx is done. Callbacks set in opts replace the ones
+// passing the same events to the browser. It returns the error if the
+// orchestration fails to start.
+func (c *Conn) Orchestrate(ctx context.Context, orchestrator *orchestration.Orchestrator, opts ...orchestration.OrchestrateOption) error {
+	c.mu.Lock()
+	c.orchestrator = orchestrator
+	c.mu.Unlock()
+
+	if err := orchestrator.Orchestrate(ctx, append(c.orchestrateOptions(), opts...)...); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-c.done:
+	}
+	return nil
+}
+
+func (c *Conn) orchestrateOptions() []orchestration.OrchestrateOption {
+	return []orchestration.OrchestrateOption{
+		orchestration.WithTranscriptionCallback(func(transcript string) {
+			c.send(message{Type: messageTypeTranscript, Text: transcript})
+		}),
+		orchestration.WithInterimTranscriptionCallback(func(transcript string) {
+			c.send(message{Type: messageTypeInterimTranscript, Text: transcript})
+		}),
+		orchestration.WithSpeakingStateChangedCallback(func(isSpeaking bool) {
+			c.send(message{Type: messageTypeSpeaking, Enabled: isSpeaking})
+		}),
+		orchestration.WithResponseCallback(func(response string) {
+			c.send(message{Type: messageTypeResponse, Text: response})
+		}),
+		orchestration.WithResponseEndCallback(func() {
+			c.send(message{Type: messageTypeResponseEnd})
+		}),
+		orchestration.WithCancellationCallback(func() {
+			c.send(message{Type: messageTypeCancelled})
+		}),
+	}
+}
+
+// Done is closed when the browser disconnects
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Conn) EncodingInfo() audio.EncodingInfo {
+	return c.encodingInfo
+}
+
+// Stream passes the microphone audio to onAudio until ctx is done or the
+// connection closes
+func (c *Conn) Stream(ctx context.Context, onAudio func(audio []byte)) error {
+	if err := c.StartCapture(ctx, onAudio); err != nil {
+		return err
+	}
+	defer c.StopCapture()
+
+	select {
+	case <-ctx.Done():
+	case <-c.done:
+	}
+	return nil
+}
+
+func (c *Conn) StartCapture(_ context.Context, onAudio func(audio []byte)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return fmt.Errorf("connection closed")
+	}
+
+	c.onAudio = onAudio
+	return nil
+}
+
+func (c *Conn) StopCapture() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onAudio = nil
+	return nil
+}
+
+func (c *Conn) SendAudio(audio []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.ws.WriteMessage(websocket.BinaryMessage, audio); err != nil {
+		return fmt.Errorf("failed to send audio: %w", err)
+	}
+	return nil
+}
+
+// ClearBuffer tells the browser to drop the audio it didn't play yet, marks
+// waiting for it are dropped as well
+func (c *Conn) ClearBuffer() {
+	c.mu.Lock()
+	c.marks = nil
+	c.mu.Unlock()
+
+	c.send(message{Type: messageTypeClear})
+}
+
+// Mark calls the callback once the browser played all audio sent before it
+func (c *Conn) Mark(name string, callback func(string)) error {
+	c.mu.Lock()
+	c.nextMarkID++
+	mark := connMark{id: c.nextMarkID, name: name, callback: callback}
+	c.marks = append(c.marks, mark)
+	c.mu.Unlock()
+
+	return c.write(message{Type: messageTypeMark, ID: mark.id})
+}
+
+// Close closes the connection, it is safe to call multiple times
+func (c *Conn) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	c.onAudio = nil
+	_ = c.ws.Close()
+}
+
+// readMessages handles the browser's messages until the connection closes
+func (c *Conn) readMessages() {
+	defer close(c.done)
+	defer c.Close()
+
+	for {
+		messageType, data, err := c.ws.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+				c.mu.Lock()
+				closed := c.closed
+				c.mu.Unlock()
+				if !closed {
+					log.Printf("Failed to read websocket message: %v", err)
+				}
+			}
+			return
+		}
+
+		switch messageType {
+		case websocket.BinaryMessage:
+			c.mu.Lock()
+			onAudio := c.onAudio
+			if c.muted {
+				onAudio = nil
+			}
+			c.mu.Unlock()
+			if onAudio != nil {
+				onAudio(data)
+			}
+
+		case websocket.TextMessage:
+			var msg message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				log.Printf("Failed to parse websocket message: %v", err)
+				continue
+			}
+			c.handleMessage(msg)
+		}
+	}
+}
+
+func (c *Conn) handleMessage(msg message) {
+	c.mu.Lock()
+	orchestrator := c.orchestrator
+	c.mu.Unlock()
+
+	switch msg.Type {
+	case messageTypeMarkPlayed:
+		c.markPlayed(msg.ID)
+
+	case messageTypeMute:
+		c.mu.Lock()
+		c.muted = msg.Enabled
+		c.mu.Unlock()
+
+	case messageTypeCancel:
+		if orchestrator != nil {
+			orchestrator.CancelTurn()
+		}
+
+	case messageTypePushToTalk:
+		if orchestrator == nil {
+			return
+		}
+		if orchestrator.IsAlwaysRecording() {
+			orchestrator.SetAlwaysRecording(false)
+		}
+		var err error
+		if msg.Enabled {
+			err = orchestrator.StartRecording()
+		} else {
+			err = orchestrator.StopRecording()
+		}
+		if err != nil {
+			log.Printf("Failed to toggle recording: %v", err)
+		}
+
+	case messageTypeHandsFree:
+		if orchestrator != nil {
+			orchestrator.SetAlwaysRecording(msg.Enabled)
+		}
+
+	case messageTypePrompt:
+		if orchestrator != nil && msg.Text != "" {
+			go orchestrator.SendPrompt(msg.Text)
+		}
+
+	default:
+		log.Printf("Warning: unknown websocket message type %q", msg.Type)
+	}
+}
+
+// markPlayed calls the callbacks of the acknowledged mark and the marks
+// before it, the browser plays the audio in order
+func (c *Conn) markPlayed(id int) {
+	c.mu.Lock()
+	played := 0
+	for played < len(c.marks) && c.marks[played].id <= id {
+		played++
+	}
+	marks := c.marks[:played]
+	c.marks = c.marks[played:]
+	c.mu.Unlock()
+
+	for _, mark := range marks {
+		if mark.callback != nil {
+			mark.callback(mark.name)
+		}
+	}
+}
+
+// send writes the message, failures are only logged since the read loop
+// notices a broken connection
+func (c *Conn) send(msg message) {
+	if err := c.write(msg); err != nil {
+		log.Printf("Failed to send websocket message: %v", err)
+	}
+}
+
+func (c *Conn) write(msg message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.ws.WriteJSON(msg); err != nil {
+		return fmt.Errorf("failed to send %s message: %w", msg.Type, err)
+	}
+	return nil
+}
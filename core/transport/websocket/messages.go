@@ -0,0 +1,50 @@
+package websocket
+
+// messageType is the type of a JSON control message, audio is sent in binary
+// messages
+type messageType string
+
+const (
+	// Sent by the browser
+
+	// messageTypeHello is the first message of a connection, it carries the
+	// sample rate of the browser's audio
+	messageTypeHello messageType = "hello"
+	// messageTypeMarkPlayed acknowledges that the audio before a mark was
+	// played
+	messageTypeMarkPlayed messageType = "mark_played"
+	// messageTypeCancel cancels the active turn
+	messageTypeCancel messageType = "cancel"
+	// messageTypeMute stops (or restarts) passing the microphone audio
+	messageTypeMute messageType = "mute"
+	// messageTypePushToTalk starts or stops recording, it turns off hands
+	// free recording
+	messageTypePushToTalk messageType = "push_to_talk"
+	// messageTypeHandsFree turns recording all the time on or off
+	messageTypeHandsFree messageType = "hands_free"
+	// messageTypePrompt sends a typed prompt
+	messageTypePrompt messageType = "prompt"
+
+	// Sent by the server
+
+	messageTypeTranscript        messageType = "transcript"
+	messageTypeInterimTranscript messageType = "interim_transcript"
+	messageTypeSpeaking          messageType = "speaking"
+	messageTypeResponse          messageType = "response"
+	messageTypeResponseEnd       messageType = "response_end"
+	messageTypeCancelled         messageType = "cancelled"
+	// messageTypeMark asks the browser to acknowledge once all audio sent
+	// before it was played
+	messageTypeMark messageType = "mark"
+	// messageTypeClear drops the audio that wasn't played yet
+	messageTypeClear messageType = "clear"
+)
+
+// message is a JSON control message, only the fields of its type are set
+type message struct {
+	Type       messageType `json:"type"`
+	Text       string      `json:"text,omitempty"`
+	ID         int         `json:"id,omitempty"`
+	SampleRate int         `json:"sampleRate,omitempty"`
+	Enabled    bool        `json:"enabled,omitempty"`
+}
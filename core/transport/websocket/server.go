@@ -0,0 +1,142 @@
+// Package websocket lets browsers talk to EMA over a WebSocket. The server
+// serves a page that captures the microphone and plays the assistant's
+// speech, and a WebSocket endpoint the page connects to.
+//
+// Every connection gets its own orchestrator. The browser's microphone audio
+// is sent as binary messages of linear16 mono PCM at the sample rate it
+// announces in its first ("hello") message, and the assistant's speech is
+// sent back in the same encoding. Transcripts, responses and controls (cancel,
+// mute, push to talk, typed prompts) are JSON text messages.
+//
+// Browsers only allow microphone access on secure origins, so to use it from
+// a phone serve it over HTTPS (or through a tunnel that provides it).
+package websocket
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//go:embed static
+var static embed.FS
+
+// SessionHandler runs the conversation of a single connection. It should
+// create an orchestrator that uses the connection as its audio input and
+// output, run it with conn.Orchestrate and clean up once that returns.
+type SessionHandler func(ctx context.Context, conn *Conn) error
+
+// Server serves the page at its root and the WebSocket endpoint at "ws", it
+// can be mounted under a prefix with http.StripPrefix
+type Server struct {
+	handler  SessionHandler
+	options  ServerOptions
+	upgrader websocket.Upgrader
+	mux      *http.ServeMux
+}
+
+type ServerOptions struct {
+	// CheckOrigin decides whether a connection from another origin is
+	// accepted, by default only the page's own origin is
+	CheckOrigin func(r *http.Request) bool
+	// HelloTimeout is how long the browser has to announce its audio format
+	// after connecting
+	HelloTimeout time.Duration
+}
+
+type ServerOption func(*ServerOptions)
+
+// WithCheckOrigin sets the function that decides whether a connection from
+// another origin is accepted
+func WithCheckOrigin(checkOrigin func(r *http.Request) bool) ServerOption {
+	return func(o *ServerOptions) {
+		o.CheckOrigin = checkOrigin
+	}
+}
+
+// WithHelloTimeout sets how long the browser has to announce its audio format
+// after connecting
+func WithHelloTimeout(timeout time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.HelloTimeout = timeout
+	}
+}
+
+const defaultHelloTimeout = 10 * time.Second
+
+func NewServer(handler SessionHandler, opts ...ServerOption) *Server {
+	options := ServerOptions{HelloTimeout: defaultHelloTimeout}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	s := &Server{
+		handler: handler,
+		options: options,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: options.CheckOrigin,
+		},
+		mux: http.NewServeMux(),
+	}
+
+	page, _ := fs.Sub(static, "static")
+	s.mux.Handle("GET /", http.FileServerFS(page))
+	s.mux.HandleFunc("GET /ws", s.serveWebSocket)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade websocket connection: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	sampleRate, err := readHello(ws, s.options.HelloTimeout)
+	if err != nil {
+		log.Printf("Failed to start websocket session: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	conn := newConn(ws, sampleRate)
+	go func() {
+		conn.readMessages()
+		cancel()
+	}()
+
+	if err := s.handler(ctx, conn); err != nil {
+		log.Printf("Failed to run websocket session: %v", err)
+	}
+	conn.Close()
+}
+
+// readHello waits for the browser to announce the sample rate of its audio
+func readHello(ws *websocket.Conn, timeout time.Duration) (int, error) {
+	if err := ws.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, err
+	}
+	defer ws.SetReadDeadline(time.Time{})
+
+	var hello message
+	if err := ws.ReadJSON(&hello); err != nil {
+		return 0, fmt.Errorf("failed to read hello message: %w", err)
+	}
+	if hello.Type != messageTypeHello || hello.SampleRate <= 0 {
+		return 0, fmt.Errorf("invalid hello message: type %q, sample rate %d", hello.Type, hello.SampleRate)
+	}
+	return hello.SampleRate, nil
+}
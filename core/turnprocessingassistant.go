@@ -8,10 +8,12 @@ import (
 	"log"
 
 	"github.com/koscakluka/ema-core/core/llms"
+	"github.com/koscakluka/ema-core/core/session"
 )
 
 func (o *Orchestrator) startAssistantLoop() {
-	for transcript := range o.transcripts {
+	for userTurn := range o.transcripts {
+		transcript := userTurn.SpeakerContent()
 		if o.turns.activeTurn() != nil {
 			o.promptEnded.Wait()
 		}
@@ -21,27 +23,29 @@ func (o *Orchestrator) startAssistantLoop() {
 		}
 		o.promptEnded.Add(1)
 
-		messages := o.turns
-		o.turns.Push(llms.Turn{
-			Role:    llms.TurnRoleUser,
-			Content: transcript,
-		})
+		messages := o.turns.Snapshot()
+		o.turns.Push(userTurn)
 
 		o.outputTextBuffer.Clear()
+		o.echoSuppression.startSpokenTurn()
 		o.outputAudioBuffer.Clear()
+		o.interruptionPause.clear()
 		go o.passTextToTTS()
 		go o.passSpeechToAudioOutput()
+		if o.orchestrateOptions.onAudio != nil {
+			go o.passSpeechToAudioCallback()
+		}
 
 		activeTurn.Stage = llms.TurnStageGeneratingResponse
 		o.turns.pushActiveTurn(*activeTurn)
 		var response *llms.Turn
 		switch o.llm.(type) {
 		case LLMWithStream:
-			response, _ = o.processStreaming(context.TODO(), transcript, messages.turns, &o.outputTextBuffer)
+			response, _ = o.processStreaming(context.TODO(), transcript, messages, o.outputTextBuffer)
 			// case LLMWithGeneralPrompt:
 			// TODO: Implement this
 		case LLMWithPrompt:
-			response, _ = o.processPromptOld(context.TODO(), transcript, messages.turns, &o.outputTextBuffer)
+			response, _ = o.processPromptOld(context.TODO(), transcript, messages, o.outputTextBuffer)
 		default:
 			// Impossible state
 			continue
@@ -49,20 +53,20 @@ func (o *Orchestrator) startAssistantLoop() {
 
 		o.outputTextBuffer.ChunksDone()
 		o.outputAudioBuffer.ChunksDone()
-		activeTurn = o.turns.activeTurn()
-		if activeTurn != nil && response != nil {
-			activeTurn.Role = response.Role
-			activeTurn.Content = response.Content
-			activeTurn.ToolCalls = response.ToolCalls
-		} else {
-			// TODO: Figure out how to handle this case
-		}
+		o.turns.updateActiveTurn(func(turn *llms.Turn) {
+			if response != nil {
+				turn.Role = response.Role
+				turn.Content = response.Content
+				turn.ToolCalls = response.ToolCalls
+			} else {
+				// TODO: Figure out how to handle this case
+			}
 
-		if activeTurn != nil && !activeTurn.Cancelled {
-			// NOTE: Just in case it wasn't set previously
-			activeTurn.Stage = llms.TurnStageSpeaking
-			o.turns.updateActiveTurn(*activeTurn)
-		}
+			if !turn.Cancelled {
+				// NOTE: Just in case it wasn't set previously
+				turn.Stage = llms.TurnStageSpeaking
+			}
+		})
 	}
 }
 
@@ -71,10 +75,14 @@ func (o *Orchestrator) processPromptOld(ctx context.Context, prompt string, mess
 		return nil, fmt.Errorf("LLM does not support prompting")
 	}
 
+	o.recordEvent(session.EventTypeLLMRequest, session.LLMRequestData{Prompt: prompt})
 	response, _ := o.llm.(LLMWithPrompt).Prompt(ctx, prompt,
 		llms.WithTurns(messages...),
 		llms.WithTools(o.tools...),
-		llms.WithStream(buffer.AddChunk),
+		llms.WithStream(func(chunk string) {
+			o.recordEvent(session.EventTypeLLMChunk, session.LLMChunkData{Content: chunk})
+			buffer.AddChunk(chunk)
+		}),
 	)
 
 	turns := llms.ToTurns(response)
@@ -105,6 +113,11 @@ func (o *Orchestrator) processStreaming(ctx context.Context, originalPrompt stri
 			turns = append(turns, assistantTurn)
 		}
 
+		requestData := session.LLMRequestData{}
+		if prompt != nil {
+			requestData.Prompt = *prompt
+		}
+		o.recordEvent(session.EventTypeLLMRequest, requestData)
 		stream := llm.PromptWithStream(context.TODO(), prompt,
 			llms.WithTurns(turns...),
 			llms.WithTools(o.tools...),
@@ -123,8 +136,7 @@ func (o *Orchestrator) processStreaming(ctx context.Context, originalPrompt stri
 				return nil, nil
 			}
 			if activeTurn != nil && activeTurn.Stage != llms.TurnStageSpeaking {
-				activeTurn.Stage = llms.TurnStageSpeaking
-				o.turns.updateActiveTurn(*activeTurn)
+				o.turns.updateActiveTurn(func(turn *llms.Turn) { turn.Stage = llms.TurnStageSpeaking })
 			}
 
 			switch chunk.(type) {
@@ -135,11 +147,14 @@ func (o *Orchestrator) processStreaming(ctx context.Context, originalPrompt stri
 			case llms.StreamContentChunk:
 				chunk := chunk.(llms.StreamContentChunk)
 
+				o.recordEvent(session.EventTypeLLMChunk, session.LLMChunkData{Content: chunk.Content()})
 				response.WriteString(chunk.Content())
 				buffer.AddChunk(chunk.Content())
 
 			case llms.StreamToolCallChunk:
-				toolCalls = append(toolCalls, chunk.(llms.StreamToolCallChunk).ToolCall())
+				toolCall := chunk.(llms.StreamToolCallChunk).ToolCall()
+				o.recordEvent(session.EventTypeLLMToolCall, session.LLMToolCallData{ToolCall: toolCall})
+				toolCalls = append(toolCalls, toolCall)
 			}
 		}
 
@@ -174,6 +189,13 @@ func (o *Orchestrator) callTool(_ context.Context, toolCall llms.ToolCall) (*llm
 			if err != nil {
 				log.Println("Error executing tool:", err)
 			}
+			if o.sessionRecorder != nil {
+				data := session.ToolCallData{Name: toolName, Arguments: toolArguments, Response: resp}
+				if err != nil {
+					data.Error = err.Error()
+				}
+				o.recordEvent(session.EventTypeToolCall, data)
+			}
 			return &llms.Turn{
 				ToolCallID: toolCall.ID,
 				Role:       llms.TurnRoleAssistant,
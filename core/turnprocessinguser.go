@@ -2,65 +2,160 @@ package orchestration
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/koscakluka/ema-core/core/llms"
+	"github.com/koscakluka/ema-core/core/session"
 	"github.com/koscakluka/ema-core/core/speechtotext"
 	"github.com/koscakluka/ema-core/internal/utils"
 )
 
-func (o *Orchestrator) initSST() {
+func (o *Orchestrator) initSST() error {
 	if o.speechToTextClient != nil {
-		sttOptions := []speechtotext.TranscriptionOption{
+		sttOptions := append([]speechtotext.TranscriptionOption{}, o.speechToTextOptions...)
+		if o.speakerFilter.enabled {
+			sttOptions = append(sttOptions, speechtotext.WithDiarization(true))
+		}
+		sttOptions = append(sttOptions,
 			speechtotext.WithSpeechStartedCallback(func() {
-				// TODO: Consider pausing on speech start
-				// maybe with some wait time for interim transcript
-				// or maybe pausing on interim transcript is enough
-				if o.orchestrateOptions.onSpeakingStateChanged != nil {
-					o.orchestrateOptions.onSpeakingStateChanged(true)
+				// NOTE: Local voice activity detection reports speech
+				// events sooner, so the provider's ones are ignored
+				if !o.voiceActivity.enabled {
+					o.onSpeechStarted()
 				}
 			}),
 			speechtotext.WithSpeechEndedCallback(func() {
-				if o.orchestrateOptions.onSpeakingStateChanged != nil {
-					o.orchestrateOptions.onSpeakingStateChanged(false)
+				if !o.voiceActivity.enabled {
+					o.onSpeechEnded()
 				}
 			}),
 			speechtotext.WithInterimTranscriptionCallback(func(transcript string) {
 				// TODO: Start generating interruption here already
 				// marking the ID will probably be required to keep track of it
+				// NOTE: With a primary speaker the pause is confirmed once the
+				// speaker is known from the interim transcription result
+				if transcript != "" && !o.speakerFilter.enabled && !o.echoSuppression.isEcho(transcript) {
+					o.confirmPauseForInterruption()
+				}
 
+				o.recordEvent(session.EventTypeInterimTranscript, session.TranscriptData{Transcript: transcript})
 				if o.orchestrateOptions.onInterimTranscription != nil {
 					o.orchestrateOptions.onInterimTranscription(transcript)
 				}
 			}),
+			speechtotext.WithInterimTranscriptionResultCallback(func(transcription speechtotext.Transcription) {
+				if o.speakerFilter.enabled && transcription.Transcript != "" &&
+					o.speakerFilter.accepts(transcription.Speaker) {
+					o.confirmPauseForInterruption()
+				}
+
+				if o.orchestrateOptions.onInterimTranscriptionResult != nil {
+					o.orchestrateOptions.onInterimTranscriptionResult(transcription)
+				}
+			}),
+			speechtotext.WithTranscriptionResultCallback(func(transcription speechtotext.Transcription) {
+				// NOTE: Providers call this before the transcription
+				// callback, which picks it up to attach it to the turn
+				o.lastTranscription.Store(&transcription)
+				if o.orchestrateOptions.onTranscriptionResult != nil {
+					o.orchestrateOptions.onTranscriptionResult(transcription)
+				}
+			}),
 			speechtotext.WithTranscriptionCallback(func(transcript string) {
+				// NOTE: Providers call the result callback right before this
+				// one for the same utterance, so the result is taken here
+				// even if the transcript is ignored, otherwise it would be
+				// attached to the next one
+				transcription := o.lastTranscription.Swap(nil)
+				if transcription != nil && strings.Join(strings.Fields(transcription.Transcript), " ") != strings.Join(strings.Fields(transcript), " ") {
+					log.Printf("Transcription result %q doesn't match the transcript %q", transcription.Transcript, transcript)
+				}
+				if o.sessionRecorder != nil {
+					o.recordEvent(session.EventTypeTranscript, session.TranscriptData{
+						Transcript:    transcript,
+						Transcription: transcription,
+					})
+				}
 				if o.orchestrateOptions.onInterimTranscription != nil {
 					o.orchestrateOptions.onInterimTranscription("")
 				}
 
-				o.SendPrompt(transcript)
+				if o.echoSuppression.isEcho(transcript) {
+					log.Printf("Ignoring transcript that matches the assistant's speech: %s", transcript)
+					return
+				}
+
+				speaker := ""
+				if transcription != nil {
+					speaker = transcription.Speaker
+				}
+				if !o.speakerFilter.accepts(speaker) {
+					log.Printf("Ignoring speech from speaker %q, not the primary speaker", speaker)
+					return
+				}
+				prompt, ok := o.filterWakeWord(transcript)
+				if !ok {
+					return
+				}
+				if prompt != transcript {
+					// NOTE: The word level transcription still includes
+					// the wake phrase
+					transcription = nil
+				}
+				o.processUserTurn(prompt, transcription)
 			}),
-		}
-		if o.audioInput != nil {
-			sttOptions = append(sttOptions, speechtotext.WithEncodingInfo(o.audioInput.EncodingInfo()))
+			speechtotext.WithConnectionStateCallback(func(state speechtotext.ConnectionState) {
+				o.speakerFilter.updateConnectionState(state)
+				if state != speechtotext.ConnectionStateConnected {
+					log.Printf("Speech to text connection %s", state)
+				}
+				if o.orchestrateOptions.onSpeechToTextConnectionStateChanged != nil {
+					o.orchestrateOptions.onSpeechToTextConnectionStateChanged(state)
+				}
+			}),
+		)
+		if encodingInfo, ok := o.negotiateInput(); ok {
+			sttOptions = append(sttOptions, speechtotext.WithEncodingInfo(encodingInfo))
 		}
 
 		if err := o.speechToTextClient.Transcribe(context.TODO(), sttOptions...); err != nil {
-			log.Fatalf("Failed to start transcribing: %v", err)
+			return fmt.Errorf("failed to start transcribing: %w", err)
 		}
 	}
+	return nil
+}
+
+func (o *Orchestrator) onSpeechStarted() {
+	o.recordEvent(session.EventTypeSpeechStarted, nil)
+	o.pauseForInterruption(true)
+	if o.orchestrateOptions.onSpeakingStateChanged != nil {
+		o.orchestrateOptions.onSpeakingStateChanged(true)
+	}
+}
+
+func (o *Orchestrator) onSpeechEnded() {
+	o.recordEvent(session.EventTypeSpeechEnded, nil)
+	o.resumeUnconfirmedInterruption()
+	if o.orchestrateOptions.onSpeakingStateChanged != nil {
+		o.orchestrateOptions.onSpeakingStateChanged(false)
+	}
 }
 
-func (o *Orchestrator) processUserTurn(prompt string) {
+func (o *Orchestrator) processUserTurn(prompt string, transcription *speechtotext.Transcription) {
 	var interruptionID *int64
 	if o.turns.activeTurn() != nil {
 		interruptionID = utils.Ptr(time.Now().UnixNano())
 		interruption := &llms.InterruptionV0{
-			ID:     *interruptionID,
-			Source: prompt,
+			ID:            *interruptionID,
+			Source:        prompt,
+			Transcription: transcription,
 		}
 		o.turns.addInterruption(*interruption)
+		o.interruptionPause.confirm()
+		defer o.recordInterruption(*interruptionID)
 	}
 
 	passthrough := &prompt
@@ -68,15 +163,19 @@ func (o *Orchestrator) processUserTurn(prompt string) {
 		if o.interruptionHandlerV1 != nil {
 			if interruption, err := o.interruptionHandlerV1.HandleV1(*interruptionID, o, o.tools); err != nil {
 				log.Printf("Failed to handle interruption: %v", err)
+				o.resumeAfterInterruption()
 			} else {
 				o.turns.updateInterruption(*interruptionID, func(update *llms.InterruptionV0) {
 					update.Type = interruption.Type
 					update.Resolved = interruption.Resolved
+					update.Confidence = interruption.Confidence
+					update.Rationale = interruption.Rationale
+					update.DecisionPath = interruption.DecisionPath
 				})
 				return
 			}
 		} else if o.interruptionHandlerV0 != nil {
-			if err := o.interruptionHandlerV0.HandleV0(prompt, o.turns.turns, o.tools, o); err != nil {
+			if err := o.interruptionHandlerV0.HandleV0(prompt, o.turns.Snapshot(), o.tools, o); err != nil {
 				log.Printf("Failed to handle interruption: %v", err)
 			} else {
 				o.turns.updateInterruption(*interruptionID, func(interruption *llms.InterruptionV0) {
@@ -85,7 +184,7 @@ func (o *Orchestrator) processUserTurn(prompt string) {
 				return
 			}
 		} else if o.interruptionClassifier != nil {
-			interruption, err := o.interruptionClassifier.Classify(prompt, llms.ToMessages(o.turns.turns), ClassifyWithTools(o.tools))
+			interruption, err := o.interruptionClassifier.Classify(prompt, llms.ToMessages(o.turns.Snapshot()), ClassifyWithTools(o.tools))
 			if err != nil {
 				// TODO: Retry?
 				log.Printf("Failed to classify interruption: %v", err)
@@ -100,15 +199,27 @@ func (o *Orchestrator) processUserTurn(prompt string) {
 		o.turns.updateInterruption(*interruptionID, func(interruption *llms.InterruptionV0) {
 			interruption.Resolved = true
 		})
+		// NOTE: V0 handlers and the classifier don't know about pausing, so
+		// output is continued unless the turn got cancelled
+		o.resumeAfterInterruption()
 	}
 	if passthrough != nil {
-		o.queuePrompt(*passthrough)
+		userTurn := llms.Turn{Role: llms.TurnRoleUser, Content: *passthrough}
+		if *passthrough == prompt && transcription != nil {
+			userTurn.Transcription = transcription
+			userTurn.Speaker = transcription.Speaker
+		}
+		o.queueUserTurn(userTurn)
 	}
 }
 
 func (o *Orchestrator) queuePrompt(prompt string) {
+	o.queueUserTurn(llms.Turn{Role: llms.TurnRoleUser, Content: prompt})
+}
+
+func (o *Orchestrator) queueUserTurn(turn llms.Turn) {
 	if o.orchestrateOptions.onTranscription != nil {
-		o.orchestrateOptions.onTranscription(prompt)
+		o.orchestrateOptions.onTranscription(turn.Content)
 	}
-	o.transcripts <- prompt
+	o.transcripts <- turn
 }
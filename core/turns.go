@@ -2,6 +2,7 @@ package orchestration
 
 import (
 	"slices"
+	"sync"
 
 	"github.com/koscakluka/ema-core/core/llms"
 )
@@ -16,15 +17,29 @@ type Turns struct {
 	// it is an int so that active turn can be correctly modified even if the
 	// underlying slice changes
 	activeTurnIdx int
+
+	// onStageChanged is called when the active turn's stage changes
+	onStageChanged func(turn llms.Turn)
+	reportedStage  llms.TurnStage
+
+	// NOTE: The turns are changed by the assistant loop while the user's
+	// turns and callers of Turns read them, so they are only read through
+	// snapshots. Snapshots share the interruptions, which are replaced
+	// instead of changed in place.
+	mu sync.Mutex
 }
 
 // Push adds a new turn to the stored turns
 func (t *Turns) Push(turn llms.Turn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.turns = append(t.turns, turn)
 }
 
 // Pop removes the last turn from the stored turns, returns nil if empty
 func (t *Turns) Pop() *llms.Turn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	if len(t.turns) == 0 {
 		return nil
 	}
@@ -39,26 +54,36 @@ func (t *Turns) Pop() *llms.Turn {
 
 // Clear removes all stored turns
 func (t *Turns) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.turns = nil
 	t.activeTurnIdx = -1
 }
 
-// Values is an iterator that goes over all the stored turns starting from the
-// earliest towards the latest
+// Snapshot returns a copy of the stored turns starting from the earliest
+// towards the latest
+func (t *Turns) Snapshot() []llms.Turn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.turns)
+}
+
+// Values is an iterator that goes over a snapshot of the stored turns starting
+// from the earliest towards the latest
 func (t *Turns) Values(yield func(llms.Turn) bool) {
-	for _, turn := range t.turns {
+	for _, turn := range t.Snapshot() {
 		if !yield(turn) {
 			return
 		}
 	}
 }
 
-// Values is an iterator that goes over all the stored turns starting from the
-// latest towards the earliest
+// RValues is an iterator that goes over a snapshot of the stored turns
+// starting from the latest towards the earliest
 func (t *Turns) RValues(yield func(llms.Turn) bool) {
 	// TODO: There should be a better way to do this than creating a new
 	// method just for reversing the order
-	for _, turn := range slices.Backward(t.turns) {
+	for _, turn := range slices.Backward(t.Snapshot()) {
 		if !yield(turn) {
 			return
 		}
@@ -66,44 +91,76 @@ func (t *Turns) RValues(yield func(llms.Turn) bool) {
 }
 
 func (t *Turns) pushActiveTurn(turn llms.Turn) {
+	t.mu.Lock()
 	t.activeTurnIdx = len(t.turns)
 	t.turns = append(t.turns, turn)
+	t.reportedStage = ""
+	report := t.stageToReport(turn)
+	t.mu.Unlock()
+
+	report()
 }
 
+// stageToReport marks the turn's stage as reported and returns the call to
+// onStageChanged if it wasn't reported yet, it is called without the lock.
+// NOTE: The active turn is modified on a copy before it is updated, so the
+// stage is compared to the last reported one instead.
+func (t *Turns) stageToReport(turn llms.Turn) func() {
+	if t.onStageChanged == nil || turn.Stage == t.reportedStage {
+		return func() {}
+	}
+	t.reportedStage = turn.Stage
+	return func() { t.onStageChanged(turn) }
+}
+
+// activeTurn returns a copy of the active turn, it is changed with
+// updateActiveTurn
 func (t *Turns) activeTurn() *llms.Turn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	if t.activeTurnIdx < 0 || t.activeTurnIdx >= len(t.turns) {
 		return nil
 	}
-	return &t.turns[t.activeTurnIdx]
+	turn := t.turns[t.activeTurnIdx]
+	return &turn
 }
 
-func (t *Turns) updateActiveTurn(turn llms.Turn) {
+// updateActiveTurn changes the active turn under the lock so concurrent
+// changes aren't lost, update isn't called if there is no active turn
+func (t *Turns) updateActiveTurn(update func(turn *llms.Turn)) {
+	t.mu.Lock()
 	if t.activeTurnIdx < 0 || t.activeTurnIdx >= len(t.turns) {
+		t.mu.Unlock()
 		return
 	}
 
+	turn := t.turns[t.activeTurnIdx]
+	update(&turn)
 	t.turns[t.activeTurnIdx] = turn
+	report := t.stageToReport(turn)
+	t.mu.Unlock()
+
+	report()
 }
 
 func (t *Turns) unsetActiveTurn() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.activeTurnIdx = -1
 }
 
 func (o *Orchestrator) finaliseActiveTurn() {
-	activeTurn := o.turns.activeTurn()
-	if activeTurn != nil {
-		activeTurn.Stage = llms.TurnStageFinalized
-		o.turns.updateActiveTurn(*activeTurn)
+	if activeTurn := o.turns.activeTurn(); activeTurn != nil {
+		o.turns.updateActiveTurn(func(turn *llms.Turn) { turn.Stage = llms.TurnStageFinalized })
 		o.turns.unsetActiveTurn()
+		o.wakeWord.extend()
 	}
 }
 
 func (t *Turns) addInterruption(interruption llms.InterruptionV0) {
-	activeTurn := t.activeTurn()
-	if activeTurn != nil {
-		activeTurn.Interruptions = append(activeTurn.Interruptions, interruption)
-		t.updateActiveTurn(*activeTurn)
-	}
+	t.updateActiveTurn(func(turn *llms.Turn) {
+		turn.Interruptions = append(slices.Clone(turn.Interruptions), interruption)
+	})
 }
 
 func (t *Turns) findInterruption(id int64) *llms.InterruptionV0 {
@@ -119,9 +176,12 @@ func (t *Turns) findInterruption(id int64) *llms.InterruptionV0 {
 }
 
 func (t *Turns) updateInterruption(id int64, update func(*llms.InterruptionV0)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	for i, turn := range slices.Backward(t.turns) {
 		for j, interruption := range turn.Interruptions {
 			if interruption.ID == id {
+				t.turns[i].Interruptions = slices.Clone(turn.Interruptions)
 				update(&t.turns[i].Interruptions[j])
 			}
 		}
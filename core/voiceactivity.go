@@ -0,0 +1,93 @@
+package orchestration
+
+import (
+	"sync"
+	"time"
+
+	"github.com/koscakluka/ema-core/core/audio/vad"
+)
+
+const (
+	// vadDefaultSampleRate is assumed for audio sent through SendAudio when
+	// there is no audio input to take the encoding from
+	vadDefaultSampleRate = 48000
+	// vadGatePreRoll is the audio sent to the speech to text client before
+	// the detected start of speech, so the first word isn't cut off
+	vadGatePreRoll = 300 * time.Millisecond
+)
+
+// voiceActivity detects speech locally in the audio sent to the speech to
+// text client
+type voiceActivity struct {
+	enabled bool
+	// gate only lets audio through to the speech to text client while speech
+	// is detected
+	gate    bool
+	options []vad.Option
+
+	detector *vad.Detector
+	preRoll  []byte
+	mu       sync.Mutex
+}
+
+// sendAudioThroughVAD runs voice activity detection on the audio, reports
+// speech start and end and sends the audio to the speech to text client,
+// unless it is gated off during silence
+func (o *Orchestrator) sendAudioThroughVAD(audio []byte) error {
+	o.voiceActivity.mu.Lock()
+	if o.voiceActivity.detector == nil {
+		sampleRate := vadDefaultSampleRate
+		if o.audioInput != nil {
+			sampleRate = o.audioInput.EncodingInfo().SampleRate
+		}
+		o.voiceActivity.detector = vad.NewDetector(sampleRate, o.voiceActivity.options...)
+	}
+
+	event := o.voiceActivity.detector.Process(audio)
+	send := [][]byte{audio}
+	if o.voiceActivity.gate {
+		send = o.gateAudio(audio, event)
+	}
+	o.voiceActivity.mu.Unlock()
+
+	switch event {
+	case vad.EventSpeechStarted:
+		o.onSpeechStarted()
+	case vad.EventSpeechEnded:
+		o.onSpeechEnded()
+	}
+
+	for _, chunk := range send {
+		if err := o.sendAudioToSpeechToText(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gateAudio returns the audio that should be sent to the speech to text
+// client, keeping the audio during silence as pre-roll. voiceActivity.mu has
+// to be held when calling it.
+func (o *Orchestrator) gateAudio(audio []byte, event vad.Event) [][]byte {
+	if event == vad.EventSpeechStarted {
+		send := [][]byte{o.voiceActivity.preRoll, audio}
+		o.voiceActivity.preRoll = nil
+		return send
+	}
+	// NOTE: The chunk that ended speech is sent as well, the speech to text
+	// client needs some silence to finalize the transcript
+	if o.voiceActivity.detector.IsSpeaking() || event == vad.EventSpeechEnded {
+		return [][]byte{audio}
+	}
+
+	sampleRate := vadDefaultSampleRate
+	if o.audioInput != nil {
+		sampleRate = o.audioInput.EncodingInfo().SampleRate
+	}
+	maxPreRoll := int(vadGatePreRoll.Seconds()*float64(sampleRate)) * 2
+	o.voiceActivity.preRoll = append(o.voiceActivity.preRoll, audio...)
+	if excess := len(o.voiceActivity.preRoll) - maxPreRoll; excess > 0 {
+		o.voiceActivity.preRoll = o.voiceActivity.preRoll[excess&^1:]
+	}
+	return nil
+}
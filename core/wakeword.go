@@ -0,0 +1,258 @@
+package orchestration
+
+import (
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/koscakluka/ema-core/core/audio/convert"
+)
+
+const defaultFollowUpWindow = 8 * time.Second
+
+// WakeWordDetector spots the wake phrase locally in the captured audio, which
+// activates the orchestrator before the transcript arrives. The audio is
+// linear16 mono in the input's sample rate.
+type WakeWordDetector interface {
+	DetectWakeWord(audio []byte) bool
+}
+
+type WakeWordOptions struct {
+	// Variants are other spellings of the wake phrase the speech to text
+	// client might produce, e.g. "hey emma" for "hey EMA"
+	Variants []string
+	// FollowUpWindow is how long after a response the user can carry on the
+	// conversation without the wake phrase
+	FollowUpWindow time.Duration
+	// Detector spots the wake phrase in the audio, without one it is only
+	// looked for in the transcripts
+	Detector WakeWordDetector
+	// DisableEarcon disables the sound played on activation
+	DisableEarcon bool
+}
+
+type WakeWordOption func(*WakeWordOptions)
+
+// WithWakePhraseVariants adds other spellings the wake phrase might be
+// transcribed as
+func WithWakePhraseVariants(variants ...string) WakeWordOption {
+	return func(o *WakeWordOptions) {
+		o.Variants = append(o.Variants, variants...)
+	}
+}
+
+// WithFollowUpWindow sets how long after a response the user can speak
+// without the wake phrase, 8 seconds by default
+func WithFollowUpWindow(window time.Duration) WakeWordOption {
+	return func(o *WakeWordOptions) {
+		o.FollowUpWindow = window
+	}
+}
+
+// WithWakeWordDetector spots the wake phrase locally in the audio
+func WithWakeWordDetector(detector WakeWordDetector) WakeWordOption {
+	return func(o *WakeWordOptions) {
+		o.Detector = detector
+	}
+}
+
+// WithoutEarcon disables the sound played when the wake phrase is heard
+func WithoutEarcon() WakeWordOption {
+	return func(o *WakeWordOptions) {
+		o.DisableEarcon = true
+	}
+}
+
+// wakeWord only lets transcripts through to the conversation after the wake
+// phrase was heard, and during the follow-up window after that
+type wakeWord struct {
+	enabled bool
+	phrases [][]string
+	options WakeWordOptions
+
+	activeUntil time.Time
+	mu          sync.Mutex
+}
+
+func (w *wakeWord) configure(phrase string, opts ...WakeWordOption) {
+	w.options = WakeWordOptions{FollowUpWindow: defaultFollowUpWindow}
+	for _, opt := range opts {
+		opt(&w.options)
+	}
+
+	w.phrases = nil
+	for _, phrase := range append([]string{phrase}, w.options.Variants...) {
+		if words := normalizedWords(phrase); len(words) > 0 {
+			w.phrases = append(w.phrases, words)
+		}
+	}
+	w.enabled = w.configured()
+}
+
+func (w *wakeWord) configured() bool {
+	return len(w.phrases) > 0 || w.options.Detector != nil
+}
+
+func (w *wakeWord) isEnabled() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.enabled
+}
+
+// setEnabled turns the wake word mode on or off, the orchestrator starts
+// inactive either way
+func (w *wakeWord) setEnabled(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.enabled = enabled
+	w.activeUntil = time.Time{}
+}
+
+// activate opens the follow-up window, it reports whether the orchestrator
+// wasn't active already
+func (w *wakeWord) activate() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wasActive := time.Now().Before(w.activeUntil)
+	w.activeUntil = time.Now().Add(w.options.FollowUpWindow)
+	return !wasActive
+}
+
+// extend restarts the follow-up window if the orchestrator is active, e.g.
+// after a response ended
+func (w *wakeWord) extend() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.enabled && !w.activeUntil.IsZero() {
+		w.activeUntil = time.Now().Add(w.options.FollowUpWindow)
+	}
+}
+
+func (w *wakeWord) isActive() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return time.Now().Before(w.activeUntil)
+}
+
+// stripPhrase removes everything up to and including the first wake phrase in
+// the transcript
+func (w *wakeWord) stripPhrase(transcript string) (string, bool) {
+	words := strings.Fields(transcript)
+	normalized := make([]string, len(words))
+	for i, word := range words {
+		normalized[i] = strings.Join(normalizedWords(word), "")
+	}
+
+	for start := range normalized {
+		for _, phrase := range w.phrases {
+			if start+len(phrase) <= len(normalized) && slices.Equal(normalized[start:start+len(phrase)], phrase) {
+				return strings.Join(words[start+len(phrase):], " "), true
+			}
+		}
+	}
+	return transcript, false
+}
+
+// normalizedWords splits the text into lowercase words without punctuation
+func normalizedWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// detectWakeWord runs the local detector on the audio and activates the
+// orchestrator if it spots the wake phrase
+func (o *Orchestrator) detectWakeWord(audio []byte) {
+	if o.wakeWord.options.Detector == nil || !o.wakeWord.isEnabled() {
+		return
+	}
+	if o.wakeWord.options.Detector.DetectWakeWord(audio) {
+		o.onWakeWord()
+	}
+}
+
+// filterWakeWord strips the wake phrase from the transcript and reports
+// whether the rest should be responded to. In wake word mode transcripts
+// without the wake phrase are only responded to while the orchestrator is
+// active, i.e. during a turn or the follow-up window.
+func (o *Orchestrator) filterWakeWord(transcript string) (string, bool) {
+	if !o.wakeWord.isEnabled() {
+		return transcript, true
+	}
+
+	if prompt, found := o.wakeWord.stripPhrase(transcript); found {
+		o.onWakeWord()
+		return prompt, strings.TrimSpace(prompt) != ""
+	}
+	if o.wakeWord.isActive() || o.turns.activeTurn() != nil {
+		o.wakeWord.activate()
+		return transcript, true
+	}
+	return "", false
+}
+
+// onWakeWord is called when the wake phrase activated the orchestrator
+func (o *Orchestrator) onWakeWord() {
+	if !o.wakeWord.activate() {
+		return
+	}
+	log.Println("Wake phrase detected")
+	o.playEarcon()
+	if o.orchestrateOptions.onWakeWord != nil {
+		o.orchestrateOptions.onWakeWord()
+	}
+}
+
+const (
+	earconToneDuration = 70 * time.Millisecond
+	earconVolume       = 0.2
+)
+
+// earconTones are the frequencies of the tones of the earcon, played one
+// after another
+var earconTones = []float64{880, 1320}
+
+// playEarcon plays a short rising chime on the audio output. It isn't played
+// during an active turn, the turn's audio is paused and resumed by its
+// position on the output, which the earcon would shift.
+func (o *Orchestrator) playEarcon() {
+	if o.wakeWord.options.DisableEarcon || o.audioOutput == nil || !o.IsSpeaking {
+		return
+	}
+	if activeTurn := o.turns.activeTurn(); activeTurn != nil && !activeTurn.Cancelled {
+		return
+	}
+
+	encodingInfo := o.audioFormats.textToSpeech
+	if encodingInfo.SampleRate == 0 {
+		return
+	}
+
+	toneSamples := int(earconToneDuration.Seconds() * float64(encodingInfo.SampleRate))
+	fadeSamples := toneSamples / 5
+	samples := make([]float64, 0, toneSamples*len(earconTones))
+	for _, frequency := range earconTones {
+		for i := range toneSamples {
+			envelope := 1.0
+			if i < fadeSamples {
+				envelope = float64(i) / float64(fadeSamples)
+			} else if i >= toneSamples-fadeSamples {
+				envelope = float64(toneSamples-i) / float64(fadeSamples)
+			}
+			phase := 2 * math.Pi * frequency * float64(i) / float64(encodingInfo.SampleRate)
+			samples = append(samples, earconVolume*envelope*math.Sin(phase))
+		}
+	}
+
+	samples = convert.MixChannels(samples, 1, encodingInfo.ChannelCount())
+	o.sendAudioToOutput(convert.Encode(samples, encodingInfo.Encoding))
+}
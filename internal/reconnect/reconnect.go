@@ -0,0 +1,40 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	InitialBackoff = 250 * time.Millisecond
+	MaxBackoff     = 8 * time.Second
+	MaxAttempts    = 10
+)
+
+// Retry calls connect with exponential backoff until it succeeds, the context
+// is done or MaxAttempts failed attempts are made. name is only used for
+// logging.
+func Retry[T any](ctx context.Context, name string, connect func() (T, error)) (T, error) {
+	var zero T
+	backoff := InitialBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		conn, err := connect()
+		if err == nil {
+			return conn, nil
+		}
+
+		log.Printf("Failed to reconnect to %s (attempt %d): %v", name, attempt, err)
+		if attempt >= MaxAttempts {
+			return zero, fmt.Errorf("failed to reconnect after %d attempts: %w", attempt, err)
+		}
+		backoff = min(2*backoff, MaxBackoff)
+	}
+}
@@ -1,23 +1,32 @@
+// Command ema talks to EMA in the terminal.
+//
+// Usage:
+//
+//	ema [-config ema.yaml]                       terminal UI
+//	ema [-config ema.yaml] chat [-speak]         text conversation on stdin and stdout
+//	ema [-config ema.yaml] ask [-timeout 2m] ... one prompt, the result is printed as JSON
+//	ema [-config ema.yaml] listen [-json]        voice conversation, printed line by line
+//
+// The chat, ask and listen commands log to stderr, so they can be used in
+// shell pipes.
 package main
 
 import (
 	"bytes"
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"slices"
 
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/koscakluka/ema-core/core"
-	"github.com/koscakluka/ema-core/core/audio/miniaudio"
-	llmInterruptionsHandler "github.com/koscakluka/ema-core/core/interruptions/llm"
-	"github.com/koscakluka/ema-core/core/llms/groq"
-	deepgramstt "github.com/koscakluka/ema-core/core/speechtotext/deepgram"
-	deepgramt2s "github.com/koscakluka/ema-core/core/texttospeech/deepgram"
+	"github.com/koscakluka/ema-core/core/config"
 	"github.com/koscakluka/ema-core/internal/utils"
 
 	"github.com/charmbracelet/bubbles/textarea"
@@ -164,6 +173,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "l":
 				m.orchestrator.SetAlwaysRecording(!m.orchestrator.IsAlwaysRecording())
 
+			case "w":
+				m.orchestrator.SetWakeWordMode(!m.orchestrator.IsWakeWordMode())
+
 			case "m":
 				m.orchestrator.SetSpeaking(!m.orchestrator.IsSpeaking)
 
@@ -358,6 +370,7 @@ func (m model) View() string {
 		value string
 	}{
 		{label: "Recording", value: fmt.Sprintf("%v", m.orchestrator.IsRecording || m.orchestrator.IsAlwaysRecording())},
+		{label: "Wake Word", value: fmt.Sprintf("%v", m.orchestrator.IsWakeWordMode())},
 		{label: "Automatic Scroll", value: fmt.Sprintf("%v", m.automaticScroll)},
 		{label: "Speaking", value: fmt.Sprintf("%v", m.speechDetected)},
 	} {
@@ -414,43 +427,42 @@ func initialModel(orchestrator *orchestration.Orchestrator) model {
 }
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	deepgramClient := deepgramstt.NewClient(ctx)
-	defer deepgramClient.Close()
-
-	voice := deepgramt2s.VoiceAuraAsteria
-	deepgramSpeechClient, err := deepgramt2s.NewTextToSpeechClient(context.TODO(), voice)
-	if err != nil {
-		log.Printf("Failed to create deepgram speech client: %v", err)
+	configPath := flag.String("config", "", "orchestrator config file, YAML or JSON")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: ema [-config file] [chat|ask|listen] [command flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch command := flag.Arg(0); command {
+	case "":
+		err = runTUI(ctx, *configPath)
+	case "chat":
+		err = runChat(ctx, *configPath, flag.Args()[1:])
+	case "ask":
+		err = runAsk(ctx, *configPath, flag.Args()[1:])
+	case "listen":
+		err = runListen(ctx, *configPath, flag.Args()[1:])
+	default:
+		err = fmt.Errorf("unknown command %q, expected chat, ask or listen", command)
 	}
-	defer deepgramSpeechClient.Close(ctx)
-
-	audioClient, err := miniaudio.NewClient()
-
-	llm, err := groq.NewLlama3370BVersatileClient()
 	if err != nil {
-		log.Fatalf("Failed to create groq client: %v", err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
+}
 
-	structuredLlm, err := groq.NewGPTOSS20BClient()
+// runTUI runs the terminal UI
+func runTUI(ctx context.Context, configPath string) error {
+	orchestrator, release, err := newOrchestrator(ctx, configPath, nil)
 	if err != nil {
-		log.Fatalf("Failed to create structured groq client: %v", err)
+		return err
 	}
-
-	orchestrator := orchestration.NewOrchestrator(
-		orchestration.WithStreamingLLM(llm),
-		orchestration.WithSpeechToTextClient(deepgramClient),
-		orchestration.WithTextToSpeechClient(deepgramSpeechClient),
-		orchestration.WithAudioInput(audioClient),
-		orchestration.WithAudioOutputV1(audioClient),
-		orchestration.WithOrchestrationTools(),
-		orchestration.WithInterruptionHandlerV1(
-			llmInterruptionsHandler.NewInterruptionHandlerWithStructuredPrompt(structuredLlm),
-		),
-		orchestration.WithConfig(&orchestration.Config{AlwaysRecording: false}),
-	)
+	defer release()
 
 	program = tea.NewProgram(
 		initialModel(orchestrator),
@@ -458,7 +470,7 @@ func main() {
 		tea.WithMouseCellMotion(),
 	)
 
-	orchestrator.Orchestrate(ctx,
+	if err := orchestrator.Orchestrate(ctx,
 		orchestration.WithTranscriptionCallback(func(transcript string) {
 			program.Send(transcriptMsg(transcript))
 		}),
@@ -476,13 +488,51 @@ func main() {
 		}),
 		orchestration.WithCancellationCallback(func() {
 			program.Send(cancelMsg{})
-			audioClient.ClearBuffer()
 		}),
-	)
-	defer orchestrator.Close()
+	); err != nil {
+		return err
+	}
 
-	if _, err := program.Run(); err != nil {
-		log.Printf("Error: %v\n", err)
-		os.Exit(1)
+	_, err = program.Run()
+	return err
+}
+
+// newOrchestrator creates the orchestrator from the config file, adjust
+// changes the loaded config for the command. The release function closes the
+// clients and the orchestrator.
+func newOrchestrator(ctx context.Context, configPath string, adjust func(*config.Config) error) (*orchestration.Orchestrator, func(), error) {
+	orchestratorConfig, err := loadConfig(configPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if adjust != nil {
+		if err := adjust(orchestratorConfig); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	orchestrator, release, err := orchestratorConfig.NewOrchestrator(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create orchestrator: %w", err)
+	}
+	return orchestrator, release, nil
+}
+
+// loadConfig loads the config file, without one the environment picks the
+// speech to text provider and the audio devices
+func loadConfig(path string) (*config.Config, error) {
+	if path != "" {
+		return config.Load(path)
+	}
+
+	defaultConfig := &config.Config{}
+	if whisperURL, ok := os.LookupEnv("WHISPER_SERVER_URL"); ok {
+		defaultConfig.SpeechToText.Provider = config.ProviderWhisper
+		defaultConfig.SpeechToText.ServerURL = whisperURL
 	}
+	defaultConfig.Audio.PlaybackDevice = os.Getenv("PLAYBACK_DEVICE_ID")
+	defaultConfig.Audio.CaptureDevice = os.Getenv("CAPTURE_DEVICE_ID")
+	// NOTE: Keeps the start of speech said right before push to talk
+	defaultConfig.Orchestrator.PreRoll = 300 * time.Millisecond
+	return defaultConfig, defaultConfig.Validate()
 }